@@ -1,35 +1,54 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gorilla/mux"
-	"github.com/s-min-sys/notifier-share/pkg"
-	"github.com/s-min-sys/notifier-share/pkg/model"
 	"github.com/s-min-sys/timeassistbe/internal/autoimport"
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/notify"
+	"github.com/s-min-sys/timeassistbe/internal/notify/notifiershare"
 	"github.com/s-min-sys/timeassistbe/internal/timeassist"
 	"github.com/s-min-sys/timeassistbe/internal/utils"
+	"github.com/s-min-sys/timeassistbe/internal/ws"
+	"github.com/sgostarter/i/commerr"
 	"github.com/sgostarter/i/l"
 	"github.com/sgostarter/libconfig"
 	"github.com/sgostarter/libeasygo/pathutils"
-	"github.com/sgostarter/libeasygo/ptl"
 	"github.com/sgostarter/libeasygo/stg/kv"
 	"golang.org/x/exp/slices"
 )
 
 const (
-	dataRoot = "data"
+	dataRoot    = "data"
+	holidayRoot = "holiday"
+	wsListen    = ":12334"
 )
 
 type Config struct {
-	Listens   string `yaml:"Listens"`
-	NotifyURL string `yaml:"NotifyURL"`
+	Listens         string            `yaml:"Listens"`
+	NotifyURL       string            `yaml:"NotifyURL"`       // 没有配置 Notifiers 时使用 notifier-share 发送
+	Notifiers       []notify.Config   `yaml:"Notifiers"`       // 同时发送到所有配置的后端
+	NotifyTemplates map[string]string `yaml:"NotifyTemplates"` // text/template, alarm/task 为默认模板, 其它在 Notify.Template 中引用
+	HolidayRoot     string            `yaml:"HolidayRoot"`     // 每年一个节假日文件, 为空时使用 holiday 目录
+	WsListen        string            `yaml:"WsListen"`        // 推送 show list 变化的 WebSocket 地址, 路径 /shows
+
+	QuietHours []notify.QuietWindow `yaml:"QuietHours"` // 全局勿扰时段, 后端可以在自己的 QuietHours 中覆盖
+
+	AlarmHistory timeassist.AlarmHistoryOptions `yaml:"AlarmHistory"` // 每个 Alarm 的显示/通知/完成记录的保留限制
+
+	Locale string `yaml:"Locale"` // 默认语言, zh-CN/en-US; 请求可以用 lang 参数或 Accept-Language 选择
 }
 
 func main() {
@@ -47,27 +66,104 @@ func main() {
 	logger.GetLogger().SetLevel(l.LevelDebug)
 	logger.Info("new time assist start at:", time.Now())
 
-	metaStorage, _ := kv.NewMemoryFileStorageEx(filepath.Join(dataRoot, "task_meta"), false)
-	timer := timeassist.NewTaskTimer(filepath.Join(dataRoot, "task_timer"))
-	taskTimer := timeassist.NewBizTimer(timer)
+	if len(cfg.Notifiers) == 0 && cfg.NotifyURL != "" {
+		cfg.Notifiers = append(cfg.Notifiers, notify.Config{
+			Type: notifiershare.Type,
+			URL:  cfg.NotifyURL,
+		})
+	}
+
+	defaultLocale := locale.Default
+
+	if cfg.Locale != "" {
+		var ok bool
+
+		if defaultLocale, ok = locale.Parse(cfg.Locale); !ok {
+			panic(fmt.Errorf("unknown locale %q", cfg.Locale))
+		}
+	}
+
+	quietHours, err := notify.NewQuietHours(filepath.Join(dataRoot, "notify_quiet"), cfg.QuietHours, logger, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	notifier, err := notify.NewNotifiers(cfg.Notifiers, notify.WrapLocale, quietHours.Wrap)
+	if err != nil {
+		panic(err)
+	}
+
+	renderer, err := notify.NewRenderer(cfg.NotifyTemplates)
+	if err != nil {
+		panic(err)
+	}
+
+	alarmHistory := timeassist.NewAlarmHistory(filepath.Join(dataRoot, "alarm_history"), &cfg.AlarmHistory, logger, nil)
+
+	outbox := notify.NewOutbox(filepath.Join(dataRoot, "notify_outbox"), notifier, &notify.OutboxOptions{
+		OnAttempt: func(msg *notify.Message, err error) {
+			recordAlarmNotify(logger, alarmHistory, msg, err)
+		},
+	}, logger, nil)
+	outbox.Start()
+	quietHours.Start(outbox.Enqueue)
+
+	if cfg.HolidayRoot == "" {
+		cfg.HolidayRoot = holidayRoot
+	}
+
+	// 加载失败时为空, 使用内置数据
+	holidayCalendar, err := timeassist.LoadHolidayCalendar(cfg.HolidayRoot)
+	if err != nil {
+		logger.WithFields(l.ErrorField(err)).Error("load holiday calendar failed, use built-in")
+	}
+
+	eventBus := timeassist.NewEventBus(timeassist.DefaultEventBufferSize, nil)
 
+	backendLocales := notify.Locales(cfg.Notifiers)
+
+	metaStorage, _ := kv.NewMemoryFileStorageEx(filepath.Join(dataRoot, "task_meta"), false)
 	showList := timeassist.NewShowList(filepath.Join(dataRoot, "task_list"), func(task *timeassist.ShowInfo, visible bool) {
 		if !visible {
 			return
 		}
 
-		notifyAlarm(logger, cfg.NotifyURL, task)
+		notifyAlarm(logger, outbox, renderer, metaStorage, task, defaultLocale, backendLocales)
+	}, eventBus, nil)
+
+	showHub := ws.NewHub(func() (interface{}, error) {
+		shows, code, msg := handleGetTasks(showList)
+		if code != CodeSuccess {
+			return nil, errors.New(msg)
+		}
+
+		return &timeassist.ShowListEvent{
+			Type:  timeassist.ShowListEventSnapshot,
+			Shows: shows,
+		}, nil
+	}, logger)
+
+	showList.AddEventOb(func(event *timeassist.ShowListEvent) {
+		showHub.Broadcast(event)
 	})
+	showList.AddEventOb(alarmHistory.OnShowListEvent)
+
+	journal := timeassist.NewIntentJournal(filepath.Join(dataRoot, "task_journal"), metaStorage, showList)
+	timer := timeassist.NewTaskTimer(filepath.Join(dataRoot, "task_timer"), journal, eventBus, nil)
+	taskTimer := timeassist.NewBizTimer(timer)
 
-	taskManger := timeassist.NewTaskManager(metaStorage, taskTimer, showList, logger)
-	alarmManager := timeassist.NewAlarmManager(metaStorage, taskTimer, showList, logger)
+	taskManger := timeassist.NewTaskManager(metaStorage, taskTimer, showList, holidayCalendar, logger, nil)
+	alarmManager := timeassist.NewAlarmManager(metaStorage, taskTimer, showList, holidayCalendar, logger, nil)
+	taskHistory := timeassist.NewTaskHistory(filepath.Join(dataRoot, "task_history"), holidayCalendar, nil)
 
 	timer.Start()
 
 	autoimport.TryImportTaskConfigs("./import", "_task.yaml", taskManger, logger)
 	autoimport.TryImportAlarmConfigs("./import", "_alarm.yaml", alarmManager, logger)
+	autoimport.TryImportCalendars("./import", "_calendar.ics", alarmManager, logger)
 
 	r := mux.NewRouter()
+	r.Use(localeMiddleware(defaultLocale))
 
 	r.HandleFunc("/alarms/add", func(writer http.ResponseWriter, request *http.Request) {
 		var alarms []timeassist.Alarm
@@ -110,6 +206,28 @@ func main() {
 		httpResp(&respWrapper, writer)
 	}).Methods(http.MethodPost)
 
+	r.HandleFunc("/alarm/parse", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		alarm, code, msg := handleParseAlarmText(request)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = alarm
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/alarm/add_text", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		alarm, code, msg := handleAddAlarmText(request, alarmManager)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = alarm
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
 	r.HandleFunc("/alarm/remove", func(writer http.ResponseWriter, request *http.Request) {
 		var respWrapper ResponseWrapper
 
@@ -149,111 +267,1323 @@ func main() {
 		httpResp(&respWrapper, writer)
 	}).Methods(http.MethodGet)
 
-	r.HandleFunc("/shows", func(writer http.ResponseWriter, _ *http.Request) {
+	r.HandleFunc("/task/remove", func(writer http.ResponseWriter, request *http.Request) {
 		var respWrapper ResponseWrapper
 
-		tasks, code, msg := handleGetTasks(showList)
+		respWrapper.Apply(handleRemoveTask(request, taskManger))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	//
+	r.HandleFunc("/alarms", func(writer http.ResponseWriter, _ *http.Request) {
+		var respWrapper ResponseWrapper
+
+		alarms, code, msg := handleListAlarms(alarmManager)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = alarms
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/alarms/preview", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		occurrences, code, msg := handlePreviewAlarm(request, holidayCalendar)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = occurrences
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/alarms/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		alarm, code, msg := handleGetAlarm(request, alarmManager)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = alarm
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/alarms/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleUpdateAlarm(request, alarmManager, request.Method == http.MethodPatch))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPut, http.MethodPatch)
+
+	r.HandleFunc("/alarms/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleDeleteAlarm(request, alarmManager))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodDelete)
+
+	r.HandleFunc("/alarms/{id}/history", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		records, code, msg := handleAlarmHistory(request, alarmHistory)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = records
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/alarms/{id}/pause", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handlePause(request, alarmManager.Pause))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/alarms/{id}/resume", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleResume(request, alarmManager.Resume))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/alarms/{id}/exceptions", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		exceptions, code, msg := handleListAlarmExceptions(request, alarmManager)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = exceptions
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/alarms/{id}/exceptions", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		exception, code, msg := handleAddAlarmException(request, alarmManager)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = exception
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/alarms/{id}/exceptions/{occurrence_at}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleRemoveAlarmException(request, alarmManager))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodDelete)
+
+	r.HandleFunc("/tasks", func(writer http.ResponseWriter, _ *http.Request) {
+		var respWrapper ResponseWrapper
+
+		tasks, code, msg := handleListTasks(taskManger)
 		if respWrapper.Apply(code, msg) {
 			respWrapper.Resp = tasks
 		}
 
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/tasks/preview", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		occurrences, code, msg := handlePreviewTask(request, holidayCalendar)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = occurrences
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/tasks/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		task, code, msg := handleGetTask(request, taskManger)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = task
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/tasks/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleUpdateTask(request, taskManger, request.Method == http.MethodPatch))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPut, http.MethodPatch)
+
+	r.HandleFunc("/tasks/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleDeleteTask(request, taskManger))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodDelete)
+
+	r.HandleFunc("/tasks/{id}/history", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		completions, code, msg := handleTaskHistory(request, taskHistory)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = completions
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/tasks/{id}/rate", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		rate, code, msg := handleTaskRate(request, taskManger, taskHistory)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = rate
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/tasks/{id}/streak", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		streak, code, msg := handleTaskStreak(request, taskManger, taskHistory)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = streak
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/tasks/{id}/pause", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handlePause(request, taskManger.Pause))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/tasks/{id}/resume", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		respWrapper.Apply(handleResume(request, taskManger.Resume))
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/shows", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		tasks, code, msg := handleGetTasks(showList)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = localizeShows(tasks, requestLocale(request))
+		}
+
 		httpResp(&respWrapper, writer)
 	})
 
 	r.HandleFunc("/shows/{task_id}/done", func(writer http.ResponseWriter, request *http.Request) {
 		var respWrapper ResponseWrapper
 
-		respWrapper.Apply(handleTaskDone(request, showList, taskManger))
+		respWrapper.Apply(handleTaskDone(request, showList, taskManger, alarmManager, taskHistory, alarmHistory, logger))
 
 		httpResp(&respWrapper, writer)
 	})
 
-	doNotify(logger, cfg.NotifyURL, "time assist be started")
+	r.HandleFunc("/shows/{task_id}/snooze", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
 
-	fnListen := func(listen string) {
-		srv := &http.Server{
-			Addr:        listen,
-			ReadTimeout: time.Second,
-			Handler:     r,
+		respWrapper.Apply(handleSnooze(request, alarmManager, alarmHistory, logger))
+
+		httpResp(&respWrapper, writer)
+	})
+
+	r.HandleFunc("/calendar.ics", func(writer http.ResponseWriter, request *http.Request) {
+		d, code, msg := handleCalendar(request, alarmManager, taskManger, holidayCalendar)
+		if code != CodeSuccess {
+			var respWrapper ResponseWrapper
+
+			respWrapper.Apply(code, msg)
+
+			httpResp(&respWrapper, writer)
+
+			return
 		}
 
-		logger.WithFields(l.StringField("listen", listen)).Debug("start listen")
+		writer.Header().Add("Content-Type", "text/calendar; charset=utf-8")
+		writer.WriteHeader(http.StatusOK)
+
+		_, _ = writer.Write(d)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/calendar/import", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		results, code, msg := handleImportCalendar(request, alarmManager)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = results
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/outbox", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		items, code, msg := handleListOutbox(request, outbox)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = items
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/outbox/dead/retry", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		n, code, msg := handleRetryDeadLetters(request, outbox)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = n
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/outbox/dead/{id}/retry", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		n, code, msg := handleRetryDeadLetters(request, outbox)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = n
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodPost)
+
+	r.HandleFunc("/outbox/dead", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		n, code, msg := handlePurgeDeadLetters(request, outbox)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = n
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodDelete)
+
+	r.HandleFunc("/outbox/dead/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		n, code, msg := handlePurgeDeadLetters(request, outbox)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = n
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodDelete)
+
+	r.HandleFunc("/notify/held", func(writer http.ResponseWriter, request *http.Request) {
+		var respWrapper ResponseWrapper
+
+		items, code, msg := handleListHeld(quietHours)
+		if respWrapper.Apply(code, msg) {
+			respWrapper.Resp = items
+		}
+
+		httpResp(&respWrapper, writer)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/events", func(writer http.ResponseWriter, request *http.Request) {
+		handleEvents(writer, request, eventBus)
+	}).Methods(http.MethodGet)
+
+	doNotify(logger, outbox, &notify.Message{
+		Text: "time assist be started",
+	})
+
+	if cfg.WsListen == "" {
+		cfg.WsListen = wsListen
+	}
+
+	logger.WithFields(l.StringField("listen", cfg.WsListen)).Debug("start ws listen")
+
+	_ = ws.NewWs(cfg.WsListen, map[string]ws.Handler{
+		"/shows": showHub.Serve,
+	})
+
+	fnListen := func(listen string) {
+		srv := &http.Server{
+			Addr:        listen,
+			ReadTimeout: time.Second,
+			Handler:     r,
+		}
+
+		logger.WithFields(l.StringField("listen", listen)).Debug("start listen")
+
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.WithFields(l.ErrorField(err), l.StringField("listen", listen)).Error("listen failed")
+		}
+	}
+
+	listens := strings.Split(cfg.Listens, " ")
+
+	for idx := 0; idx < len(listens)-1; idx++ {
+		go fnListen(listens[idx])
+	}
+
+	fnListen(listens[len(listens)-1])
+}
+
+//
+//
+//
+
+func httpResp(respWrapper *ResponseWrapper, writer http.ResponseWriter) {
+	if lc, ok := locale.Parse(writer.Header().Get(headerContentLanguage)); ok && respWrapper.Message != "" {
+		respWrapper.Message = CodeToMessageLocale(respWrapper.Code, respWrapper.msg, lc)
+	}
+
+	writer.Header().Add("Content-Type", "application/json")
+
+	writer.WriteHeader(http.StatusOK)
+
+	d, _ := json.Marshal(respWrapper)
+
+	_, _ = writer.Write(d)
+}
+
+const headerContentLanguage = "Content-Language"
+
+type localeContextKey struct{}
+
+// localeMiddleware 按 lang 参数或 Accept-Language 选择语言, 放在 request 的 context 和响应的 Content-Language 中
+func localeMiddleware(defaultLocale locale.Locale) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			lc, ok := locale.Parse(request.URL.Query().Get("lang"))
+			if !ok {
+				lc = locale.FromAcceptLanguage(request.Header.Get("Accept-Language"), defaultLocale)
+			}
+
+			writer.Header().Set(headerContentLanguage, string(lc))
+
+			next.ServeHTTP(writer, request.WithContext(context.WithValue(request.Context(), localeContextKey{}, lc)))
+		})
+	}
+}
+
+func requestLocale(request *http.Request) locale.Locale {
+	if lc, ok := request.Context().Value(localeContextKey{}).(locale.Locale); ok {
+		return lc
+	}
+
+	return locale.Default
+}
+
+func localizeShows(shows []*timeassist.ShowInfo, lc locale.Locale) []*timeassist.ShowInfo {
+	timeNow := time.Now()
+
+	localized := make([]*timeassist.ShowInfo, 0, len(shows))
+
+	for _, show := range shows {
+		localized = append(localized, show.Localize(lc, timeNow))
+	}
+
+	return localized
+}
+
+// handleListOutbox status 为 pending/delivered/dead, 默认只看死信
+func handleListOutbox(request *http.Request, outbox notify.Outbox) (items []*notify.OutboxItem, code Code, msg string) {
+	status := notify.OutboxStatus(request.URL.Query().Get("status"))
+	if status == "" {
+		status = notify.OutboxStatusDead
+	}
+
+	items, err := outbox.List(status)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+// handleListHeld 勿扰时段内保存, 还没有合并成摘要发送的通知
+func handleListHeld(quietHours notify.QuietHours) (items []*notify.HeldMessage, code Code, msg string) {
+	items, err := quietHours.Held()
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleRetryDeadLetters(request *http.Request, outbox notify.Outbox) (n int, code Code, msg string) {
+	n, err := outbox.Retry(mux.Vars(request)["id"])
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handlePurgeDeadLetters(request *http.Request, outbox notify.Outbox) (n int, code Code, msg string) {
+	n, err := outbox.Purge(mux.Vars(request)["id"])
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+const sseHeartbeatInterval = 15 * time.Second
+
+// handleEvents Server-Sent Events, 客户端重连时通过 Last-Event-ID 或 last_event_id 参数续上
+func handleEvents(writer http.ResponseWriter, request *http.Request, eventBus timeassist.EventBus) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writer.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	lastEventIDS := request.Header.Get("Last-Event-ID")
+	if lastEventIDS == "" {
+		lastEventIDS = request.URL.Query().Get("last_event_id")
+	}
+
+	lastEventID, _ := strconv.ParseUint(lastEventIDS, 10, 64)
+
+	backlog, ch, cancel := eventBus.Subscribe(lastEventID)
+	defer cancel()
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+
+	fnWrite := func(event *timeassist.Event) bool {
+		d, err := json.Marshal(event)
+		if err != nil {
+			return true
+		}
+
+		_, err = fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, d)
+
+		return err == nil
+	}
+
+	for _, event := range backlog {
+		if !fnWrite(event) {
+			return
+		}
+	}
+
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case event, ok := <-ch:
+			// 处理不过来被断开, 客户端带 Last-Event-ID 重连
+			if !ok || !fnWrite(event) {
+				return
+			}
+		case <-ticker.C:
+			if _, err := writer.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+func handleGetTasks(taskList timeassist.ShowList) (
+	tasks []*timeassist.ShowInfo, code Code, msg string) {
+	tasks, err := taskList.GetList()
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if tasks == nil {
+		tasks = make([]*timeassist.ShowInfo, 0)
+	}
+
+	slices.SortFunc(tasks, func(a, b *timeassist.ShowInfo) int {
+		if a.VOTaskType != b.VOTaskType {
+			if a.VOTaskType == timeassist.VOTaskTypeTask {
+				return 1
+			}
+
+			return -1
+		}
+
+		if a.VOTaskType == timeassist.VOTaskTypeTask {
+			return strings.Compare(a.Value, b.Value)
+		}
+
+		return a.AlarmAt.Compare(b.AlarmAt)
+	})
+
+	return
+}
+
+func handleAddAlarm(request *http.Request, alarmManager timeassist.AlarmManager) (code Code, msg string) {
+	var alarm timeassist.Alarm
+
+	err := json.NewDecoder(request.Body).Decode(&alarm)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	err = alarmManager.Add(&alarm)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+// AlarmTextRequest 自然语言描述的提醒, 如 "每周三早上9点 开会"; TimeZone/Location 同 Alarm
+type AlarmTextRequest struct {
+	Text     string `json:"text"`
+	TimeZone int    `json:"timeZone,omitempty"`
+	Location string `json:"location,omitempty"`
+}
+
+func handleParseAlarmText(request *http.Request) (alarm *timeassist.Alarm, code Code, msg string) {
+	var req AlarmTextRequest
+
+	err := json.NewDecoder(request.Body).Decode(&req)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	alarm, err = timeassist.ParseAlarmText(req.Text, req.Location, req.TimeZone, time.Now())
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleAddAlarmText(request *http.Request, alarmManager timeassist.AlarmManager) (alarm *timeassist.Alarm, code Code, msg string) {
+	alarm, code, msg = handleParseAlarmText(request)
+	if code != CodeSuccess {
+		return
+	}
+
+	if err := alarmManager.Add(alarm); err != nil {
+		alarm = nil
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	return
+}
+
+func handleRemoveAlarm(request *http.Request, alarmManager timeassist.AlarmManager) (code Code, msg string) {
+	id := request.URL.Query().Get("id")
+	if id == "" {
+		code = CodeErrBadRequest
+
+		return
+	}
+
+	err := alarmManager.Remove(id)
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+type AlarmItem struct {
+	ID       string `json:"id"`
+	CheckAt  int64  `json:"check_at"`
+	CheckAtS string `json:"check_at_s"`
+
+	ShowAt    int64  `json:"show_at"`
+	ShowAtS   string `json:"show_at_s"`
+	ExpireAt  int64  `json:"expire_at"`
+	ExpireAtS string `json:"expire_at_s"`
+
+	Text     string `json:"text"`
+	Value    string `json:"value"`
+	AValue   string `json:"a_value"`
+	LeftTime string `json:"left_time"`
+}
+
+func handleGetAlarms(request *http.Request, t timeassist.TaskTimer, storage kv.StorageTiny) (aItems []AlarmItem, code Code, msg string) {
+	items, err := t.List()
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	aItems = make([]AlarmItem, 0, len(items))
+	lc := requestLocale(request)
+
+	fnFormatTime := func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	}
+
+	fnFormatTimeStamp := func(tm int64) string {
+		return fnFormatTime(time.Unix(tm, 0))
+	}
+
+	for _, d := range items {
+		idPre := timeassist.ParsePreOnID(d.Data.ID)
+		if idPre != timeassist.AlarmIDPre {
+			continue
+		}
+
+		alarm := &timeassist.Alarm{}
+
+		ok, e := storage.Get(d.Data.ID, alarm)
+		if e != nil || !ok {
+			continue
+		}
+
+		av, e := timeassist.ParseAlarmValue(alarm.Value, alarm.AType)
+		if e != nil {
+			continue
+		}
+
+		_, aValue := av.StringNoNowTimeLocale(alarm.AType, lc)
+		aItems = append(aItems, AlarmItem{
+			ID:        d.Data.ID,
+			CheckAt:   d.At.Unix(),
+			CheckAtS:  fnFormatTime(d.At),
+			ShowAt:    d.Data.StartUTC,
+			ShowAtS:   fnFormatTimeStamp(d.Data.StartUTC),
+			ExpireAt:  d.Data.EndUTC,
+			ExpireAtS: fnFormatTimeStamp(d.Data.EndUTC),
+			Text:      alarm.Text,
+			Value:     alarm.Value,
+			AValue:    aValue,
+			LeftTime:  utils.LeftTimeStringLocale(time.Unix(d.Data.EndUTC, 0), time.Now(), lc),
+		})
+	}
+
+	slices.SortFunc(aItems, func(a, b AlarmItem) int {
+		if a.ExpireAt < b.ExpireAt {
+			return -1
+		}
+
+		if a.ExpireAt > b.ExpireAt {
+			return 1
+		}
+
+		return 0
+	})
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleAddTask(request *http.Request, taskManager timeassist.TaskManager) (code Code, msg string) {
+	var task timeassist.Task
+
+	err := json.NewDecoder(request.Body).Decode(&task)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	err = taskManager.Add(&task)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleRemoveTask(request *http.Request, taskManager timeassist.TaskManager) (code Code, msg string) {
+	id := request.URL.Query().Get("id")
+	if id == "" {
+		code = CodeErrBadRequest
+
+		return
+	}
+
+	err := taskManager.Remove(id)
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleListAlarms(alarmManager timeassist.AlarmManager) (alarms []*timeassist.Alarm, code Code, msg string) {
+	alarms, err := alarmManager.List()
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if alarms == nil {
+		alarms = make([]*timeassist.Alarm, 0)
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleGetAlarm(request *http.Request, alarmManager timeassist.AlarmManager) (alarm *timeassist.Alarm, code Code, msg string) {
+	alarm, err := alarmManager.Get(mux.Vars(request)["id"])
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if alarm == nil {
+		code = CodeErrNotFound
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+// handleUpdateAlarm PUT 整体替换, PATCH 只修改请求中出现的字段
+func handleUpdateAlarm(request *http.Request, alarmManager timeassist.AlarmManager, patch bool) (code Code, msg string) {
+	id := mux.Vars(request)["id"]
+
+	alarm := &timeassist.Alarm{}
+
+	if patch {
+		old, err := alarmManager.Get(id)
+		if err != nil {
+			code = CodeErrInternal
+			msg = err.Error()
+
+			return
+		}
+
+		if old == nil {
+			code = CodeErrNotFound
+
+			return
+		}
+
+		alarm = old
+	}
+
+	err := json.NewDecoder(request.Body).Decode(alarm)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	alarm.ID = id
+
+	err = alarmManager.Update(alarm)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleDeleteAlarm(request *http.Request, alarmManager timeassist.AlarmManager) (code Code, msg string) {
+	id := mux.Vars(request)["id"]
+
+	alarm, err := alarmManager.Get(id)
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if alarm == nil {
+		code = CodeErrNotFound
+
+		return
+	}
+
+	err = alarmManager.Remove(id)
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+// handlePause resume_at 为 unix 秒或 RFC3339, 不传时需要手动恢复
+func handlePause(request *http.Request, pause func(id string, resumeAt time.Time) error) (code Code, msg string) {
+	resumeAt, err := parseTimeParam(request, "resume_at", time.Time{})
+	if err != nil {
+		code = CodeErrBadRequest
+		msg = err.Error()
+
+		return
+	}
+
+	err = pause(mux.Vars(request)["id"], resumeAt)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleResume(request *http.Request, resume func(id string) error) (code Code, msg string) {
+	err := resume(mux.Vars(request)["id"])
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleListAlarmExceptions(request *http.Request, alarmManager timeassist.AlarmManager) (
+	exceptions []*timeassist.AlarmException, code Code, msg string) {
+	alarm, err := alarmManager.Get(mux.Vars(request)["id"])
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if alarm == nil {
+		code = CodeErrNotFound
+
+		return
+	}
+
+	exceptions = alarm.Exceptions
+	code = CodeSuccess
+
+	return
+}
+
+// handleAddAlarmException occurrence_at 为 0 时为下一次提醒, skip 和 reschedule_at 只能设置一个
+func handleAddAlarmException(request *http.Request, alarmManager timeassist.AlarmManager) (
+	exception *timeassist.AlarmException, code Code, msg string) {
+	exception = &timeassist.AlarmException{}
+
+	err := json.NewDecoder(request.Body).Decode(exception)
+	if err != nil {
+		exception = nil
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	err = alarmManager.AddException(mux.Vars(request)["id"], exception)
+	if err != nil {
+		exception = nil
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleRemoveAlarmException(request *http.Request, alarmManager timeassist.AlarmManager) (code Code, msg string) {
+	vars := mux.Vars(request)
+
+	occurrenceAt, err := strconv.ParseInt(vars["occurrence_at"], 10, 64)
+	if err != nil {
+		code = CodeErrBadRequest
+		msg = "invalid occurrence_at"
+
+		return
+	}
+
+	err = alarmManager.RemoveException(vars["id"], occurrenceAt)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleListTasks(taskManager timeassist.TaskManager) (tasks []*timeassist.Task, code Code, msg string) {
+	tasks, err := taskManager.List()
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if tasks == nil {
+		tasks = make([]*timeassist.Task, 0)
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleGetTask(request *http.Request, taskManager timeassist.TaskManager) (task *timeassist.Task, code Code, msg string) {
+	task, err := taskManager.Get(mux.Vars(request)["id"])
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if task == nil {
+		code = CodeErrNotFound
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+// handleUpdateTask PUT 整体替换, PATCH 只修改请求中出现的字段
+func handleUpdateTask(request *http.Request, taskManager timeassist.TaskManager, patch bool) (code Code, msg string) {
+	id := mux.Vars(request)["id"]
+
+	task := &timeassist.Task{}
+
+	if patch {
+		old, err := taskManager.Get(id)
+		if err != nil {
+			code = CodeErrInternal
+			msg = err.Error()
+
+			return
+		}
+
+		if old == nil {
+			code = CodeErrNotFound
+
+			return
+		}
+
+		task = old
+	}
+
+	err := json.NewDecoder(request.Body).Decode(task)
+	if err != nil {
+		code = CodeErrParse
+		msg = err.Error()
+
+		return
+	}
+
+	task.ID = id
+
+	err = taskManager.Update(task)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+func handleDeleteTask(request *http.Request, taskManager timeassist.TaskManager) (code Code, msg string) {
+	id := mux.Vars(request)["id"]
+
+	task, err := taskManager.Get(id)
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	if task == nil {
+		code = CodeErrNotFound
+
+		return
+	}
+
+	err = taskManager.Remove(id)
+	if err != nil {
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	code = CodeSuccess
+
+	return
+}
+
+// handleTaskDone task 在显示中时记录一次完成
+func handleTaskDone(request *http.Request, taskList timeassist.ShowList, taskManager timeassist.TaskManager,
+	alarmManager timeassist.AlarmManager, taskHistory timeassist.TaskHistory, alarmHistory timeassist.AlarmHistory,
+	logger l.Wrapper) (code Code, msg string) {
+	taskID := mux.Vars(request)["task_id"]
+
+	switch timeassist.ParsePreOnID(taskID) {
+	case timeassist.TaskIDPre:
+		if showInfo, err := taskList.Get(taskID); err == nil && showInfo != nil {
+			if err = taskHistory.Record(timeassist.NewTaskCompletion(showInfo, time.Now())); err != nil {
+				logger.WithFields(l.ErrorField(err), l.StringField("id", taskID)).Error("record task completion failed")
+			}
+		}
+
+		taskManager.TaskDone(taskID)
+	case timeassist.AlarmIDPre:
+		// 提醒不存在或没有在显示时不记录
+		if err := alarmManager.Done(taskID); err == nil {
+			recordAlarmHistory(logger, alarmHistory, taskID, &timeassist.AlarmHistoryRecord{
+				Type: timeassist.AlarmHistoryDone,
+			})
+		} else if !errors.Is(err, commerr.ErrNotFound) {
+			logger.WithFields(l.ErrorField(err), l.StringField("id", taskID)).Error("alarm done failed")
+		}
+	}
+
+	_ = taskList.Remove(taskID)
+
+	code = CodeSuccess
+
+	return
+}
+
+// parseTimeParam unix 秒或 RFC3339, 没有时使用 defaultValue
+func parseTimeParam(request *http.Request, key string, defaultValue time.Time) (t time.Time, err error) {
+	s := request.URL.Query().Get(key)
+	if s == "" {
+		return defaultValue, nil
+	}
+
+	if n, e := strconv.ParseInt(s, 10, 64); e == nil {
+		return time.Unix(n, 0), nil
+	}
+
+	t, err = time.Parse(time.RFC3339, s)
+	if err != nil {
+		err = fmt.Errorf("invalid %s", key)
+	}
+
+	return
+}
+
+func parseTimeRange(request *http.Request, defaultStart, defaultEnd time.Time) (startAt, endAt time.Time, err error) {
+	if startAt, err = parseTimeParam(request, "start", defaultStart); err != nil {
+		return
+	}
+
+	endAt, err = parseTimeParam(request, "end", defaultEnd)
+
+	return
+}
+
+// parsePreviewParams start 默认为当前时间, count 默认为 10, 最多 100
+func parsePreviewParams(request *http.Request) (timeFrom time.Time, count int, err error) {
+	if timeFrom, err = parseTimeParam(request, "start", time.Now()); err != nil {
+		return
+	}
 
-		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.WithFields(l.ErrorField(err), l.StringField("listen", listen)).Error("listen failed")
+	if s := request.URL.Query().Get("count"); s != "" {
+		count, err = strconv.Atoi(s)
+		if err != nil || count <= 0 {
+			err = errors.New("invalid count")
 		}
 	}
 
-	listens := strings.Split(cfg.Listens, " ")
+	return
+}
 
-	for idx := 0; idx < len(listens)-1; idx++ {
-		go fnListen(listens[idx])
+func handlePreviewAlarm(request *http.Request, holidayCalendar timeassist.HolidayCalendar) (occurrences []*timeassist.AlarmOccurrence, code Code, msg string) {
+	timeFrom, count, err := parsePreviewParams(request)
+	if err != nil {
+		code = CodeErrBadRequest
+		msg = err.Error()
+
+		return
 	}
 
-	fnListen(listens[len(listens)-1])
-}
+	var alarm timeassist.Alarm
 
-//
-//
-//
+	if err = json.NewDecoder(request.Body).Decode(&alarm); err != nil {
+		code = CodeErrParse
+		msg = err.Error()
 
-func httpResp(respWrapper *ResponseWrapper, writer http.ResponseWriter) {
-	writer.Header().Add("Content-Type", "application/json")
+		return
+	}
 
-	writer.WriteHeader(http.StatusOK)
+	occurrences, err = timeassist.PreviewAlarmLocale(&alarm, timeFrom, count, requestLocale(request), holidayCalendar)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
 
-	d, _ := json.Marshal(respWrapper)
+		return
+	}
 
-	_, _ = writer.Write(d)
+	code = CodeSuccess
+
+	return
 }
 
-func handleGetTasks(taskList timeassist.ShowList) (
-	tasks []*timeassist.ShowInfo, code Code, msg string) {
-	tasks, err := taskList.GetList()
+func handlePreviewTask(request *http.Request, holidayCalendar timeassist.HolidayCalendar) (occurrences []*timeassist.TaskOccurrence, code Code, msg string) {
+	timeFrom, count, err := parsePreviewParams(request)
 	if err != nil {
-		code = CodeErrInternal
+		code = CodeErrBadRequest
 		msg = err.Error()
 
 		return
 	}
 
-	if tasks == nil {
-		tasks = make([]*timeassist.ShowInfo, 0)
-	}
+	var task timeassist.Task
 
-	slices.SortFunc(tasks, func(a, b *timeassist.ShowInfo) int {
-		if a.VOTaskType != b.VOTaskType {
-			if a.VOTaskType == timeassist.VOTaskTypeTask {
-				return 1
-			}
+	if err = json.NewDecoder(request.Body).Decode(&task); err != nil {
+		code = CodeErrParse
+		msg = err.Error()
 
-			return -1
-		}
+		return
+	}
 
-		if a.VOTaskType == timeassist.VOTaskTypeTask {
-			return strings.Compare(a.Value, b.Value)
-		}
+	occurrences, err = timeassist.PreviewTaskLocale(&task, timeFrom, count, requestLocale(request), holidayCalendar)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
 
-		return a.AlarmAt.Compare(b.AlarmAt)
-	})
+		return
+	}
+
+	code = CodeSuccess
 
 	return
 }
 
-func handleAddAlarm(request *http.Request, alarmManager timeassist.AlarmManager) (code Code, msg string) {
-	var alarm timeassist.Alarm
-
-	err := json.NewDecoder(request.Body).Decode(&alarm)
+func handleTaskHistory(request *http.Request, taskHistory timeassist.TaskHistory) (
+	completions []*timeassist.TaskCompletion, code Code, msg string) {
+	startAt, endAt, err := parseTimeRange(request, time.Time{}, time.Time{})
 	if err != nil {
-		code = CodeErrParse
+		code = CodeErrBadRequest
 		msg = err.Error()
 
 		return
 	}
 
-	err = alarmManager.Add(&alarm)
+	completions, err = taskHistory.List(mux.Vars(request)["id"], startAt, endAt)
 	if err != nil {
-		code = CodeErrParse
+		code = errorToCode(err)
 		msg = err.Error()
 
 		return
@@ -264,17 +1594,27 @@ func handleAddAlarm(request *http.Request, alarmManager timeassist.AlarmManager)
 	return
 }
 
-func handleRemoveAlarm(request *http.Request, alarmManager timeassist.AlarmManager) (code Code, msg string) {
-	id := request.URL.Query().Get("id")
-	if id == "" {
+// handleTaskRate 默认统计最近 30 天
+func handleTaskRate(request *http.Request, taskManager timeassist.TaskManager, taskHistory timeassist.TaskHistory) (
+	rate *timeassist.TaskCompletionRate, code Code, msg string) {
+	task, code, msg := handleGetTask(request, taskManager)
+	if code != CodeSuccess {
+		return
+	}
+
+	timeNow := time.Now()
+
+	startAt, endAt, err := parseTimeRange(request, timeNow.AddDate(0, 0, -30), timeNow)
+	if err != nil {
 		code = CodeErrBadRequest
+		msg = err.Error()
 
 		return
 	}
 
-	err := alarmManager.Remove(id)
+	rate, err = taskHistory.Rate(task, startAt, endAt)
 	if err != nil {
-		code = CodeErrInternal
+		code = errorToCode(err)
 		msg = err.Error()
 
 		return
@@ -285,131 +1625,193 @@ func handleRemoveAlarm(request *http.Request, alarmManager timeassist.AlarmManag
 	return
 }
 
-type AlarmItem struct {
-	ID       string `json:"id"`
-	CheckAt  int64  `json:"check_at"`
-	CheckAtS string `json:"check_at_s"`
+func handleTaskStreak(request *http.Request, taskManager timeassist.TaskManager, taskHistory timeassist.TaskHistory) (
+	streak *timeassist.TaskStreak, code Code, msg string) {
+	task, code, msg := handleGetTask(request, taskManager)
+	if code != CodeSuccess {
+		return
+	}
 
-	ShowAt    int64  `json:"show_at"`
-	ShowAtS   string `json:"show_at_s"`
-	ExpireAt  int64  `json:"expire_at"`
-	ExpireAtS string `json:"expire_at_s"`
+	streak, err := taskHistory.Streak(task)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
 
-	Text     string `json:"text"`
-	Value    string `json:"value"`
-	AValue   string `json:"a_value"`
-	LeftTime string `json:"left_time"`
+		return
+	}
+
+	code = CodeSuccess
+
+	return
 }
 
-func handleGetAlarms(_ *http.Request, t timeassist.TaskTimer, storage kv.StorageTiny) (aItems []AlarmItem, code Code, msg string) {
-	items, err := t.List()
+func handleSnooze(request *http.Request, alarmManager timeassist.AlarmManager, alarmHistory timeassist.AlarmHistory,
+	logger l.Wrapper) (code Code, msg string) {
+	taskID := mux.Vars(request)["task_id"]
+
+	if timeassist.ParsePreOnID(taskID) != timeassist.AlarmIDPre {
+		code = CodeErrBadRequest
+		msg = "only alarm can snooze"
+
+		return
+	}
+
+	var minutes int
+
+	if s := request.URL.Query().Get("minutes"); s != "" {
+		var err error
+
+		minutes, err = strconv.Atoi(s)
+		if err != nil || minutes <= 0 {
+			code = CodeErrBadRequest
+			msg = "invalid minutes"
+
+			return
+		}
+	}
+
+	err := alarmManager.Snooze(taskID, minutes)
 	if err != nil {
-		code = CodeErrInternal
+		code = errorToCode(err)
 		msg = err.Error()
 
 		return
 	}
 
-	aItems = make([]AlarmItem, 0, len(items))
+	if minutes == 0 {
+		minutes = timeassist.DefaultSnoozeMinutes
+	}
 
-	fnFormatTime := func(t time.Time) string {
-		return t.Format("2006-01-02 15:04:05")
+	recordAlarmHistory(logger, alarmHistory, taskID, &timeassist.AlarmHistoryRecord{
+		Type:    timeassist.AlarmHistorySnooze,
+		Message: fmt.Sprintf("%d minutes", minutes),
+	})
+
+	code = CodeSuccess
+
+	return
+}
+
+func recordAlarmHistory(logger l.Wrapper, alarmHistory timeassist.AlarmHistory, alarmID string,
+	record *timeassist.AlarmHistoryRecord) {
+	if err := alarmHistory.Record(alarmID, record); err != nil {
+		logger.WithFields(l.ErrorField(err), l.StringField("id", alarmID)).Error("record alarm history failed")
 	}
+}
 
-	fnFormatTimeStamp := func(tm int64) string {
-		return fnFormatTime(time.Unix(tm, 0))
+// recordAlarmNotify outbox 每次发送 Alarm 的通知后记录结果
+func recordAlarmNotify(logger l.Wrapper, alarmHistory timeassist.AlarmHistory, msg *notify.Message, err error) {
+	if msg.Show == nil || timeassist.ParsePreOnID(msg.Show.ID) != timeassist.AlarmIDPre {
+		return
 	}
 
-	for _, d := range items {
-		idPre := timeassist.ParsePreOnID(d.Data.ID)
-		if idPre != timeassist.AlarmIDPre {
-			continue
-		}
+	record := &timeassist.AlarmHistoryRecord{
+		Type:     timeassist.AlarmHistoryNotifySent,
+		NotifyID: msg.NotifyID,
+	}
 
-		alarm := &timeassist.Alarm{}
+	if err != nil {
+		record.Type = timeassist.AlarmHistoryNotifyFailed
+		record.Message = err.Error()
+	}
 
-		ok, e := storage.Get(d.Data.ID, alarm)
-		if e != nil || !ok {
-			continue
-		}
+	recordAlarmHistory(logger, alarmHistory, msg.Show.ID, record)
+}
 
-		av, e := timeassist.ParseAlarmValue(alarm.Value, alarm.AType)
-		if e != nil {
-			continue
-		}
+func handleAlarmHistory(request *http.Request, alarmHistory timeassist.AlarmHistory) (
+	records []*timeassist.AlarmHistoryRecord, code Code, msg string) {
+	startAt, endAt, err := parseTimeRange(request, time.Time{}, time.Time{})
+	if err != nil {
+		code = CodeErrBadRequest
+		msg = err.Error()
 
-		_, aValue := av.StringNoNowTime(alarm.AType)
-		aItems = append(aItems, AlarmItem{
-			ID:        d.Data.ID,
-			CheckAt:   d.At.Unix(),
-			CheckAtS:  fnFormatTime(d.At),
-			ShowAt:    d.Data.StartUTC,
-			ShowAtS:   fnFormatTimeStamp(d.Data.StartUTC),
-			ExpireAt:  d.Data.EndUTC,
-			ExpireAtS: fnFormatTimeStamp(d.Data.EndUTC),
-			Text:      alarm.Text,
-			Value:     alarm.Value,
-			AValue:    aValue,
-			LeftTime:  utils.LeftTimeString(time.Unix(d.Data.EndUTC, 0)),
-		})
+		return
 	}
 
-	slices.SortFunc(aItems, func(a, b AlarmItem) int {
-		if a.ExpireAt < b.ExpireAt {
-			return -1
-		}
-
-		if a.ExpireAt > b.ExpireAt {
-			return 1
-		}
+	records, err = alarmHistory.List(mux.Vars(request)["id"], startAt, endAt)
+	if err != nil {
+		code = errorToCode(err)
+		msg = err.Error()
 
-		return 0
-	})
+		return
+	}
 
 	code = CodeSuccess
 
 	return
 }
 
-func handleAddTask(request *http.Request, taskManager timeassist.TaskManager) (code Code, msg string) {
-	var task timeassist.Task
+// handleCalendar days: 无法用 RRULE 表达的提醒展开的天数
+func handleCalendar(request *http.Request, alarmManager timeassist.AlarmManager, taskManager timeassist.TaskManager,
+	holidayCalendar timeassist.HolidayCalendar) (
+	d []byte, code Code, msg string) {
+	days := timeassist.DefaultCalendarExportDays
 
-	err := json.NewDecoder(request.Body).Decode(&task)
+	if s := request.URL.Query().Get("days"); s != "" {
+		var err error
+
+		days, err = strconv.Atoi(s)
+		if err != nil || days <= 0 {
+			code = CodeErrBadRequest
+			msg = "invalid days"
+
+			return
+		}
+	}
+
+	alarms, err := alarmManager.List()
 	if err != nil {
-		code = CodeErrParse
+		code = CodeErrInternal
 		msg = err.Error()
 
 		return
 	}
 
-	err = taskManager.Add(&task)
+	tasks, err := taskManager.List()
 	if err != nil {
-		code = CodeErrParse
+		code = CodeErrInternal
+		msg = err.Error()
+
+		return
+	}
+
+	var buf bytes.Buffer
+
+	err = timeassist.ExportCalendar(&buf, alarms, tasks, time.Now(), time.Duration(days)*24*time.Hour, holidayCalendar)
+	if err != nil {
+		code = CodeErrInternal
 		msg = err.Error()
 
 		return
 	}
 
+	d = buf.Bytes()
 	code = CodeSuccess
 
 	return
 }
 
-func handleTaskDone(request *http.Request, taskList timeassist.ShowList, taskManager timeassist.TaskManager) (code Code, msg string) {
-	taskID := mux.Vars(request)["task_id"]
+// handleImportCalendar body 为 .ics 文件内容, 每个 VEVENT 返回一个导入结果
+func handleImportCalendar(request *http.Request, alarmManager timeassist.AlarmManager) (
+	results []*timeassist.CalendarImportResult, code Code, msg string) {
+	results, err := timeassist.ImportCalendar(request.Body, alarmManager, time.Now())
+	if err != nil {
+		code = CodeErrBadRequest
+		msg = err.Error()
 
-	if timeassist.ParsePreOnID(taskID) == timeassist.TaskIDPre {
-		taskManager.TaskDone(taskID)
+		return
 	}
 
-	_ = taskList.Remove(taskID)
+	if results == nil {
+		results = make([]*timeassist.CalendarImportResult, 0)
+	}
 
 	code = CodeSuccess
 
 	return
 }
 
-func handleGetRTasks(_ *http.Request, t timeassist.TaskTimer, storage kv.StorageTiny) (aItems []AlarmItem, code Code, msg string) {
+func handleGetRTasks(request *http.Request, t timeassist.TaskTimer, storage kv.StorageTiny) (aItems []AlarmItem, code Code, msg string) {
 	items, err := t.List()
 	if err != nil {
 		code = CodeErrInternal
@@ -419,6 +1821,7 @@ func handleGetRTasks(_ *http.Request, t timeassist.TaskTimer, storage kv.Storage
 	}
 
 	aItems = make([]AlarmItem, 0, len(items))
+	lc := requestLocale(request)
 
 	fnFormatTime := func(t time.Time) string {
 		return t.Format("2006-01-02 15:04:05")
@@ -451,8 +1854,8 @@ func handleGetRTasks(_ *http.Request, t timeassist.TaskTimer, storage kv.Storage
 			ExpireAtS: fnFormatTimeStamp(d.Data.EndUTC),
 			Text:      task.Text,
 			Value:     "",
-			AValue:    task.Desc(),
-			LeftTime:  utils.LeftTimeString(time.Unix(d.Data.EndUTC, 0)),
+			AValue:    task.DescLocale(lc),
+			LeftTime:  utils.LeftTimeStringLocale(time.Unix(d.Data.EndUTC, 0), time.Now(), lc),
 		})
 	}
 
@@ -494,39 +1897,54 @@ const (
 	CodeErrsNotImplemented
 )
 
+// codeMessageKeys 返回码在 locale 消息目录中的 key
+var codeMessageKeys = map[Code]string{
+	CodeSuccess:            "code.success",
+	CodeErrUnauthenticated: "code.unauthenticated",
+	CodeErrBadRequest:      "code.bad_request",
+	CodeErrAuth:            "code.auth",
+	CodeErrParse:           "code.parse",
+	CodeErrInternal:        "code.internal",
+	CodeErrPermission:      "code.permission",
+	CodeErrNotFound:        "code.not_found",
+	CodeErrBanned:          "code.banned",
+	CodeErrDisabled:        "code.disabled",
+	CodeErrUserExists:      "code.user_exists",
+	CodeErrsNotImplemented: "code.not_implemented",
+}
+
 func (c Code) String() string {
-	switch c {
-	case CodeSuccess:
-		return "成功"
-	case CodeErrUnauthenticated:
-		return "需要授权"
-	case CodeErrBadRequest:
-		return "缺少参数"
-	case CodeErrAuth:
-		return "非法凭证"
-	case CodeErrParse:
-		return "传输错误"
-	case CodeErrInternal:
-		return "服务器内部错误"
-	case CodeErrPermission:
-		return "没有对应权限"
-	case CodeErrNotFound:
-		return "指定对象不存在"
-	case CodeErrBanned:
-		return "用户被限制"
-	case CodeErrDisabled:
-		return "操作被禁止"
-	case CodeErrUserExists:
-		return "用户已经存在"
-	case CodeErrsNotImplemented:
-		return "未实现"
-	}
-
-	return fmt.Sprintf("未知错误%d", c)
+	return c.StringLocale(locale.Default)
+}
+
+func (c Code) StringLocale(lc locale.Locale) string {
+	if key, ok := codeMessageKeys[c]; ok {
+		return lc.T(key)
+	}
+
+	return lc.T("code.unknown", c)
+}
+
+func errorToCode(err error) Code {
+	switch {
+	case errors.Is(err, commerr.ErrNotFound):
+		return CodeErrNotFound
+	case errors.Is(err, commerr.ErrResourceExhausted):
+		return CodeErrDisabled
+	case errors.Is(err, commerr.ErrInvalidArgument), errors.Is(err, commerr.ErrBadFormat), errors.Is(err, os.ErrInvalid),
+		errors.Is(err, commerr.ErrOutOfRange):
+		return CodeErrBadRequest
+	}
+
+	return CodeErrInternal
 }
 
 func CodeToMessage(code Code, msg string) string {
-	codeMsg := code.String()
+	return CodeToMessageLocale(code, msg, locale.Default)
+}
+
+func CodeToMessageLocale(code Code, msg string, lc locale.Locale) string {
+	codeMsg := code.StringLocale(lc)
 
 	if msg != "" {
 		codeMsg += ":" + msg
@@ -539,53 +1957,84 @@ type ResponseWrapper struct {
 	Code    Code        `json:"code"`
 	Message string      `json:"message"`
 	Resp    interface{} `json:"resp,omitempty"`
+
+	msg string // Apply 时的详细信息, httpResp 按请求的语言重新生成 Message
 }
 
 func (wr *ResponseWrapper) Apply(code Code, msg string) bool {
 	wr.Code = code
 	wr.Message = CodeToMessage(code, msg)
+	wr.msg = msg
 
 	return code == CodeSuccess
 }
 
-func notifyAlarm(logger l.Wrapper, notifyURL string, task *timeassist.ShowInfo) {
-	var text string
+// notifyAlarm 按 Alarm/Task 的路由和模板生成通知, 模板出错时退回默认模板;
+// Text 使用路由的语言, 后端配置的其他语言放在 Texts 中
+func notifyAlarm(logger l.Wrapper, outbox notify.Outbox, renderer *notify.Renderer, metaStorage kv.StorageTiny,
+	task *timeassist.ShowInfo, defaultLocale locale.Locale, backendLocales []locale.Locale) {
+	route, err := timeassist.GetNotifyRoute(metaStorage, task)
+	if err != nil {
+		logger.WithFields(l.ErrorField(err), l.StringField("id", task.ID)).Error("get notify route failed")
+	}
+
+	var tmpl string
+
+	lc := defaultLocale
+
+	if route != nil {
+		tmpl = route.Template
+		lc = locale.ParseOr(route.Locale, defaultLocale)
+	}
+
+	timeNow := time.Now()
+
+	msg := &notify.Message{
+		NotifyID: task.NotifyID,
+		Text:     renderNotify(logger, renderer, tmpl, task, timeNow, lc),
+		Locale:   lc,
+		Show:     task,
+		Route:    route,
+		Urgent:   timeassist.IsUrgentNotify(metaStorage, task.ID),
+	}
 
-	if task.VOTaskType == timeassist.VOTaskTypeAlarm {
-		text = fmt.Sprintf("闹钟: %s %s - %s", task.Value, task.SubTitle, task.AlarmAt.Format("2006-01-02 15:04:05"))
-		if task.AlarmFlag {
-			text += " 已经过期"
+	for _, backendLocale := range backendLocales {
+		if backendLocale == lc {
+			continue
 		}
-	} else {
-		text = fmt.Sprintf("任务: %s %s", task.Value, task.SubTitle)
-		if task.AlarmFlag {
-			text += " 已经过期"
+
+		if msg.Texts == nil {
+			msg.Texts = make(map[locale.Locale]string)
 		}
+
+		msg.Texts[backendLocale] = renderNotify(logger, renderer, tmpl, task, timeNow, backendLocale)
 	}
 
-	doNotify(logger, notifyURL, text)
+	doNotify(logger, outbox, msg)
 }
 
-func doNotify(logger l.Wrapper, notifyURL, text string) {
-	go func() {
-		fnSend := func(receiverType model.ReceiverType, text string) {
-			code, errMsg := pkg.SendTextMessage(notifyURL, &model.TextMessage{
-				SendMessageTarget: model.SendMessageTarget{
-					SenderBy: model.SenderByAll,
-					BizCode:  "z",
-					ToType:   receiverType,
-					FindOpts: 0,
-				},
-				Text: text,
-			})
-			if code != ptl.CodeSuccess {
-				logger.WithFields(l.StringField("errMsg", errMsg),
-					l.IntField("receiverType", int(receiverType)), l.StringField("text", text)).
-					Info("send failed")
-			}
-		}
+func renderNotify(logger l.Wrapper, renderer *notify.Renderer, tmpl string, task *timeassist.ShowInfo,
+	timeNow time.Time, lc locale.Locale) string {
+	text, err := renderer.RenderLocale(tmpl, task, timeNow, lc)
+	if err != nil && tmpl != "" {
+		logger.WithFields(l.ErrorField(err), l.StringField("id", task.ID)).Error("render notify template failed")
+
+		text, err = renderer.RenderLocale("", task, timeNow, lc)
+	}
+
+	if err != nil {
+		logger.WithFields(l.ErrorField(err), l.StringField("id", task.ID)).Error("render default notify template failed")
+
+		text = task.Value
+	}
+
+	return text
+}
 
-		fnSend(model.ReceiverTypeAdminUsers, text)
-		fnSend(model.ReceiverTypeAdminGroups, text)
-	}()
+// doNotify 放入 outbox 后由其负责重试, 这里只记录入队失败
+func doNotify(logger l.Wrapper, outbox notify.Outbox, msg *notify.Message) {
+	if err := outbox.Enqueue(msg); err != nil {
+		logger.WithFields(l.ErrorField(err), l.StringField("notifyID", msg.NotifyID),
+			l.StringField("text", msg.Text)).Error("enqueue notify failed")
+	}
 }
@@ -128,3 +128,64 @@ func tryImportAlarmConfigs(file string, alarmManager timeassist.AlarmManager, lo
 		return
 	}
 }
+
+func TryImportCalendars(root string, fileSuffix string, alarmManager timeassist.AlarmManager, logger l.Wrapper) {
+	logger.Debug("TryImportCalendars root:", root)
+
+	_ = filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		if !strings.HasSuffix(path, fileSuffix) {
+			return nil
+		}
+
+		tryImportCalendar(path, alarmManager, logger)
+
+		return nil
+	})
+}
+
+func tryImportCalendar(file string, alarmManager timeassist.AlarmManager, logger l.Wrapper) {
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+
+	results, err := timeassist.ImportCalendar(f, alarmManager, time.Now())
+
+	_ = f.Close()
+
+	if err != nil {
+		logger.WithFields(l.ErrorField(err), l.StringField("file", file)).Error("invalid calendar file format")
+
+		return
+	}
+
+	for _, result := range results {
+		fields := []l.Field{
+			l.StringField("uid", result.UID),
+			l.StringField("id", result.AlarmID),
+			l.StringField("summary", result.Summary),
+			l.StringField("status", string(result.Status)),
+		}
+
+		if result.Status == timeassist.CalendarImportFailed {
+			logger.WithFields(append(fields, l.StringField("message", result.Message))...).Error("try import calendar event failed")
+		} else {
+			logger.WithFields(fields...).Info("try import calendar event")
+		}
+	}
+
+	err = os.Rename(file, file+"."+strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		logger.WithFields(l.ErrorField(err)).Error("rename failed")
+
+		return
+	}
+}
@@ -0,0 +1,115 @@
+package locale
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale BCP 47 语言标签, 目前支持 zh-CN 和 en-US
+type Locale string
+
+const (
+	ZhCN Locale = "zh-CN"
+	EnUS Locale = "en-US"
+
+	Default = ZhCN
+)
+
+var Supported = []Locale{ZhCN, EnUS}
+
+// Parse 不区分大小写, zh/zh-Hans/zh_CN 等按语言匹配
+func Parse(s string) (Locale, bool) {
+	s = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), "_", "-"))
+	if s == "" {
+		return "", false
+	}
+
+	for _, lc := range Supported {
+		if strings.ToLower(string(lc)) == s {
+			return lc, true
+		}
+	}
+
+	lang, _, _ := strings.Cut(s, "-")
+
+	for _, lc := range Supported {
+		if l, _, _ := strings.Cut(strings.ToLower(string(lc)), "-"); l == lang {
+			return lc, true
+		}
+	}
+
+	return "", false
+}
+
+// ParseOr 无法识别时返回 def
+func ParseOr(s string, def Locale) Locale {
+	if lc, ok := Parse(s); ok {
+		return lc
+	}
+
+	return def
+}
+
+// FromAcceptLanguage 按 q 值选第一个支持的语言, 如 "en-US,en;q=0.9,zh-CN;q=0.8"
+func FromAcceptLanguage(header string, def Locale) Locale {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+
+	var candidates []candidate
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		q := 1.0
+
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if f, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+				q = f
+			}
+		}
+
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	for _, c := range candidates {
+		if lc, ok := Parse(c.tag); ok {
+			return lc
+		}
+	}
+
+	return def
+}
+
+func (lc Locale) catalog() map[string]string {
+	if c, ok := catalogs[lc]; ok {
+		return c
+	}
+
+	return catalogs[Default]
+}
+
+// T 按 key 取消息并格式化, 当前语言没有时使用默认语言, 都没有时返回 key
+func (lc Locale) T(key string, args ...interface{}) string {
+	format, ok := lc.catalog()[key]
+	if !ok {
+		if format, ok = catalogs[Default][key]; !ok {
+			format = key
+		}
+	}
+
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
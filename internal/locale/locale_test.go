@@ -0,0 +1,56 @@
+package locale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for s, want := range map[string]Locale{
+		"zh-CN":   ZhCN,
+		"zh_cn":   ZhCN,
+		"zh-Hans": ZhCN,
+		"zh":      ZhCN,
+		"en-US":   EnUS,
+		"EN-gb":   EnUS,
+		"en":      EnUS,
+	} {
+		lc, ok := Parse(s)
+		assert.True(t, ok, s)
+		assert.Equal(t, want, lc, s)
+	}
+
+	_, ok := Parse("fr-FR")
+	assert.False(t, ok)
+
+	_, ok = Parse("")
+	assert.False(t, ok)
+
+	assert.Equal(t, EnUS, ParseOr("ja", EnUS))
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	assert.Equal(t, EnUS, FromAcceptLanguage("en-US,en;q=0.9,zh-CN;q=0.8", ZhCN))
+	assert.Equal(t, ZhCN, FromAcceptLanguage("fr;q=0.9, zh-TW;q=0.5, en;q=0.1", EnUS))
+	assert.Equal(t, ZhCN, FromAcceptLanguage("en;q=0.1, zh;q=0.5", EnUS))
+	assert.Equal(t, EnUS, FromAcceptLanguage("fr, de;q=0.5", EnUS))
+	assert.Equal(t, EnUS, FromAcceptLanguage("", EnUS))
+	assert.Equal(t, ZhCN, FromAcceptLanguage("en;q=0, zh", EnUS))
+}
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "已经过期", ZhCN.T("left.expired"))
+	assert.Equal(t, "expired", EnUS.T("left.expired"))
+	assert.Equal(t, "1h 5m", EnUS.T("left.hour_minute", 1, 5))
+	assert.Equal(t, "成功", Locale("ja-JP").T("code.success"))
+	assert.Equal(t, "no.such.key", EnUS.T("no.such.key"))
+
+	// 每种语言的 key 都应该相同
+	for lc, catalog := range catalogs {
+		for key := range catalogs[Default] {
+			_, ok := catalog[key]
+			assert.True(t, ok, "%s: %s", lc, key)
+		}
+	}
+}
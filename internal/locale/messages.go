@@ -0,0 +1,175 @@
+package locale
+
+// catalogs 消息目录, zh-CN 与之前写死的文字一致
+var catalogs = map[Locale]map[string]string{
+	ZhCN: {
+		// 剩余时间
+		"left.expired":       "已经过期",
+		"left.month_day":     "约%d月%d天",
+		"left.day_hour":      "%d天%d小时",
+		"left.hour_minute":   "%d小时%d分",
+		"left.minute_second": "%d分%d秒",
+		"left.second":        "%d秒",
+
+		// AlarmValue
+		"alarm.day":            "%02d日",
+		"alarm.day_last":       "%02d日(最后一天)",
+		"alarm.day_last2":      "%02d日(倒数第二天)",
+		"alarm.day_last3":      "%02d日(倒数第三天)",
+		"alarm.once":           "%04d年%02d月%s%02d时%02d分%02d秒",
+		"alarm.once_lunar":     "阴历%04d年%02d月%s%02d时%02d分%02d秒",
+		"alarm.solar":          "阳历",
+		"alarm.lunar":          "阴历",
+		"alarm.year":           "%s每年%02d月%s%02d时%02d分%02d秒",
+		"alarm.month":          "%s每月%s%02d时%02d分%02d秒",
+		"alarm.week":           "每周周%s%02d时%02d分%02d秒",
+		"alarm.day_time":       "每日%02d时%02d分%02d秒",
+		"alarm.hour":           "每小时%02d分%02d秒",
+		"alarm.minute":         "每分%02d秒",
+		"alarm.cron":           "定时[%s]",
+		"alarm.rrule":          "重复[%s]",
+		"alarm.at_layout":      "[2006年01月02日15时04分05秒]",
+		"alarm.expired_suffix": " - 过期",
+
+		"week.0": "周日",
+		"week.1": "周1",
+		"week.2": "周2",
+		"week.3": "周3",
+		"week.4": "周4",
+		"week.5": "周5",
+		"week.6": "周6",
+
+		// Task
+		"task.once":          "单次",
+		"task.once_subtitle": "单次任务",
+		"task.lunar":         "阴历",
+		"task.auto":          "自动过期刷新",
+		"task.year":          "%d %s年一次 %s",
+		"task.month":         "%d %s月一次 %s",
+		"task.week":          "%d 周一次 %s",
+		"task.day":           "%d 天一次 %s",
+		"task.hour":          "%d 小时一次 %s",
+		"task.minute":        "%d 分钟一次 %s",
+		"task.unknown":       "unknown type: %d",
+		"task.layout.minute": "15时04分",
+		"task.layout.hour":   "02号15时",
+		"task.layout.day":    "01月02号",
+		"task.layout.week":   "01月02号",
+		"task.layout.month":  "2006年01月02号",
+		"task.layout.year":   "2006年01月02号",
+
+		// 预览, preview.lunar 参数为 干支年 月 日 的中文, 年 月 日 的数字和时间
+		"preview.solar":  "2006年01月02日15时04分05秒",
+		"preview.time":   "15时04分05秒",
+		"preview.lunar":  "%[1]s年%[2]s月%[3]s %[7]s",
+		"preview.week.0": "周日",
+		"preview.week.1": "周一",
+		"preview.week.2": "周二",
+		"preview.week.3": "周三",
+		"preview.week.4": "周四",
+		"preview.week.5": "周五",
+		"preview.week.6": "周六",
+
+		// 通知模板中的农历日期, 参数为 月 日 的中文和数字
+		"notify.lunar": "%[1]s月%[2]s",
+
+		// 勿扰时段结束后的摘要标题, 参数为通知条数
+		"notify.digest": "勿扰期间的 %d 条通知:",
+
+		// 接口返回码
+		"code.success":         "成功",
+		"code.unauthenticated": "需要授权",
+		"code.bad_request":     "缺少参数",
+		"code.auth":            "非法凭证",
+		"code.parse":           "传输错误",
+		"code.internal":        "服务器内部错误",
+		"code.permission":      "没有对应权限",
+		"code.not_found":       "指定对象不存在",
+		"code.banned":          "用户被限制",
+		"code.disabled":        "操作被禁止",
+		"code.user_exists":     "用户已经存在",
+		"code.not_implemented": "未实现",
+		"code.unknown":         "未知错误%d",
+	},
+	EnUS: {
+		"left.expired":       "expired",
+		"left.month_day":     "about %dmo %dd",
+		"left.day_hour":      "%dd %dh",
+		"left.hour_minute":   "%dh %dm",
+		"left.minute_second": "%dm %ds",
+		"left.second":        "%ds",
+
+		"alarm.day":            "%02d",
+		"alarm.day_last":       "%02d (last day)",
+		"alarm.day_last2":      "%02d (second to last day)",
+		"alarm.day_last3":      "%02d (third to last day)",
+		"alarm.once":           "%04d-%02d-%s %02d:%02d:%02d",
+		"alarm.once_lunar":     "lunar %04d-%02d-%s %02d:%02d:%02d",
+		"alarm.solar":          "",
+		"alarm.lunar":          "lunar ",
+		"alarm.year":           "%severy year on %02d-%s at %02d:%02d:%02d",
+		"alarm.month":          "%severy month on day %s at %02d:%02d:%02d",
+		"alarm.week":           "every %s at %02d:%02d:%02d",
+		"alarm.day_time":       "every day at %02d:%02d:%02d",
+		"alarm.hour":           "every hour at %02d:%02d",
+		"alarm.minute":         "every minute at second %02d",
+		"alarm.cron":           "cron [%s]",
+		"alarm.rrule":          "repeat [%s]",
+		"alarm.at_layout":      "[2006-01-02 15:04:05]",
+		"alarm.expired_suffix": " - expired",
+
+		"week.0": "Sunday",
+		"week.1": "Monday",
+		"week.2": "Tuesday",
+		"week.3": "Wednesday",
+		"week.4": "Thursday",
+		"week.5": "Friday",
+		"week.6": "Saturday",
+
+		"task.once":          "once",
+		"task.once_subtitle": "one-time task",
+		"task.lunar":         "lunar ",
+		"task.auto":          "auto refresh",
+		"task.year":          "every %d %syear(s) %s",
+		"task.month":         "every %d %smonth(s) %s",
+		"task.week":          "every %d week(s) %s",
+		"task.day":           "every %d day(s) %s",
+		"task.hour":          "every %d hour(s) %s",
+		"task.minute":        "every %d minute(s) %s",
+		"task.unknown":       "unknown type: %d",
+		"task.layout.minute": "15:04",
+		"task.layout.hour":   "01/02 15h",
+		"task.layout.day":    "01/02",
+		"task.layout.week":   "01/02",
+		"task.layout.month":  "2006/01/02",
+		"task.layout.year":   "2006/01/02",
+
+		"preview.solar":  "2006-01-02 15:04:05",
+		"preview.time":   "15:04:05",
+		"preview.lunar":  "lunar %[4]d-%02[5]d-%02[6]d %[7]s",
+		"preview.week.0": "Sun",
+		"preview.week.1": "Mon",
+		"preview.week.2": "Tue",
+		"preview.week.3": "Wed",
+		"preview.week.4": "Thu",
+		"preview.week.5": "Fri",
+		"preview.week.6": "Sat",
+
+		"notify.lunar":  "lunar %[3]d/%[4]d",
+		"notify.digest": "%d notifications during quiet hours:",
+
+		"code.success":         "success",
+		"code.unauthenticated": "unauthenticated",
+		"code.bad_request":     "bad request",
+		"code.auth":            "invalid credentials",
+		"code.parse":           "parse error",
+		"code.internal":        "internal server error",
+		"code.permission":      "permission denied",
+		"code.not_found":       "not found",
+		"code.banned":          "user banned",
+		"code.disabled":        "operation disabled",
+		"code.user_exists":     "user already exists",
+		"code.not_implemented": "not implemented",
+		"code.unknown":         "unknown error %d",
+	},
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+// commandNotifier 执行本地命令, Message 的 JSON 从 stdin 传入, 文本, ID 和路由的 Group 同时放在环境变量中
+type commandNotifier struct {
+	name    string
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+func newCommandNotifier(cfg *Config) (Notifier, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("%w: command is empty", commerr.ErrInvalidArgument)
+	}
+
+	return &commandNotifier{
+		name:    cfg.name(),
+		command: cfg.Command,
+		args:    cfg.Args,
+		timeout: cfg.timeout(),
+	}, nil
+}
+
+func (impl *commandNotifier) Name() string {
+	return impl.name
+}
+
+func (impl *commandNotifier) Notify(ctx context.Context, msg *Message) error {
+	d, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, impl.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, impl.command, impl.args...)
+	cmd.Stdin = bytes.NewReader(d)
+	cmd.Env = append(os.Environ(),
+		"TIMEASSIST_NOTIFY_ID="+msg.NotifyID,
+		"TIMEASSIST_TEXT="+msg.Text,
+	)
+
+	if msg.Route != nil && msg.Route.Group != "" {
+		cmd.Env = append(cmd.Env, "TIMEASSIST_GROUP="+msg.Route.Group)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+	}
+
+	return nil
+}
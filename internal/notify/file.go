@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileNotifier 每条消息一行追加到文件, 主要用于测试和调试
+type fileNotifier struct {
+	name string
+	path string
+
+	lock sync.Mutex
+}
+
+func newFileNotifier(cfg *Config) (Notifier, error) {
+	return &fileNotifier{
+		name: cfg.name(),
+		path: cfg.Path,
+	}, nil
+}
+
+func (impl *fileNotifier) Name() string {
+	return impl.name
+}
+
+func (impl *fileNotifier) Notify(_ context.Context, msg *Message) (err error) {
+	at := msg.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	line := fmt.Sprintf("%s\t%s\t%s\n", at.Format("2006-01-02 15:04:05"), msg.NotifyID, msg.Text)
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	if impl.path == "" || impl.path == "-" {
+		_, err = io.WriteString(os.Stdout, line)
+
+		return
+	}
+
+	f, err := os.OpenFile(impl.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+
+	_, err = f.WriteString(line)
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	return
+}
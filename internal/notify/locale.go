@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/sgostarter/i/commerr"
+)
+
+// WrapLocale 后端配置了 Locale 时发送 Message.Texts 中对应语言的文本
+func WrapLocale(cfg *Config, n Notifier) (Notifier, error) {
+	if cfg.Locale == "" {
+		return n, nil
+	}
+
+	lc, ok := locale.Parse(cfg.Locale)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown locale %q", commerr.ErrInvalidArgument, cfg.Locale)
+	}
+
+	return &localeNotifier{
+		Notifier: n,
+		locale:   lc,
+	}, nil
+}
+
+// Locales 后端配置中用到的语言, 生成通知时按这些语言填充 Message.Texts
+func Locales(cfgs []Config) (lcs []locale.Locale) {
+	seen := make(map[locale.Locale]bool)
+
+	for idx := range cfgs {
+		lc, ok := locale.Parse(cfgs[idx].Locale)
+		if !ok || seen[lc] {
+			continue
+		}
+
+		seen[lc] = true
+
+		lcs = append(lcs, lc)
+	}
+
+	return
+}
+
+type localeNotifier struct {
+	Notifier
+	locale locale.Locale
+}
+
+func (n *localeNotifier) Notify(ctx context.Context, msg *Message) error {
+	if text, ok := msg.Texts[n.locale]; ok && text != msg.Text {
+		localized := *msg
+		localized.Text = text
+
+		msg = &localized
+	}
+
+	return n.Notifier.Notify(ctx, msg)
+}
@@ -0,0 +1,134 @@
+package notifiershare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/s-min-sys/notifier-share/pkg"
+	"github.com/s-min-sys/notifier-share/pkg/model"
+	"github.com/s-min-sys/timeassistbe/internal/notify"
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/libeasygo/ptl"
+)
+
+const (
+	Type = "notifier-share"
+
+	defaultBizCode = "z"
+)
+
+var receiverTypes = map[string]model.ReceiverType{
+	"admin_users":  model.ReceiverTypeAdminUsers,
+	"admin_groups": model.ReceiverTypeAdminGroups,
+	"users":        model.ReceiverTypeUsers,
+	"groups":       model.ReceiverTypeGroups,
+}
+
+func init() {
+	notify.Register(Type, New)
+}
+
+// New Receivers 为空时发给管理员用户和管理员群, BizCode 默认为 z
+func New(cfg *notify.Config) (n notify.Notifier, err error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("%w: notifier-share URL is empty", commerr.ErrInvalidArgument)
+	}
+
+	impl := &notifierImpl{
+		name:    cfg.Name,
+		url:     cfg.URL,
+		bizCode: cfg.BizCode,
+	}
+
+	if impl.name == "" {
+		impl.name = Type
+	}
+
+	if impl.bizCode == "" {
+		impl.bizCode = defaultBizCode
+	}
+
+	receivers := cfg.Receivers
+	if len(receivers) == 0 {
+		receivers = []string{"admin_users", "admin_groups"}
+	}
+
+	impl.receiverTypes, err = parseReceivers(receivers)
+	if err != nil {
+		return nil, err
+	}
+
+	return impl, nil
+}
+
+func parseReceivers(receivers []string) (types []model.ReceiverType, err error) {
+	for _, receiver := range receivers {
+		receiverType, ok := receiverTypes[strings.ToLower(receiver)]
+		if !ok {
+			err = fmt.Errorf("%w: unknown receiver %q", commerr.ErrInvalidArgument, receiver)
+
+			return
+		}
+
+		types = append(types, receiverType)
+	}
+
+	return
+}
+
+type notifierImpl struct {
+	name          string
+	url           string
+	bizCode       string
+	receiverTypes []model.ReceiverType
+}
+
+func (impl *notifierImpl) Name() string {
+	return impl.name
+}
+
+// Notify 路由中的 Receivers/BizCode 覆盖配置; notifier-share 不能按群名发送, 忽略 Group
+func (impl *notifierImpl) Notify(_ context.Context, msg *notify.Message) error {
+	bizCode := impl.bizCode
+	toTypes := impl.receiverTypes
+
+	if msg.Route != nil {
+		if msg.Route.BizCode != "" {
+			bizCode = msg.Route.BizCode
+		}
+
+		if len(msg.Route.Receivers) > 0 {
+			types, err := parseReceivers(msg.Route.Receivers)
+			if err != nil {
+				return err
+			}
+
+			toTypes = types
+		}
+	}
+
+	var errMsgs []string
+
+	for _, receiverType := range toTypes {
+		code, errMsg := pkg.SendTextMessage(impl.url, &model.TextMessage{
+			SendMessageTarget: model.SendMessageTarget{
+				SenderBy: model.SenderByAll,
+				BizCode:  bizCode,
+				ToType:   receiverType,
+				FindOpts: 0,
+			},
+			Text: msg.Text,
+		})
+		if code != ptl.CodeSuccess {
+			errMsgs = append(errMsgs, fmt.Sprintf("receiver type %d: %d %s", receiverType, code, errMsg))
+		}
+	}
+
+	if len(errMsgs) > 0 {
+		return errors.New(strings.Join(errMsgs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,284 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	"github.com/sgostarter/i/commerr"
+)
+
+const (
+	TypeWebhook = "webhook"
+	TypeSMTP    = "smtp"
+	TypeCommand = "command"
+	TypeFile    = "file"
+
+	defaultTimeout = 10 * time.Second
+)
+
+type Message struct {
+	NotifyID string               `yaml:"NotifyID,omitempty" json:"notify_id,omitempty"`
+	Text     string               `yaml:"Text" json:"text"`
+	Show     *timeassist.ShowInfo `yaml:"Show,omitempty" json:"show,omitempty"` // 系统消息时为空
+	At       time.Time            `yaml:"At" json:"at"`
+
+	// Texts 其他语言的 Text, 配置了 Locale 的后端从中选择 @see WrapLocale
+	Texts map[locale.Locale]string `yaml:"Texts,omitempty" json:"texts,omitempty"`
+	// Locale Text 的语言, 为空时为默认语言
+	Locale locale.Locale `yaml:"Locale,omitempty" json:"locale,omitempty"`
+
+	Route *timeassist.NotifyRoute `yaml:"Route,omitempty" json:"route,omitempty"` // 为空时使用后端自己的配置
+
+	Urgent bool `yaml:"Urgent,omitempty" json:"urgent,omitempty"` // 不受勿扰时段限制
+	Digest bool `yaml:"Digest,omitempty" json:"digest,omitempty"` // 勿扰时段结束后的摘要
+}
+
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, msg *Message) error
+}
+
+// BackendNotifier 多个后端时分别返回结果, Outbox 重试时跳过已经发送成功的后端
+type BackendNotifier interface {
+	Notifier
+	NotifyBackends(ctx context.Context, msg *Message, skip map[string]bool) (delivered []string, err error)
+}
+
+// Config 所有后端共用一个结构, 按 Type 取需要的字段
+type Config struct {
+	Type           string `yaml:"Type"`
+	Name           string `yaml:"Name"` // 同类型有多个时用于区分, 默认为 Type
+	TimeoutSeconds int    `yaml:"TimeoutSeconds"`
+
+	// notifier-share, webhook
+	URL       string            `yaml:"URL"`
+	BizCode   string            `yaml:"BizCode"`
+	Receivers []string          `yaml:"Receivers"`
+	Headers   map[string]string `yaml:"Headers"`
+
+	// smtp
+	Host     string   `yaml:"Host"`
+	Port     int      `yaml:"Port"`
+	Username string   `yaml:"Username"`
+	Password string   `yaml:"Password"`
+	From     string   `yaml:"From"`
+	To       []string `yaml:"To"`
+	Subject  string   `yaml:"Subject"`
+
+	// command
+	Command string   `yaml:"Command"`
+	Args    []string `yaml:"Args"`
+
+	// file, 为空或 - 时输出到 stdout
+	Path string `yaml:"Path"`
+
+	// 这个后端的勿扰时段, 为空时使用全局配置
+	QuietHours []QuietWindow `yaml:"QuietHours"`
+
+	// 接收者使用的语言, 如 en-US, 为空时使用通知默认的语言
+	Locale string `yaml:"Locale"`
+}
+
+func (cfg *Config) name() string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+
+	return cfg.Type
+}
+
+func (cfg *Config) timeout() time.Duration {
+	if cfg.TimeoutSeconds > 0 {
+		return time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+
+	return defaultTimeout
+}
+
+type Factory func(cfg *Config) (Notifier, error)
+
+// Wrapper 在创建后端后附加功能, 如勿扰时段
+type Wrapper func(cfg *Config, n Notifier) (Notifier, error)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = map[string]Factory{
+		TypeWebhook: newWebhookNotifier,
+		TypeSMTP:    newSMTPNotifier,
+		TypeCommand: newCommandNotifier,
+		TypeFile:    newFileNotifier,
+	}
+)
+
+// Register 依赖外部模块的后端(如 notifier-share)在自己的包里注册
+func Register(notifierType string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	factories[notifierType] = factory
+}
+
+func NewNotifier(cfg *Config) (Notifier, error) {
+	factoriesLock.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown notifier type %q", commerr.ErrInvalidArgument, cfg.Type)
+	}
+
+	return factory(cfg)
+}
+
+// NewNotifiers 多个后端同时发送, 任一配置错误都返回错误
+func NewNotifiers(cfgs []Config, wrappers ...Wrapper) (Notifier, error) {
+	notifiers := make([]Notifier, 0, len(cfgs))
+
+	for idx := range cfgs {
+		n, err := NewNotifier(&cfgs[idx])
+
+		for _, wrapper := range wrappers {
+			if err != nil {
+				break
+			}
+
+			n, err = wrapper(&cfgs[idx], n)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d(%s): %w", idx, cfgs[idx].name(), err)
+		}
+
+		notifiers = append(notifiers, n)
+	}
+
+	return NewMulti(notifiers...), nil
+}
+
+func NewMulti(notifiers ...Notifier) Notifier {
+	return &multiNotifier{
+		notifiers: notifiers,
+	}
+}
+
+type multiNotifier struct {
+	notifiers []Notifier
+}
+
+func (impl *multiNotifier) Name() string {
+	names := make([]string, 0, len(impl.notifiers))
+	for _, n := range impl.notifiers {
+		names = append(names, n.Name())
+	}
+
+	return strings.Join(names, ",")
+}
+
+// route 指定了 Backends 时只选择这些后端
+func (impl *multiNotifier) route(msg *Message) (notifiers []Notifier, err error) {
+	if msg.Route == nil || len(msg.Route.Backends) == 0 {
+		return impl.notifiers, nil
+	}
+
+	for _, n := range impl.notifiers {
+		for _, name := range msg.Route.Backends {
+			if n.Name() == name {
+				notifiers = append(notifiers, n)
+
+				break
+			}
+		}
+	}
+
+	if len(notifiers) == 0 {
+		err = fmt.Errorf("%w: no notifier named %s", commerr.ErrNotFound, strings.Join(msg.Route.Backends, ","))
+	}
+
+	return
+}
+
+// Notify 并发发送, 返回所有失败的后端
+func (impl *multiNotifier) Notify(ctx context.Context, msg *Message) error {
+	_, err := impl.NotifyBackends(ctx, msg, nil)
+
+	return err
+}
+
+// NotifyBackends 跳过 skip 中的后端, delivered 为本次发送成功的后端
+func (impl *multiNotifier) NotifyBackends(ctx context.Context, msg *Message, skip map[string]bool) (delivered []string, err error) {
+	notifiers, err := impl.route(msg)
+	if err != nil {
+		return
+	}
+
+	errs := make([]error, len(notifiers))
+	oks := make([]bool, len(notifiers))
+
+	var wg sync.WaitGroup
+
+	for idx, n := range notifiers {
+		if skip[n.Name()] {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(idx int, n Notifier) {
+			defer wg.Done()
+
+			if err := n.Notify(ctx, msg); err != nil {
+				errs[idx] = fmt.Errorf("%s: %w", n.Name(), err)
+			} else {
+				oks[idx] = true
+			}
+		}(idx, n)
+	}
+
+	wg.Wait()
+
+	for idx, n := range notifiers {
+		if oks[idx] {
+			delivered = append(delivered, n.Name())
+		}
+	}
+
+	err = joinErrors(errs)
+
+	return
+}
+
+type multiError []error
+
+func (errs multiError) Error() string {
+	ss := make([]string, 0, len(errs))
+	for _, err := range errs {
+		ss = append(ss, err.Error())
+	}
+
+	return strings.Join(ss, "; ")
+}
+
+func (errs multiError) Unwrap() error {
+	return errs[0]
+}
+
+func joinErrors(errs []error) error {
+	var result multiError
+
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
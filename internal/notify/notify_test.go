@@ -0,0 +1,302 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func utMessage() *Message {
+	return &Message{
+		NotifyID: "n1",
+		Text:     "闹钟: 喝水",
+		At:       time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestNewNotifiers(t *testing.T) {
+	_, err := NewNotifiers([]Config{{Type: "unknown"}})
+	assert.True(t, errors.Is(err, commerr.ErrInvalidArgument))
+
+	for _, cfg := range []Config{
+		{Type: TypeWebhook},
+		{Type: TypeSMTP, Host: "localhost"},
+		{Type: TypeCommand},
+	} {
+		_, err = NewNotifier(&cfg)
+		assert.NotNil(t, err, cfg.Type)
+	}
+
+	n, err := NewNotifiers([]Config{
+		{Type: TypeFile, Path: filepath.Join(t.TempDir(), "a.txt")},
+		{Type: TypeFile, Name: "b", Path: filepath.Join(t.TempDir(), "b.txt")},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "file,b", n.Name())
+}
+
+func TestMultiNotifier(t *testing.T) {
+	dir := t.TempDir()
+
+	var webhookMsg Message
+
+	var webhookAuth string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		webhookAuth = request.Header.Get("Authorization")
+
+		_ = json.NewDecoder(request.Body).Decode(&webhookMsg)
+	}))
+	defer srv.Close()
+
+	failSrv := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failSrv.Close()
+
+	n, err := NewNotifiers([]Config{
+		{Type: TypeFile, Path: filepath.Join(dir, "notify.txt")},
+		{Type: TypeWebhook, URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer x"}},
+		{Type: TypeCommand, Command: "sh", Args: []string{"-c", "cat > " + filepath.Join(dir, "stdin.json") +
+			"; echo \"$TIMEASSIST_NOTIFY_ID\" > " + filepath.Join(dir, "env.txt")}},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, n.Notify(context.Background(), utMessage()))
+
+	d, err := os.ReadFile(filepath.Join(dir, "notify.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-01-01 09:00:00\tn1\t闹钟: 喝水\n", string(d))
+
+	assert.Equal(t, "n1", webhookMsg.NotifyID)
+	assert.Equal(t, "Bearer x", webhookAuth)
+
+	d, err = os.ReadFile(filepath.Join(dir, "stdin.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(d), `"text":"闹钟: 喝水"`)
+
+	d, err = os.ReadFile(filepath.Join(dir, "env.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "n1\n", string(d))
+
+	// 一个失败不影响其它的
+	n, err = NewNotifiers([]Config{
+		{Type: TypeWebhook, Name: "bad", URL: failSrv.URL},
+		{Type: TypeCommand, Name: "exit", Command: "sh", Args: []string{"-c", "echo oops; exit 3"}},
+		{Type: TypeFile, Path: filepath.Join(dir, "notify.txt")},
+	})
+	assert.Nil(t, err)
+
+	err = n.Notify(context.Background(), utMessage())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "bad: webhook status 502")
+	assert.Contains(t, err.Error(), "exit: exit status 3: oops")
+
+	d, err = os.ReadFile(filepath.Join(dir, "notify.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, strings.Count(string(d), "\n"))
+}
+
+func TestWrapLocale(t *testing.T) {
+	_, err := NewNotifiers([]Config{{Type: TypeFile, Locale: "fr-FR"}}, WrapLocale)
+	assert.True(t, errors.Is(err, commerr.ErrInvalidArgument))
+
+	dir := t.TempDir()
+
+	cfgs := []Config{
+		{Type: TypeFile, Path: filepath.Join(dir, "zh.txt")},
+		{Type: TypeFile, Name: "en", Path: filepath.Join(dir, "en.txt"), Locale: "en"},
+		{Type: TypeFile, Name: "en2", Path: filepath.Join(dir, "en2.txt"), Locale: "en-US"},
+	}
+
+	assert.Equal(t, []locale.Locale{locale.EnUS}, Locales(cfgs))
+
+	n, err := NewNotifiers(cfgs, WrapLocale)
+	assert.Nil(t, err)
+
+	msg := utMessage()
+	msg.Texts = map[locale.Locale]string{locale.EnUS: "Alarm: drink water"}
+
+	assert.Nil(t, n.Notify(context.Background(), msg))
+
+	d, err := os.ReadFile(filepath.Join(dir, "zh.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-01-01 09:00:00\tn1\t闹钟: 喝水\n", string(d))
+
+	d, err = os.ReadFile(filepath.Join(dir, "en.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-01-01 09:00:00\tn1\tAlarm: drink water\n", string(d))
+
+	// 没有对应语言的文本时发送 Text
+	assert.Nil(t, n.Notify(context.Background(), utMessage()))
+
+	d, err = os.ReadFile(filepath.Join(dir, "en2.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-01-01 09:00:00\tn1\tAlarm: drink water\n2026-01-01 09:00:00\tn1\t闹钟: 喝水\n", string(d))
+	assert.Equal(t, "闹钟: 喝水", msg.Text)
+}
+
+func TestMultiNotifierRoute(t *testing.T) {
+	a := &utNotifier{}
+	b := &utNotifier{}
+
+	n := NewMulti(&utNamedNotifier{utNotifier: a, name: "a"}, &utNamedNotifier{utNotifier: b, name: "b"})
+
+	msg := utMessage()
+	msg.Route = &timeassist.NotifyRoute{Backends: []string{"b"}}
+
+	assert.Nil(t, n.Notify(context.Background(), msg))
+
+	callsA, _ := a.stat()
+	callsB, _ := b.stat()
+	assert.Equal(t, 0, callsA)
+	assert.Equal(t, 1, callsB)
+
+	msg.Route.Backends = []string{"c"}
+	assert.True(t, errors.Is(n.Notify(context.Background(), msg), commerr.ErrNotFound))
+
+	msg.Route = &timeassist.NotifyRoute{Group: "family"}
+	assert.Nil(t, n.Notify(context.Background(), msg))
+
+	callsA, _ = a.stat()
+	assert.Equal(t, 1, callsA)
+}
+
+type utNamedNotifier struct {
+	*utNotifier
+	name string
+}
+
+func (n *utNamedNotifier) Name() string {
+	return n.name
+}
+
+func TestSMTPBuildMail(t *testing.T) {
+	n, err := NewNotifier(&Config{Type: TypeSMTP, Host: "smtp.example.com", From: "a@example.com",
+		To: []string{"b@example.com", "c@example.com"}, Subject: "提醒"})
+	assert.Nil(t, err)
+
+	mail := string(n.(*smtpNotifier).buildMail(&Message{NotifyID: "n1", Text: "line1\nline2"}))
+
+	assert.Contains(t, mail, "To: b@example.com, c@example.com\r\n")
+	assert.Contains(t, mail, "Subject: =?UTF-8?b?5o+Q6YaS?=\r\n")
+	assert.Contains(t, mail, "Message-ID: <n1@timeassistbe>\r\n")
+	assert.True(t, strings.HasSuffix(mail, "\r\n\r\nline1\r\nline2\r\n"))
+	assert.Equal(t, "smtp.example.com:25", n.(*smtpNotifier).addr)
+}
+
+// utSMTPServer 最简单的 SMTP 服务, 不支持扩展, 收到的邮件正文写到 mails
+func utSMTPServer(t *testing.T, mails chan<- string) (host string, port int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		tc := textproto.NewConn(conn)
+
+		_ = tc.PrintfLine("220 ut")
+
+		for {
+			line, err := tc.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "DATA":
+				_ = tc.PrintfLine("354 go")
+
+				d, _ := tc.ReadDotBytes()
+				mails <- string(d)
+
+				_ = tc.PrintfLine("250 ok")
+			case "QUIT":
+				_ = tc.PrintfLine("221 bye")
+
+				return
+			default:
+				_ = tc.PrintfLine("250 ok")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+
+	return addr.IP.String(), addr.Port
+}
+
+func TestSMTPNotify(t *testing.T) {
+	mails := make(chan string, 1)
+	host, port := utSMTPServer(t, mails)
+
+	n, err := NewNotifier(&Config{Type: TypeSMTP, Host: host, Port: port, From: "a@example.com",
+		To: []string{"b@example.com"}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, n.Notify(context.Background(), utMessage()))
+	assert.Contains(t, <-mails, "闹钟: 喝水")
+}
+
+// 服务器不响应时超时返回并关闭连接, 不会在后台继续发送
+func TestSMTPNotifyTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+
+	defer ln.Close()
+
+	closed := make(chan struct{})
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		_, _ = io.Copy(io.Discard, conn)
+
+		close(closed)
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+
+	n, err := NewNotifier(&Config{Type: TypeSMTP, Host: addr.IP.String(), Port: addr.Port, From: "a@example.com",
+		To: []string{"b@example.com"}})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, n.Notify(ctx, utMessage()), context.DeadlineExceeded)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		assert.Fail(t, "smtp connection is still open")
+	}
+}
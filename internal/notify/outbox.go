@@ -0,0 +1,471 @@
+package notify
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/i/l"
+	"github.com/sgostarter/libeasygo/stg/kv"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending   OutboxStatus = "pending"
+	OutboxStatusDelivered OutboxStatus = "delivered"
+	OutboxStatusDead      OutboxStatus = "dead"
+)
+
+const (
+	DefaultOutboxMaxAttempts = 10
+	DefaultOutboxBaseDelay   = 30 * time.Second
+	DefaultOutboxMaxDelay    = time.Hour
+
+	// outboxDeliveredRetention 已发送的保留一段时间用于去重
+	outboxDeliveredRetention = 7 * 24 * time.Hour
+)
+
+type OutboxItem struct {
+	ID        string       `yaml:"ID" json:"id"` // 即 NotifyID, 没有时生成
+	Message   *Message     `yaml:"Message" json:"message"`
+	Status    OutboxStatus `yaml:"Status" json:"status"`
+	Attempts  int          `yaml:"Attempts,omitempty" json:"attempts"`
+	NextAt    time.Time    `yaml:"NextAt" json:"next_at"`
+	LastError string       `yaml:"LastError,omitempty" json:"last_error,omitempty"`
+	Delivered []string     `yaml:"Delivered,omitempty" json:"delivered,omitempty"` // 多个后端时已经发送成功的, 重试时跳过
+	CreatedAt time.Time    `yaml:"CreatedAt" json:"created_at"`
+	DoneAt    time.Time    `yaml:"DoneAt,omitempty" json:"done_at,omitempty"` // 发送成功或进入死信的时间
+}
+
+type OutboxOptions struct {
+	MaxAttempts int           // 超过后进入死信
+	BaseDelay   time.Duration // 第 n 次失败后等待 BaseDelay * 2^(n-1)
+	MaxDelay    time.Duration
+
+	OnAttempt func(msg *Message, err error) // 每次发送后调用, 不持有锁
+}
+
+// Outbox 持久化的发送队列, 至少发送一次: 发送中重启的会再发一次
+type Outbox interface {
+	Start()
+	Stop()
+	// Enqueue 相同 NotifyID 的消息已经在队列中或已经发送过时忽略
+	Enqueue(msg *Message) error
+	List(status OutboxStatus) ([]*OutboxItem, error)
+	// Retry 死信重新进入队列, id 为空时重试所有死信
+	Retry(id string) (n int, err error)
+	// Purge 删除死信, id 为空时删除所有死信
+	Purge(id string) (n int, err error)
+}
+
+func NewOutbox(fileName string, notifier Notifier, opts *OutboxOptions, logger l.Wrapper, clock timeassist.Clock) Outbox {
+	if logger == nil {
+		logger = l.NewNopLoggerWrapper()
+	}
+
+	storage, err := kv.NewMemoryFileStorageEx(fileName, false)
+	if err != nil {
+		logger.WithFields(l.ErrorField(err)).Fatal("open outbox storage failed")
+
+		return nil
+	}
+
+	if clock == nil {
+		clock = timeassist.NewRealClock()
+	}
+
+	impl := &outboxImpl{
+		logger:   logger.WithFields(l.StringField(l.ClsKey, "outboxImpl")),
+		storage:  storage,
+		notifier: notifier,
+		clock:    clock,
+		wakeCh:   make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+
+	if opts != nil {
+		impl.opts = *opts
+	}
+
+	impl.init()
+
+	return impl
+}
+
+type outboxImpl struct {
+	logger   l.Wrapper
+	storage  kv.StorageTiny
+	notifier Notifier
+	clock    timeassist.Clock
+	opts     OutboxOptions
+
+	// lock 保证读改写 OutboxItem 不被打断, 发送时不持有
+	lock sync.Mutex
+
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func (impl *outboxImpl) init() {
+	if impl.opts.MaxAttempts <= 0 {
+		impl.opts.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+
+	if impl.opts.BaseDelay <= 0 {
+		impl.opts.BaseDelay = DefaultOutboxBaseDelay
+	}
+
+	if impl.opts.MaxDelay <= 0 {
+		impl.opts.MaxDelay = DefaultOutboxMaxDelay
+	}
+}
+
+func (impl *outboxImpl) wakeup() {
+	select {
+	case impl.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (impl *outboxImpl) Start() {
+	impl.wg.Add(1)
+
+	go func() {
+		defer impl.wg.Done()
+
+		for {
+			nextAt, hasNext := impl.deliverDue()
+
+			var timerC <-chan time.Time
+
+			var timer timeassist.ClockTimer
+
+			if hasNext {
+				timer = impl.clock.NewTimer(nextAt.Sub(impl.clock.Now()))
+				timerC = timer.C()
+			}
+
+			select {
+			case <-timerC:
+			case <-impl.wakeCh:
+			case <-impl.stopCh:
+				if timer != nil {
+					timer.Stop()
+				}
+
+				return
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+func (impl *outboxImpl) Stop() {
+	impl.stopOnce.Do(func() {
+		close(impl.stopCh)
+	})
+
+	impl.wg.Wait()
+}
+
+func (impl *outboxImpl) Enqueue(msg *Message) (err error) {
+	if msg == nil {
+		return commerr.ErrInvalidArgument
+	}
+
+	timeNow := impl.clock.Now()
+
+	if msg.At.IsZero() {
+		msg.At = timeNow
+	}
+
+	id := msg.NotifyID
+	if id == "" {
+		id = uuid.NewV4().String()
+	}
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	var item OutboxItem
+
+	ok, err := impl.storage.Get(id, &item)
+	if err != nil {
+		return
+	}
+
+	if ok {
+		return
+	}
+
+	err = impl.storage.Set(id, &OutboxItem{
+		ID:        id,
+		Message:   msg,
+		Status:    OutboxStatusPending,
+		NextAt:    timeNow,
+		CreatedAt: timeNow,
+	})
+	if err != nil {
+		return
+	}
+
+	impl.wakeup()
+
+	return
+}
+
+func (impl *outboxImpl) listNoLock() (items []*OutboxItem, err error) {
+	ds, err := impl.storage.GetList(func(_ string) interface{} {
+		return &OutboxItem{}
+	})
+	if err != nil {
+		return
+	}
+
+	for _, d := range ds {
+		if item, ok := d.(*OutboxItem); ok {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	return
+}
+
+// List status 为空时返回全部
+func (impl *outboxImpl) List(status OutboxStatus) (items []*OutboxItem, err error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	all, err := impl.listNoLock()
+	if err != nil {
+		return
+	}
+
+	items = make([]*OutboxItem, 0, len(all))
+
+	for _, item := range all {
+		if status == "" || item.Status == status {
+			items = append(items, item)
+		}
+	}
+
+	return
+}
+
+func (impl *outboxImpl) Retry(id string) (n int, err error) {
+	n, err = impl.updateDead(id, func(item *OutboxItem) error {
+		item.Status = OutboxStatusPending
+		item.Attempts = 0
+		item.NextAt = impl.clock.Now()
+		item.DoneAt = time.Time{}
+
+		return impl.storage.Set(item.ID, item)
+	})
+
+	if n > 0 {
+		impl.wakeup()
+	}
+
+	return
+}
+
+func (impl *outboxImpl) Purge(id string) (n int, err error) {
+	return impl.updateDead(id, func(item *OutboxItem) error {
+		return impl.storage.Del(item.ID)
+	})
+}
+
+func (impl *outboxImpl) updateDead(id string, fn func(item *OutboxItem) error) (n int, err error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	var items []*OutboxItem
+
+	if id != "" {
+		item := &OutboxItem{}
+
+		ok, e := impl.storage.Get(id, item)
+		if e != nil {
+			err = e
+
+			return
+		}
+
+		if !ok || item.Status != OutboxStatusDead {
+			err = commerr.ErrNotFound
+
+			return
+		}
+
+		items = append(items, item)
+	} else {
+		all, e := impl.listNoLock()
+		if e != nil {
+			err = e
+
+			return
+		}
+
+		for _, item := range all {
+			if item.Status == OutboxStatusDead {
+				items = append(items, item)
+			}
+		}
+	}
+
+	for _, item := range items {
+		if err = fn(item); err != nil {
+			return
+		}
+
+		n++
+	}
+
+	return
+}
+
+// deliverDue 发送所有到期的, 返回下一个待发送的时间
+func (impl *outboxImpl) deliverDue() (nextAt time.Time, hasNext bool) {
+	impl.lock.Lock()
+	items, err := impl.listNoLock()
+	impl.lock.Unlock()
+
+	if err != nil {
+		impl.logger.WithFields(l.ErrorField(err)).Error("list outbox failed")
+
+		return
+	}
+
+	for _, item := range items {
+		timeNow := impl.clock.Now()
+
+		switch item.Status {
+		case OutboxStatusPending:
+		case OutboxStatusDelivered:
+			if timeNow.Sub(item.DoneAt) > outboxDeliveredRetention {
+				impl.lock.Lock()
+				_ = impl.storage.Del(item.ID)
+				impl.lock.Unlock()
+			}
+
+			continue
+		default:
+			continue
+		}
+
+		if timeNow.Before(item.NextAt) {
+			if !hasNext || item.NextAt.Before(nextAt) {
+				nextAt = item.NextAt
+				hasNext = true
+			}
+
+			continue
+		}
+
+		if next, ok := impl.deliver(item); ok && (!hasNext || next.Before(nextAt)) {
+			nextAt = next
+			hasNext = true
+		}
+	}
+
+	return
+}
+
+func (impl *outboxImpl) deliver(item *OutboxItem) (nextAt time.Time, hasNext bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	delivered, err := impl.notify(ctx, item)
+	cancel()
+
+	if impl.opts.OnAttempt != nil {
+		impl.opts.OnAttempt(item.Message, err)
+	}
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	// 发送期间可能已经被 Purge
+	var current OutboxItem
+
+	if ok, e := impl.storage.Get(item.ID, &current); e != nil || !ok || current.Status != OutboxStatusPending {
+		return
+	}
+
+	timeNow := impl.clock.Now()
+
+	item.Attempts++
+	item.Delivered = append(item.Delivered, delivered...)
+
+	if err == nil {
+		item.Status = OutboxStatusDelivered
+		item.LastError = ""
+		item.DoneAt = timeNow
+	} else {
+		item.LastError = err.Error()
+
+		if item.Attempts >= impl.opts.MaxAttempts {
+			item.Status = OutboxStatusDead
+			item.DoneAt = timeNow
+
+			impl.logger.WithFields(l.StringField("id", item.ID), l.StringField("text", item.Message.Text),
+				l.ErrorField(err)).Error("notify failed, move to dead letters")
+		} else {
+			item.NextAt = timeNow.Add(impl.backoff(item.Attempts))
+			nextAt = item.NextAt
+			hasNext = true
+
+			impl.logger.WithFields(l.StringField("id", item.ID), l.IntField("attempts", item.Attempts),
+				l.ErrorField(err)).Info("notify failed, retry later")
+		}
+	}
+
+	if e := impl.storage.Set(item.ID, item); e != nil {
+		impl.logger.WithFields(l.ErrorField(e), l.StringField("id", item.ID)).Error("save outbox item failed")
+	}
+
+	return
+}
+
+// notify 支持 BackendNotifier 时只发送给还没有成功的后端
+func (impl *outboxImpl) notify(ctx context.Context, item *OutboxItem) (delivered []string, err error) {
+	backendNotifier, ok := impl.notifier.(BackendNotifier)
+	if !ok {
+		err = impl.notifier.Notify(ctx, item.Message)
+
+		return
+	}
+
+	skip := make(map[string]bool, len(item.Delivered))
+	for _, name := range item.Delivered {
+		skip[name] = true
+	}
+
+	return backendNotifier.NotifyBackends(ctx, item.Message, skip)
+}
+
+func (impl *outboxImpl) backoff(attempts int) time.Duration {
+	d := impl.opts.BaseDelay
+
+	for idx := 1; idx < attempts && d < impl.opts.MaxDelay; idx++ {
+		d *= 2
+	}
+
+	if d > impl.opts.MaxDelay {
+		d = impl.opts.MaxDelay
+	}
+
+	return d
+}
@@ -0,0 +1,278 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+type utNotifier struct {
+	name     string // 默认为 ut
+	lock     sync.Mutex
+	failLeft int // <0 一直失败
+	sent     []string
+	calls    int
+}
+
+func (n *utNotifier) Name() string {
+	if n.name != "" {
+		return n.name
+	}
+
+	return "ut"
+}
+
+func (n *utNotifier) Notify(_ context.Context, msg *Message) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.calls++
+
+	if n.failLeft != 0 {
+		n.failLeft--
+
+		return errors.New("down")
+	}
+
+	n.sent = append(n.sent, msg.NotifyID)
+
+	return nil
+}
+
+func (n *utNotifier) stat() (calls int, sent []string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.calls, append([]string(nil), n.sent...)
+}
+
+func utWaitItem(t *testing.T, outbox Outbox, id string, fn func(item *OutboxItem) bool) *OutboxItem {
+	for idx := 0; idx < 200; idx++ {
+		items, err := outbox.List("")
+		assert.Nil(t, err)
+
+		for _, item := range items {
+			if item.ID == id && fn(item) {
+				return item
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Fail(t, "wait outbox item timeout", id)
+
+	return nil
+}
+
+func TestOutboxRetry(t *testing.T) {
+	clock := timeassist.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	n := &utNotifier{failLeft: 2}
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox"), n, nil, nil, clock)
+	outbox.Start()
+
+	defer outbox.Stop()
+
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n1", Text: "t"}))
+
+	item := utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Attempts == 1
+	})
+	assert.Equal(t, OutboxStatusPending, item.Status)
+	assert.Equal(t, clock.Now().Add(DefaultOutboxBaseDelay), item.NextAt)
+	assert.Equal(t, "down", item.LastError)
+
+	clock.Advance(DefaultOutboxBaseDelay)
+
+	item = utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Attempts == 2
+	})
+	assert.Equal(t, clock.Now().Add(2*DefaultOutboxBaseDelay), item.NextAt)
+
+	clock.Advance(2 * DefaultOutboxBaseDelay)
+
+	utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDelivered
+	})
+
+	// 相同 NotifyID 不再发送
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n1", Text: "t"}))
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n2", Text: "t"}))
+
+	utWaitItem(t, outbox, "n2", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDelivered
+	})
+
+	calls, sent := n.stat()
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []string{"n1", "n2"}, sent)
+}
+
+func TestOutboxDeadLetters(t *testing.T) {
+	clock := timeassist.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	n := &utNotifier{failLeft: -1}
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox"), n, &OutboxOptions{
+		MaxAttempts: 2,
+		BaseDelay:   time.Minute,
+	}, nil, clock)
+	outbox.Start()
+
+	defer outbox.Stop()
+
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n1", Text: "t"}))
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n2", Text: "t"}))
+
+	utWaitItem(t, outbox, "n2", func(item *OutboxItem) bool {
+		return item.Attempts == 1
+	})
+
+	clock.Advance(time.Minute)
+
+	utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDead
+	})
+	utWaitItem(t, outbox, "n2", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDead
+	})
+
+	dead, err := outbox.List(OutboxStatusDead)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(dead))
+
+	_, err = outbox.Retry("n3")
+	assert.True(t, errors.Is(err, commerr.ErrNotFound))
+
+	n.lock.Lock()
+	n.failLeft = 0
+	n.lock.Unlock()
+
+	cnt, err := outbox.Retry("n1")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cnt)
+
+	utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDelivered
+	})
+
+	cnt, err = outbox.Purge("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, cnt)
+
+	items, err := outbox.List("")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, "n1", items[0].ID)
+}
+
+func TestOutboxRestart(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "outbox")
+	clock := timeassist.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	n := &utNotifier{}
+
+	// 没有启动, 模拟入队后进程退出
+	outbox := NewOutbox(fileName, n, nil, nil, clock)
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n1", Text: "t", Show: &timeassist.ShowInfo{ID: "Aa", Value: "v"}}))
+
+	outbox = NewOutbox(fileName, n, nil, nil, clock)
+	outbox.Start()
+
+	defer outbox.Stop()
+
+	item := utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDelivered
+	})
+	assert.Equal(t, "v", item.Message.Show.Value)
+
+	_, sent := n.stat()
+	assert.Equal(t, []string{"n1"}, sent)
+}
+
+func TestOutboxRetryFailedBackends(t *testing.T) {
+	clock := timeassist.NewFakeClock(time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC))
+	ok := &utNotifier{name: "ok"}
+	bad := &utNotifier{name: "bad", failLeft: 2}
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox"), NewMulti(ok, bad), nil, nil, clock)
+	outbox.Start()
+
+	defer outbox.Stop()
+
+	assert.Nil(t, outbox.Enqueue(&Message{NotifyID: "n1", Text: "t"}))
+
+	item := utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Attempts == 1
+	})
+	assert.Equal(t, OutboxStatusPending, item.Status)
+	assert.Equal(t, []string{"ok"}, item.Delivered)
+
+	clock.Advance(DefaultOutboxBaseDelay)
+
+	utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Attempts == 2
+	})
+
+	clock.Advance(2 * DefaultOutboxBaseDelay)
+
+	item = utWaitItem(t, outbox, "n1", func(item *OutboxItem) bool {
+		return item.Status == OutboxStatusDelivered
+	})
+	assert.Equal(t, []string{"ok", "bad"}, item.Delivered)
+
+	// 成功的后端只发送一次
+	calls, sent := ok.stat()
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []string{"n1"}, sent)
+
+	calls, sent = bad.stat()
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []string{"n1"}, sent)
+}
+
+// 同一个 Task 的显示, 过期和下一个周期都要通知
+func TestOutboxShowNotifyID(t *testing.T) {
+	n := &utNotifier{}
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox"), n, nil, nil, nil)
+	outbox.Start()
+
+	defer outbox.Stop()
+
+	showList := timeassist.NewShowList(filepath.Join(t.TempDir(), "show"), func(show *timeassist.ShowInfo, visible bool) {
+		if visible {
+			assert.Nil(t, outbox.Enqueue(&Message{NotifyID: show.NotifyID, Text: show.Value, Show: show}))
+		}
+	}, nil, nil)
+
+	show := &timeassist.ShowInfo{ID: "Ttask", Value: "打扫", StartUTC: 100, EndUTC: 200}
+	assert.Nil(t, showList.Add(show))
+
+	expired := *show
+	expired.AlarmFlag = true
+	assert.Nil(t, showList.Add(&expired))
+
+	next := expired
+	next.AlarmFlag = false
+	next.StartUTC, next.EndUTC = 200, 300
+	assert.Nil(t, showList.Add(&next))
+
+	// 内容不变时不重复通知
+	assert.Nil(t, showList.Add(&next))
+
+	for _, id := range []string{show.NotifyID, expired.NotifyID, next.NotifyID} {
+		utWaitItem(t, outbox, id, func(item *OutboxItem) bool {
+			return item.Status == OutboxStatusDelivered
+		})
+	}
+
+	_, sent := n.stat()
+	assert.Len(t, sent, 3)
+}
@@ -0,0 +1,544 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	uuid "github.com/satori/go.uuid"
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/i/l"
+	"github.com/sgostarter/libeasygo/stg/kv"
+)
+
+const (
+	quietTimeLayout = "15:04"
+	quietRetryDelay = time.Minute
+)
+
+// QuietWindow 勿扰时段, End 不晚于 Start 时表示跨天, 如 22:00 - 07:00.
+// Receivers 和 Groups 都为空时对所有通知生效, 否则通知的任一接收者或 Group 匹配时生效, 整条通知一起保存
+type QuietWindow struct {
+	Start    string `yaml:"Start"`
+	End      string `yaml:"End"`
+	Weekdays []int  `yaml:"Weekdays,omitempty"` // 0 为周日, 按 Start 所在的日期判断, 为空时每天
+	Location string `yaml:"Location,omitempty"` // IANA 时区名, 为空时使用本地时区
+
+	Receivers []string `yaml:"Receivers,omitempty"` // 和路由的 Receivers 比较, 路由没有时使用后端配置的
+	Groups    []string `yaml:"Groups,omitempty"`    // 和路由的 Group 比较
+
+	start, end time.Duration
+	loc        *time.Location
+}
+
+func (w *QuietWindow) parse() (err error) {
+	parseClock := func(s string) (d time.Duration, err error) {
+		t, err := time.Parse(quietTimeLayout, s)
+		if err != nil {
+			err = fmt.Errorf("%w: quiet hours %q", commerr.ErrInvalidArgument, s)
+
+			return
+		}
+
+		d = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+		return
+	}
+
+	if w.start, err = parseClock(w.Start); err != nil {
+		return
+	}
+
+	if w.end, err = parseClock(w.End); err != nil {
+		return
+	}
+
+	if w.start == w.end {
+		return fmt.Errorf("%w: quiet hours %s - %s is empty", commerr.ErrInvalidArgument, w.Start, w.End)
+	}
+
+	for _, weekday := range w.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			return fmt.Errorf("%w: quiet hours weekday %d", commerr.ErrInvalidArgument, weekday)
+		}
+	}
+
+	w.loc = time.Local
+	if w.Location != "" {
+		w.loc, err = time.LoadLocation(w.Location)
+	}
+
+	return
+}
+
+// active t 在时段内时返回时段结束的时间
+func (w *QuietWindow) active(t time.Time) (endAt time.Time, ok bool) {
+	t = t.In(w.loc)
+
+	// 跨天的时段可能从前一天开始
+	for _, day := range []int{-1, 0} {
+		date := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, 0, 0, w.loc)
+		if !w.onWeekday(date.Weekday()) {
+			continue
+		}
+
+		startAt := date.Add(w.start)
+		endAt = date.Add(w.end)
+
+		if w.end < w.start {
+			endAt = date.AddDate(0, 0, 1).Add(w.end)
+		}
+
+		if !t.Before(startAt) && t.Before(endAt) {
+			return endAt, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (w *QuietWindow) onWeekday(weekday time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+
+	for _, d := range w.Weekdays {
+		if time.Weekday(d) == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (w *QuietWindow) match(receivers []string, group string) bool {
+	if len(w.Receivers) == 0 && len(w.Groups) == 0 {
+		return true
+	}
+
+	for _, receiver := range receivers {
+		for _, r := range w.Receivers {
+			if r == receiver {
+				return true
+			}
+		}
+	}
+
+	if group == "" {
+		return false
+	}
+
+	for _, g := range w.Groups {
+		if g == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseQuietWindows(windows []QuietWindow) (parsed []QuietWindow, err error) {
+	parsed = make([]QuietWindow, len(windows))
+	copy(parsed, windows)
+
+	for idx := range parsed {
+		if err = parsed[idx].parse(); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// quietUntil 在多个匹配的时段内时取最晚的结束时间, loc 为这个时段的时区
+func quietUntil(windows []QuietWindow, receivers []string, group string, t time.Time) (endAt time.Time, loc *time.Location, ok bool) {
+	for idx := range windows {
+		if !windows[idx].match(receivers, group) {
+			continue
+		}
+
+		if at, active := windows[idx].active(t); active && (!ok || at.After(endAt)) {
+			endAt, loc, ok = at, windows[idx].loc, true
+		}
+	}
+
+	return
+}
+
+// HeldMessage 勿扰时段内保存的通知
+type HeldMessage struct {
+	Backend   string    `yaml:"Backend" json:"backend"`
+	Message   *Message  `yaml:"Message" json:"message"`
+	HeldAt    time.Time `yaml:"HeldAt" json:"held_at"`
+	ReleaseAt time.Time `yaml:"ReleaseAt" json:"release_at"`
+	Location  string    `yaml:"Location,omitempty" json:"location,omitempty"` // 勿扰时段的时区, 摘要按这个时区显示时间
+}
+
+func (item *HeldMessage) location() *time.Location {
+	if item.Location != "" {
+		if loc, err := time.LoadLocation(item.Location); err == nil {
+			return loc
+		}
+	}
+
+	return time.Local
+}
+
+// QuietHours 勿扰时段内的非紧急通知先保存, 时段结束后每个后端合并成一条摘要发送
+type QuietHours interface {
+	// Wrap 作为 NewNotifiers 的 wrapper, 后端配置了 QuietHours 时使用后端的, 否则使用全局的
+	Wrap(cfg *Config, n Notifier) (Notifier, error)
+	// Start 摘要通过 enqueue 发送, 一般为 Outbox.Enqueue
+	Start(enqueue func(msg *Message) error)
+	Stop()
+	Held() ([]*HeldMessage, error)
+}
+
+func NewQuietHours(fileName string, windows []QuietWindow, logger l.Wrapper, clock timeassist.Clock) (QuietHours, error) {
+	if logger == nil {
+		logger = l.NewNopLoggerWrapper()
+	}
+
+	parsed, err := parseQuietWindows(windows)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := kv.NewMemoryFileStorageEx(fileName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if clock == nil {
+		clock = timeassist.NewRealClock()
+	}
+
+	return &quietHoursImpl{
+		logger:  logger.WithFields(l.StringField(l.ClsKey, "quietHoursImpl")),
+		storage: storage,
+		windows: parsed,
+		clock:   clock,
+		wakeCh:  make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+	}, nil
+}
+
+type quietHoursImpl struct {
+	logger  l.Wrapper
+	storage kv.StorageTiny
+	windows []QuietWindow
+	clock   timeassist.Clock
+
+	lock sync.Mutex
+
+	wakeCh   chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func (impl *quietHoursImpl) Wrap(cfg *Config, n Notifier) (Notifier, error) {
+	windows := impl.windows
+
+	if len(cfg.QuietHours) > 0 {
+		var err error
+
+		windows, err = parseQuietWindows(cfg.QuietHours)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(windows) == 0 {
+		return n, nil
+	}
+
+	// 配置错误的 Locale 由 WrapLocale 报错
+	lc, _ := locale.Parse(cfg.Locale)
+
+	return &quietNotifier{
+		Notifier:  n,
+		owner:     impl,
+		windows:   windows,
+		receivers: cfg.Receivers,
+		locale:    lc,
+	}, nil
+}
+
+func (impl *quietHoursImpl) wakeup() {
+	select {
+	case impl.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (impl *quietHoursImpl) hold(backend string, msg *Message, releaseAt time.Time, loc *time.Location) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	id := msg.NotifyID
+	if id == "" {
+		id = uuid.NewV4().String()
+	}
+
+	key := backend + "/" + id
+
+	if ok, err := impl.storage.Get(key, &HeldMessage{}); err != nil || ok {
+		return err
+	}
+
+	err := impl.storage.Set(key, &HeldMessage{
+		Backend:   backend,
+		Message:   msg,
+		HeldAt:    impl.clock.Now(),
+		ReleaseAt: releaseAt,
+		Location:  loc.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	impl.wakeup()
+
+	return nil
+}
+
+func (impl *quietHoursImpl) listNoLock() (keys []string, items []*HeldMessage, err error) {
+	ds, err := impl.storage.GetMap(func(_ string) interface{} {
+		return &HeldMessage{}
+	})
+	if err != nil {
+		return
+	}
+
+	for key := range ds {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return ds[keys[i]].(*HeldMessage).HeldAt.Before(ds[keys[j]].(*HeldMessage).HeldAt)
+	})
+
+	for _, key := range keys {
+		items = append(items, ds[key].(*HeldMessage))
+	}
+
+	return
+}
+
+func (impl *quietHoursImpl) Held() (items []*HeldMessage, err error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	_, items, err = impl.listNoLock()
+
+	return
+}
+
+func (impl *quietHoursImpl) Start(enqueue func(msg *Message) error) {
+	impl.wg.Add(1)
+
+	go func() {
+		defer impl.wg.Done()
+
+		for {
+			nextAt, hasNext := impl.releaseDue(enqueue)
+
+			var timerC <-chan time.Time
+
+			var timer timeassist.ClockTimer
+
+			if hasNext {
+				timer = impl.clock.NewTimer(nextAt.Sub(impl.clock.Now()))
+				timerC = timer.C()
+			}
+
+			select {
+			case <-timerC:
+			case <-impl.wakeCh:
+			case <-impl.stopCh:
+				if timer != nil {
+					timer.Stop()
+				}
+
+				return
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+func (impl *quietHoursImpl) Stop() {
+	impl.stopOnce.Do(func() {
+		close(impl.stopCh)
+	})
+
+	impl.wg.Wait()
+}
+
+type digestGroup struct {
+	keys  []string
+	items []*HeldMessage
+}
+
+// releaseDue 到期的按 后端+结束时间+路由 合并成摘要, 入队成功后删除
+func (impl *quietHoursImpl) releaseDue(enqueue func(msg *Message) error) (nextAt time.Time, hasNext bool) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	keys, items, err := impl.listNoLock()
+	if err != nil {
+		impl.logger.WithFields(l.ErrorField(err)).Error("list held messages failed")
+
+		return
+	}
+
+	timeNow := impl.clock.Now()
+
+	var groupIDs []string
+
+	groups := make(map[string]*digestGroup)
+
+	for idx, item := range items {
+		if timeNow.Before(item.ReleaseAt) {
+			if !hasNext || item.ReleaseAt.Before(nextAt) {
+				nextAt, hasNext = item.ReleaseAt, true
+			}
+
+			continue
+		}
+
+		id := digestID(item)
+
+		group, ok := groups[id]
+		if !ok {
+			group = &digestGroup{}
+			groups[id] = group
+			groupIDs = append(groupIDs, id)
+		}
+
+		group.keys = append(group.keys, keys[idx])
+		group.items = append(group.items, item)
+	}
+
+	for _, id := range groupIDs {
+		group := groups[id]
+
+		if err = enqueue(newDigestMessage(id, group.items, timeNow)); err != nil {
+			impl.logger.WithFields(l.ErrorField(err), l.StringField("id", id)).Error("enqueue digest failed")
+
+			if retryAt := timeNow.Add(quietRetryDelay); !hasNext || retryAt.Before(nextAt) {
+				nextAt, hasNext = retryAt, true
+			}
+
+			continue
+		}
+
+		for _, key := range group.keys {
+			_ = impl.storage.Del(key)
+		}
+	}
+
+	return
+}
+
+// digestID 相同的保存内容生成相同的 ID, 入队后重启不会重复发送
+func digestID(item *HeldMessage) string {
+	h := fnv.New32a()
+
+	if route := item.Message.Route; route != nil {
+		_, _ = h.Write([]byte(strings.Join(route.Receivers, ",") + "|" + route.Group + "|" + route.BizCode))
+	}
+
+	return fmt.Sprintf("digest-%s-%d-%x", item.Backend, item.ReleaseAt.Unix(), h.Sum32())
+}
+
+func newDigestMessage(id string, items []*HeldMessage, timeNow time.Time) *Message {
+	// 保存时已经按后端的语言选择了文本
+	lc := items[0].Message.Locale
+	if lc == "" {
+		lc = locale.Default
+	}
+
+	lines := make([]string, 0, len(items)+1)
+	lines = append(lines, lc.T("notify.digest", len(items)))
+
+	for _, item := range items {
+		lines = append(lines, item.Message.At.In(item.location()).Format("15:04")+" "+item.Message.Text)
+	}
+
+	route := &timeassist.NotifyRoute{
+		Backends: []string{items[0].Backend},
+	}
+
+	if r := items[0].Message.Route; r != nil {
+		route.Receivers = r.Receivers
+		route.Group = r.Group
+		route.BizCode = r.BizCode
+	}
+
+	return &Message{
+		NotifyID: id,
+		Text:     strings.Join(lines, "\n"),
+		Locale:   lc,
+		At:       timeNow,
+		Route:    route,
+		Digest:   true,
+	}
+}
+
+type quietNotifier struct {
+	Notifier
+	owner     *quietHoursImpl
+	windows   []QuietWindow
+	receivers []string
+	locale    locale.Locale
+}
+
+// localize 保存时就选好后端语言的文本, 摘要按这个语言生成
+func (n *quietNotifier) localize(msg *Message) *Message {
+	text, ok := msg.Texts[n.locale]
+	if n.locale == "" || !ok {
+		return msg
+	}
+
+	localized := *msg
+	localized.Text = text
+	localized.Locale = n.locale
+	localized.Texts = nil
+
+	return &localized
+}
+
+// Notify 紧急通知和摘要不受勿扰时段限制
+func (n *quietNotifier) Notify(ctx context.Context, msg *Message) error {
+	if msg.Urgent || msg.Digest {
+		return n.Notifier.Notify(ctx, msg)
+	}
+
+	receivers, group := n.receivers, ""
+
+	if route := msg.Route; route != nil {
+		if len(route.Receivers) > 0 {
+			receivers = route.Receivers
+		}
+
+		group = route.Group
+	}
+
+	releaseAt, loc, ok := quietUntil(n.windows, receivers, group, n.owner.clock.Now())
+	if !ok {
+		return n.Notifier.Notify(ctx, msg)
+	}
+
+	return n.owner.hold(n.Name(), n.localize(msg), releaseAt, loc)
+}
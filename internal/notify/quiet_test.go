@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietWindowActive(t *testing.T) {
+	_, err := parseQuietWindows([]QuietWindow{{Start: "22:00", End: "22:00"}})
+	assert.True(t, errors.Is(err, commerr.ErrInvalidArgument))
+
+	_, err = parseQuietWindows([]QuietWindow{{Start: "25:00", End: "07:00"}})
+	assert.True(t, errors.Is(err, commerr.ErrInvalidArgument))
+
+	windows, err := parseQuietWindows([]QuietWindow{
+		{Start: "22:00", End: "07:00", Location: "UTC"},
+		{Start: "12:00", End: "13:30", Weekdays: []int{1}, Location: "UTC"},
+	})
+	assert.Nil(t, err)
+
+	// 2026-03-02 为周一
+	for _, c := range []struct {
+		at    time.Time
+		endAt time.Time
+	}{
+		{time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 6, 59, 0, 0, time.UTC), time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC), time.Time{}},
+		{time.Date(2026, 3, 2, 12, 30, 0, 0, time.UTC), time.Date(2026, 3, 2, 13, 30, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 3, 12, 30, 0, 0, time.UTC), time.Time{}},
+	} {
+		endAt, _, ok := quietUntil(windows, nil, "", c.at)
+		assert.Equal(t, !c.endAt.IsZero(), ok, c.at)
+		assert.True(t, endAt.Equal(c.endAt), c.at)
+	}
+}
+
+type utEnqueue struct {
+	lock sync.Mutex
+	msgs []*Message
+}
+
+func (e *utEnqueue) enqueue(msg *Message) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.msgs = append(e.msgs, msg)
+
+	return nil
+}
+
+func (e *utEnqueue) wait(t *testing.T, n int) []*Message {
+	for idx := 0; idx < 200; idx++ {
+		e.lock.Lock()
+		msgs := append([]*Message(nil), e.msgs...)
+		e.lock.Unlock()
+
+		if len(msgs) >= n {
+			return msgs
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Fail(t, "wait digest timeout")
+
+	return nil
+}
+
+func TestQuietHoursDigest(t *testing.T) {
+	clock := timeassist.NewFakeClock(time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC))
+
+	quietHours, err := NewQuietHours(filepath.Join(t.TempDir(), "quiet"),
+		[]QuietWindow{{Start: "22:00", End: "07:00", Location: "UTC"}}, nil, clock)
+	assert.Nil(t, err)
+
+	night := &utNotifier{}
+	always := &utNotifier{}
+
+	n, err := quietHours.Wrap(&Config{}, &utNamedNotifier{utNotifier: night, name: "night"})
+	assert.Nil(t, err)
+
+	// 后端自己的时段覆盖全局的
+	n2, err := quietHours.Wrap(&Config{QuietHours: []QuietWindow{{Start: "01:00", End: "02:00", Location: "UTC"}}},
+		&utNamedNotifier{utNotifier: always, name: "always"})
+	assert.Nil(t, err)
+
+	multi := NewMulti(n, n2)
+
+	assert.Nil(t, multi.Notify(context.Background(), &Message{NotifyID: "n1", Text: "喝水", At: clock.Now()}))
+	assert.Nil(t, multi.Notify(context.Background(), &Message{NotifyID: "n2", Text: "吃药", At: clock.Now()}))
+	assert.Nil(t, multi.Notify(context.Background(), &Message{NotifyID: "n3", Text: "起火", Urgent: true}))
+
+	_, sent := night.stat()
+	assert.Equal(t, []string{"n3"}, sent)
+
+	_, sent = always.stat()
+	assert.Equal(t, []string{"n1", "n2", "n3"}, sent)
+
+	held, err := quietHours.Held()
+	assert.Nil(t, err)
+	assert.Len(t, held, 2)
+
+	e := &utEnqueue{}
+
+	quietHours.Start(e.enqueue)
+	defer quietHours.Stop()
+
+	clock.Set(time.Date(2026, 3, 3, 7, 0, 0, 0, time.UTC))
+
+	msgs := e.wait(t, 1)
+	assert.Len(t, msgs, 1)
+	assert.True(t, msgs[0].Digest)
+	assert.Equal(t, []string{"night"}, msgs[0].Route.Backends)
+	assert.True(t, strings.HasPrefix(msgs[0].Text, "勿扰期间的 2 条通知:\n"))
+	assert.Contains(t, msgs[0].Text, "23:00 喝水")
+	assert.Contains(t, msgs[0].Text, "23:00 吃药")
+
+	// 摘要经 outbox 回到后端时直接发送
+	assert.Nil(t, multi.Notify(context.Background(), msgs[0]))
+
+	_, sent = night.stat()
+	assert.Equal(t, []string{"n3", msgs[0].NotifyID}, sent)
+
+	held, err = quietHours.Held()
+	assert.Nil(t, err)
+	assert.Len(t, held, 0)
+}
+
+func TestQuietHoursDigestLocale(t *testing.T) {
+	clock := timeassist.NewFakeClock(time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC))
+
+	quietHours, err := NewQuietHours(filepath.Join(t.TempDir(), "quiet"),
+		[]QuietWindow{{Start: "22:00", End: "07:00", Location: "UTC"}}, nil, clock)
+	assert.Nil(t, err)
+
+	en := &utNotifier{}
+
+	// 和 main 中一样, 勿扰在 WrapLocale 外层
+	n, err := WrapLocale(&Config{Locale: "en-US"}, &utNamedNotifier{utNotifier: en, name: "en"})
+	assert.Nil(t, err)
+
+	n, err = quietHours.Wrap(&Config{Locale: "en-US"}, n)
+	assert.Nil(t, err)
+
+	assert.Nil(t, n.Notify(context.Background(), &Message{NotifyID: "n1", Text: "喝水", At: clock.Now(),
+		Texts: map[locale.Locale]string{locale.EnUS: "drink water"}}))
+
+	e := &utEnqueue{}
+
+	quietHours.Start(e.enqueue)
+	defer quietHours.Stop()
+
+	clock.Set(time.Date(2026, 3, 3, 7, 0, 0, 0, time.UTC))
+
+	msgs := e.wait(t, 1)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, locale.EnUS, msgs[0].Locale)
+	assert.Equal(t, "1 notifications during quiet hours:\n23:00 drink water", msgs[0].Text)
+
+	assert.Nil(t, n.Notify(context.Background(), msgs[0]))
+
+	_, sent := en.stat()
+	assert.Equal(t, []string{msgs[0].NotifyID}, sent)
+}
+
+func TestQuietHoursReceivers(t *testing.T) {
+	clock := timeassist.NewFakeClock(time.Date(2026, 3, 2, 23, 0, 0, 0, time.UTC))
+
+	quietHours, err := NewQuietHours(filepath.Join(t.TempDir(), "quiet"), []QuietWindow{
+		{Start: "22:00", End: "07:00", Location: "UTC", Receivers: []string{"alice"}},
+		{Start: "06:00", End: "08:00", Location: "Asia/Shanghai", Groups: []string{"family"}},
+	}, nil, clock)
+	assert.Nil(t, err)
+
+	un := &utNotifier{}
+
+	n, err := quietHours.Wrap(&Config{Receivers: []string{"bob"}}, &utNamedNotifier{utNotifier: un, name: "share"})
+	assert.Nil(t, err)
+
+	// 后端配置的接收者不在勿扰时段
+	assert.Nil(t, n.Notify(context.Background(), &Message{NotifyID: "n1", Text: "喝水", At: clock.Now()}))
+	assert.Nil(t, n.Notify(context.Background(), &Message{NotifyID: "n2", Text: "吃药", At: clock.Now(),
+		Route: &timeassist.NotifyRoute{Receivers: []string{"alice", "bob"}}}))
+	assert.Nil(t, n.Notify(context.Background(), &Message{NotifyID: "n3", Text: "浇花", At: clock.Now(),
+		Route: &timeassist.NotifyRoute{Group: "family"}}))
+
+	_, sent := un.stat()
+	assert.Equal(t, []string{"n1"}, sent)
+
+	held, err := quietHours.Held()
+	assert.Nil(t, err)
+	assert.Len(t, held, 2)
+
+	e := &utEnqueue{}
+
+	quietHours.Start(e.enqueue)
+	defer quietHours.Stop()
+
+	// 上海时间 08:00
+	clock.Set(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))
+
+	msgs := e.wait(t, 1)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, "family", msgs[0].Route.Group)
+	// 按时段的时区显示
+	assert.Equal(t, "勿扰期间的 1 条通知:\n07:00 浇花", msgs[0].Text)
+
+	clock.Set(time.Date(2026, 3, 3, 7, 0, 0, 0, time.UTC))
+
+	msgs = e.wait(t, 2)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, []string{"alice", "bob"}, msgs[1].Route.Receivers)
+	assert.Equal(t, "勿扰期间的 1 条通知:\n23:00 吃药", msgs[1].Text)
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+const defaultMailSubject = "timeassist"
+
+// smtpNotifier 配置了 Username 时使用 PLAIN 认证, 服务器支持时自动 STARTTLS
+type smtpNotifier struct {
+	name    string
+	addr    string
+	host    string
+	auth    smtp.Auth
+	from    string
+	to      []string
+	subject string
+	timeout time.Duration
+}
+
+func newSMTPNotifier(cfg *Config) (Notifier, error) {
+	if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("%w: smtp Host, From and To are required", commerr.ErrInvalidArgument)
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 25
+	}
+
+	impl := &smtpNotifier{
+		name:    cfg.name(),
+		addr:    net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		host:    cfg.Host,
+		from:    cfg.From,
+		to:      cfg.To,
+		subject: cfg.Subject,
+		timeout: cfg.timeout(),
+	}
+
+	if impl.subject == "" {
+		impl.subject = defaultMailSubject
+	}
+
+	if cfg.Username != "" {
+		impl.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return impl, nil
+}
+
+func (impl *smtpNotifier) Name() string {
+	return impl.name
+}
+
+// Notify 连接和每次读写都受超时限制, 返回时不会留下仍在发送的连接
+func (impl *smtpNotifier) Notify(ctx context.Context, msg *Message) error {
+	ctx, cancel := context.WithTimeout(ctx, impl.timeout)
+	defer cancel()
+
+	dialer := &net.Dialer{
+		Timeout: impl.timeout,
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", impl.addr)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// 外部取消时关闭连接, 打断阻塞的读写
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err = impl.send(conn, msg); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// send 同 smtp.SendMail, 使用已经建立的连接
+func (impl *smtpNotifier) send(conn net.Conn, msg *Message) error {
+	c, err := smtp.NewClient(conn, impl.host)
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: impl.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return err
+		}
+	}
+
+	if impl.auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("%w: smtp server doesn't support AUTH", commerr.ErrUnavailable)
+		}
+
+		if err = c.Auth(impl.auth); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(impl.from); err != nil {
+		return err
+	}
+
+	for _, to := range impl.to {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(impl.buildMail(msg)); err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (impl *smtpNotifier) buildMail(msg *Message) []byte {
+	at := msg.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("From: " + impl.from + "\r\n")
+	sb.WriteString("To: " + strings.Join(impl.to, ", ") + "\r\n")
+	sb.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", impl.subject) + "\r\n")
+	sb.WriteString("Date: " + at.Format(time.RFC1123Z) + "\r\n")
+
+	if msg.NotifyID != "" {
+		sb.WriteString("Message-ID: <" + msg.NotifyID + "@timeassistbe>\r\n")
+	}
+
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	sb.WriteString("\r\n")
+
+	return []byte(sb.String())
+}
@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/6tail/lunar-go/calendar"
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	"github.com/s-min-sys/timeassistbe/internal/utils"
+)
+
+const (
+	TemplateAlarm = "alarm"
+	TemplateTask  = "task"
+
+	templateTimeLayout = "2006-01-02 15:04:05"
+)
+
+// defaultTemplates 与之前写死的格式一致, 可以在配置中用同名模板覆盖;
+// 名字加 ".<语言>" 的为该语言的模板, 如 alarm.en-US
+var defaultTemplates = map[string]string{
+	TemplateAlarm: `闹钟: {{.Text}} {{.SubTitle}} - {{date .AlarmAt}}{{if .Expired}} 已经过期{{end}}`,
+	TemplateTask:  `任务: {{.Text}} {{.SubTitle}}{{if .Expired}} 已经过期{{end}}`,
+
+	TemplateAlarm + "." + string(locale.EnUS): `Alarm: {{.Text}} {{.SubTitle}} - {{date .AlarmAt}}{{if .Expired}} expired{{end}}`,
+	TemplateTask + "." + string(locale.EnUS):  `Task: {{.Text}} {{.SubTitle}}{{if .Expired}} expired{{end}}`,
+}
+
+// TemplateData 模板中可以使用的字段
+type TemplateData struct {
+	ID       string
+	Text     string
+	SubTitle string
+	IsAlarm  bool
+	Expired  bool
+	AlarmAt  time.Time
+	LeftTime string // 距离 AlarmAt 的时间, 如 "1小时5分"
+	Lunar    string // AlarmAt(task 为当前时间)的农历日期, 如 "八月十五"
+	Now      time.Time
+	Locale   locale.Locale
+}
+
+func NewTemplateData(show *timeassist.ShowInfo, timeNow time.Time) *TemplateData {
+	return NewTemplateDataLocale(show, timeNow, locale.Default)
+}
+
+// NewTemplateDataLocale SubTitle/LeftTime/Lunar 使用 lc 对应的语言
+func NewTemplateDataLocale(show *timeassist.ShowInfo, timeNow time.Time, lc locale.Locale) *TemplateData {
+	show = show.Localize(lc, timeNow)
+
+	data := &TemplateData{
+		ID:       show.ID,
+		Text:     show.Value,
+		SubTitle: show.SubTitle,
+		IsAlarm:  show.VOTaskType == timeassist.VOTaskTypeAlarm,
+		Expired:  show.AlarmFlag,
+		AlarmAt:  show.AlarmAt,
+		Now:      timeNow,
+		Locale:   lc,
+	}
+
+	lunarAt := timeNow
+
+	if data.IsAlarm {
+		data.LeftTime = utils.LeftTimeStringLocale(show.AlarmAt, timeNow, lc)
+		lunarAt = show.AlarmAt
+	}
+
+	lunar := calendar.NewSolarFromYmd(lunarAt.Year(), int(lunarAt.Month()), lunarAt.Day()).GetLunar()
+	month := lunar.GetMonth()
+
+	if month < 0 {
+		month = -month
+	}
+
+	data.Lunar = lc.T("notify.lunar", lunar.GetMonthInChinese(), lunar.GetDayInChinese(), month, lunar.GetDay())
+
+	return data
+}
+
+var templateFuncs = template.FuncMap{
+	// date 默认格式 2006-01-02 15:04:05
+	"date": func(t time.Time, layout ...string) string {
+		if len(layout) > 0 {
+			return t.Format(layout[0])
+		}
+
+		return t.Format(templateTimeLayout)
+	},
+}
+
+// Renderer 全局模板在创建时解析, 配置错误启动时就能发现
+type Renderer struct {
+	templates map[string]*template.Template
+}
+
+func NewRenderer(templates map[string]string) (*Renderer, error) {
+	r := &Renderer{
+		templates: make(map[string]*template.Template),
+	}
+
+	for name, text := range defaultTemplates {
+		if _, ok := templates[name]; !ok {
+			if err := r.add(name, text); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for name, text := range templates {
+		if err := r.add(name, text); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Renderer) add(name, text string) error {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("template %s: %w", name, err)
+	}
+
+	r.templates[name] = t
+
+	return nil
+}
+
+// Render tmpl 为全局模板名或模板内容, 为空时按 alarm/task 选择默认模板
+func (r *Renderer) Render(tmpl string, show *timeassist.ShowInfo, timeNow time.Time) (string, error) {
+	return r.RenderLocale(tmpl, show, timeNow, locale.Default)
+}
+
+// RenderLocale 全局模板优先使用 "<模板名>.<语言>"
+func (r *Renderer) RenderLocale(tmpl string, show *timeassist.ShowInfo, timeNow time.Time, lc locale.Locale) (string, error) {
+	data := NewTemplateDataLocale(show, timeNow, lc)
+
+	if tmpl == "" {
+		tmpl = TemplateTask
+		if data.IsAlarm {
+			tmpl = TemplateAlarm
+		}
+	}
+
+	t, ok := r.templates[tmpl+"."+string(lc)]
+	if !ok {
+		t, ok = r.templates[tmpl]
+	}
+
+	if !ok {
+		var err error
+
+		t, err = template.New("inline").Funcs(templateFuncs).Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var sb strings.Builder
+
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/s-min-sys/timeassistbe/internal/timeassist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderer(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	timeNow := time.Date(2026, 9, 25, 8, 0, 0, 0, tz8)
+
+	alarm := &timeassist.ShowInfo{
+		ID:         "Aa",
+		Value:      "赏月",
+		SubTitle:   "每年",
+		AlarmAt:    time.Date(2026, 9, 25, 20, 0, 0, 0, tz8),
+		VOTaskType: timeassist.VOTaskTypeAlarm,
+	}
+
+	task := &timeassist.ShowInfo{
+		ID:         "Ta",
+		Value:      "打扫",
+		SubTitle:   "09月25号-09月26号",
+		AlarmFlag:  true,
+		VOTaskType: timeassist.VOTaskTypeTask,
+	}
+
+	r, err := NewRenderer(map[string]string{
+		"short": `{{.Text}}@{{date .AlarmAt "15:04"}} 农历{{.Lunar}} 还有{{.LeftTime}}`,
+	})
+	assert.Nil(t, err)
+
+	// 默认模板和之前的格式一致
+	text, err := r.Render("", alarm, timeNow)
+	assert.Nil(t, err)
+	assert.Equal(t, "闹钟: 赏月 每年 - 2026-09-25 20:00:00", text)
+
+	text, err = r.Render("", task, timeNow)
+	assert.Nil(t, err)
+	assert.Equal(t, "任务: 打扫 09月25号-09月26号 已经过期", text)
+
+	text, err = r.Render("short", alarm, timeNow)
+	assert.Nil(t, err)
+	assert.Equal(t, "赏月@20:00 农历八月十五 还有12小时0分", text)
+
+	text, err = r.Render("{{.ID}}:{{.Text}}", task, timeNow)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ta:打扫", text)
+
+	_, err = r.Render("{{.Missing}}", task, timeNow)
+	assert.NotNil(t, err)
+
+	// 覆盖默认模板
+	r, err = NewRenderer(map[string]string{TemplateAlarm: "{{.Text}}"})
+	assert.Nil(t, err)
+
+	text, err = r.Render("", alarm, timeNow)
+	assert.Nil(t, err)
+	assert.Equal(t, "赏月", text)
+
+	_, err = NewRenderer(map[string]string{"bad": "{{.Text"})
+	assert.NotNil(t, err)
+}
+
+func TestRendererLocale(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	timeNow := time.Date(2026, 9, 25, 8, 0, 0, 0, tz8)
+
+	alarm := &timeassist.ShowInfo{
+		ID:         "Aa",
+		Value:      "赏月",
+		SubTitle:   "每年",
+		SubTitles:  map[locale.Locale]string{locale.ZhCN: "每年", locale.EnUS: "every year"},
+		AlarmAt:    time.Date(2026, 9, 25, 20, 0, 0, 0, tz8),
+		VOTaskType: timeassist.VOTaskTypeAlarm,
+	}
+
+	r, err := NewRenderer(map[string]string{
+		"short":       `{{.Text}} 农历{{.Lunar}} 还有{{.LeftTime}}`,
+		"short.en-US": `{{.Text}} {{.Lunar}} in {{.LeftTime}}`,
+	})
+	assert.Nil(t, err)
+
+	text, err := r.RenderLocale("", alarm, timeNow, locale.EnUS)
+	assert.Nil(t, err)
+	assert.Equal(t, "Alarm: 赏月 every year - 2026-09-25 20:00:00", text)
+
+	text, err = r.RenderLocale("short", alarm, timeNow, locale.EnUS)
+	assert.Nil(t, err)
+	assert.Equal(t, "赏月 lunar 8/15 in 12h 0m", text)
+
+	text, err = r.RenderLocale("short", alarm, timeNow, locale.ZhCN)
+	assert.Nil(t, err)
+	assert.Equal(t, "赏月 农历八月十五 还有12小时0分", text)
+
+	// 没有对应语言的模板时使用同名模板
+	text, err = r.RenderLocale("{{.SubTitle}}", alarm, timeNow, locale.EnUS)
+	assert.Nil(t, err)
+	assert.Equal(t, "every year", text)
+}
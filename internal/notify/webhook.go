@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+// webhookNotifier POST Message 的 JSON, 非 2xx 视为失败
+type webhookNotifier struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookNotifier(cfg *Config) (Notifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("%w: webhook URL is empty", commerr.ErrInvalidArgument)
+	}
+
+	return &webhookNotifier{
+		name:    cfg.name(),
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client: &http.Client{
+			Timeout: cfg.timeout(),
+		},
+	}, nil
+}
+
+func (impl *webhookNotifier) Name() string {
+	return impl.name
+}
+
+func (impl *webhookNotifier) Notify(ctx context.Context, msg *Message) error {
+	d, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, impl.url, bytes.NewReader(d))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range impl.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := impl.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
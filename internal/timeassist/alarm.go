@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/s-min-sys/timeassistbe/internal/locale"
 	"github.com/sgostarter/i/commerr"
 )
 
@@ -17,6 +18,8 @@ L[S]22092400 [日时分秒] RecycleTimeTypeMonth/RecycleAlarmTypeLunarMonth | 24
 092812[时分秒] RecycleTimeTypeDay | 60 分
 2912[分秒] RecycleTimeTypeHour | 5分
 23[秒] RecycleTimeTypeMinute | 0 分
+0 9,14 * * 1-5 [cron 表达式] RecycleTimeTypeCron | 按触发间隔 @see CronSchedule
+DTSTART:20260106T090000 RRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=10 RecycleTimeTypeRRule | 按触发间隔 @see RRule
 */
 
 type Alarm struct {
@@ -27,35 +30,74 @@ type Alarm struct {
 
 	Value    string `yaml:"Value,omitempty" json:"value,omitempty"` // @see AlarmValue
 	TimeZone int    `yaml:"TimeZone,omitempty" json:"timeZone,omitempty"`
+	Location string `yaml:"Location,omitempty" json:"location,omitempty"` // IANA 时区名, 如 Europe/Berlin, 设置后忽略 TimeZone
 
 	ValidTime *ValidTime `yaml:"ValidRanges,omitempty" json:"valid_time,omitempty"`
 
 	EarlyShowMinute int `yaml:"EarlyShowMinute,omitempty" json:"early_show_minute,omitempty"`
+	MaxSnoozeCount  int `yaml:"MaxSnoozeCount,omitempty" json:"max_snooze_count,omitempty"` // 0 不限制
 
 	//
 	//
 	//
 	TimeLastAt int64 `yaml:"TimeLastAt,omitempty" json:"time_last_at,omitempty"` // ？
+
+	SnoozeCount int       `yaml:"SnoozeCount,omitempty" json:"snooze_count,omitempty"` // 本次提醒已经稍后提醒的次数
+	SnoozeShow  *ShowInfo `yaml:"SnoozeShow,omitempty" json:"snooze_show,omitempty"`   // 稍后提醒到期时重新显示的内容
+
+	Finished bool `yaml:"Finished,omitempty" json:"finished,omitempty"` // 没有下一次提醒了, 如单次提醒过期或 RRULE 的 COUNT/UNTIL 用完
+
+	Paused   bool  `yaml:"Paused,omitempty" json:"paused,omitempty"`      // 暂停时保留定义, 没有定时和显示
+	ResumeAt int64 `yaml:"ResumeAt,omitempty" json:"resume_at,omitempty"` // 自动恢复的时间(unix 秒), 0 需要手动恢复
+
+	UID string `yaml:"UID,omitempty" json:"uid,omitempty"` // 从 iCalendar 导入时的 UID
+
+	Notify     *NotifyRoute `yaml:"Notify,omitempty" json:"notify,omitempty"`
+	Escalation *Escalation  `yaml:"Escalation,omitempty" json:"escalation,omitempty"` // 过期后没有完成时重复通知
+	Urgent     bool         `yaml:"Urgent,omitempty" json:"urgent,omitempty"`         // 不受勿扰时段限制
+
+	Exceptions []*AlarmException `yaml:"Exceptions,omitempty" json:"exceptions,omitempty"` // 跳过或改期的单次提醒
+}
+
+func (a *Alarm) resetSnooze() {
+	a.SnoozeCount = 0
+	a.SnoozeShow = nil
 }
 
 func (a *Alarm) Validate() (av *AlarmValue, err error) {
+	return a.ValidateEx(time.Now(), nil)
+}
+
+// ValidateEx calendar 为空时使用内置的节假日数据
+func (a *Alarm) ValidateEx(timeNow time.Time, calendar HolidayCalendar) (av *AlarmValue, err error) {
 	if a.Text == "" || a.Value == "" {
 		return nil, commerr.ErrInvalidArgument
 	}
 
+	if _, err = TimeLocation(a.Location, a.TimeZone); err != nil {
+		return
+	}
+
+	// 节假日数据用完后可能再也没有有效的日期
+	if a.ValidTime != nil {
+		if err = a.ValidTime.Valid(timeNow, calendar); err != nil {
+			return
+		}
+	}
+
 	av, err = ParseAlarmValue(a.Value, a.AType)
 
 	return
 }
 
 func (a *Alarm) GenRecycleData() (av *AlarmValue, timeAt time.Time, rd *ShowItem, show, alarm bool, err error) {
-	return a.GenRecycleDataEx(time.Now(), time.Now())
+	return a.GenRecycleDataEx(time.Now(), time.Now(), nil)
 }
 
-// GenRecycleDataEx
+// GenRecycleDataEx calendar 为空时使用内置的节假日数据
 // nolint: gocyclo
-func (a *Alarm) GenRecycleDataEx(timeNow, timeLastAt time.Time) (av *AlarmValue, timeAt time.Time, rd *ShowItem, show, alarm bool, err error) {
-	av, err = a.Validate()
+func (a *Alarm) GenRecycleDataEx(timeNow, timeLastAt time.Time, calendar HolidayCalendar) (av *AlarmValue, timeAt time.Time, rd *ShowItem, show, alarm bool, err error) {
+	av, err = a.ValidateEx(timeNow, calendar)
 	if err != nil {
 		return
 	}
@@ -64,16 +106,101 @@ func (a *Alarm) GenRecycleDataEx(timeNow, timeLastAt time.Time) (av *AlarmValue,
 		ID: a.ID,
 	}
 
-	if a.TimeZone < -11 || a.TimeZone > 12 {
-		a.TimeZone = 8
+	timeZone, err := TimeLocation(a.Location, a.TimeZone)
+	if err != nil {
+		return
 	}
 
-	timeZone := time.FixedZone("X", a.TimeZone*3600)
 	timeNow = timeNow.In(timeZone)
 	timeLastAt = timeLastAt.In(timeZone)
 
-	var showDuration time.Duration
+	timeAt, showDuration, err := a.nextOccurrenceAt(av, timeNow, timeZone, calendar)
+	if err != nil {
+		return
+	}
+
+	timeAt = a.rescheduledBefore(timeAt, timeNow, timeZone)
+
+	if a.EarlyShowMinute > 0 {
+		showDuration = time.Minute * time.Duration(a.EarlyShowMinute)
+	}
+
+	timeShow := timeAt.Add(-showDuration)
+
+	rdNow.StartUTC = timeShow.Unix() // next show at
+	rdNow.EndUTC = timeAt.Unix()     // next expire at
+
+	if a.AType == TimeTypeOnce || a.AType == RecycleTimeTypeRRule {
+		if timeAt.Before(timeNow) {
+			show = true
+			alarm = true
+
+			return
+		}
+	}
+
+	rd = rdNow
+
+	if timeShow.Before(timeNow) {
+		show = true
+
+		return
+	}
+
+	if timeLastAt.Before(timeNow) {
+		alarm = true
+
+		return
+	}
+
+	return
+}
+
+// nextTimeAt 计算 timeNow 之后下一次符合 ValidTime 的提醒时间
+func (a *Alarm) nextTimeAt(av *AlarmValue, timeNow time.Time, timeZone *time.Location, calendar HolidayCalendar) (timeAt time.Time, showDuration time.Duration, err error) {
+	timeAt, showDuration, err = a.calcTimeAt(av, timeNow, timeZone)
+	if err != nil || a.ValidTime == nil {
+		return
+	}
+
+	if a.AType == TimeTypeOnce {
+		var found bool
+
+		if _, timeAt, found = a.ValidTime.findAfterTime(timeAt, calendar); !found {
+			err = commerr.ErrNotFound
+		}
+	} else if !timeAt.Before(timeNow) {
+		// 从下一个有效时间开始重新计算, 保持提醒的时分秒不变
+		for idx := 0; idx < maxValidTimeRetry; idx++ {
+			ok, validAt, found := a.ValidTime.findAfterTime(timeAt, calendar)
+			if !found {
+				err = commerr.ErrNotFound
+
+				return
+			}
+
+			if ok {
+				break
+			}
+
+			timeAt, showDuration, err = a.calcTimeAt(av, validAt, timeZone)
+			if err != nil {
+				return
+			}
+
+			// 已经没有下一次
+			if timeAt.Before(validAt) {
+				break
+			}
+		}
+	}
+
+	return
+}
 
+// calcTimeAt 计算 timeNow 之后的下一次提醒时间
+// nolint: gocyclo
+func (a *Alarm) calcTimeAt(av *AlarmValue, timeNow time.Time, timeZone *time.Location) (timeAt time.Time, showDuration time.Duration, err error) {
 	fnCalcDynamicDuration := func(tNow, tAt time.Time) (d time.Duration) {
 		if tAt.Before(tNow) {
 			return
@@ -210,76 +337,52 @@ func (a *Alarm) GenRecycleDataEx(timeNow, timeLastAt time.Time) (av *AlarmValue,
 
 		showDuration = time.Hour
 	case RecycleTimeTypeHour:
-		year := timeNow.Year()
-		month := timeNow.Month()
-		day := timeNow.Day()
-		hour := timeNow.Hour()
-
-	ReCalcHour:
-		timeAt = ToDateTime(year, int(month), day, hour, av.Minute, av.Second, timeZone)
+		// 按实际经过的小时计算, 夏令时结束时重复的小时也会提醒
+		timeAt = HourStart(timeNow).Add(time.Duration(av.Minute)*time.Minute + time.Duration(av.Second)*time.Second)
 
-		if timeAt.Before(timeNow) {
-			hour++
-
-			goto ReCalcHour
+		for timeAt.Before(timeNow) {
+			timeAt = timeAt.Add(time.Hour)
 		}
 
 		showDuration = time.Minute * 5
 	case RecycleTimeTypeMinute:
-		year := timeNow.Year()
-		month := timeNow.Month()
-		day := timeNow.Day()
-		hour := timeNow.Hour()
-		minute := timeNow.Minute()
-
-	ReCalcMinute:
-		timeAt = ToDateTime(year, int(month), day, hour, minute, av.Second, timeZone)
-
-		if timeAt.Before(timeNow) {
-			minute++
+		timeAt = MinuteStart(timeNow).Add(time.Duration(av.Second) * time.Second)
 
-			goto ReCalcMinute
+		for timeAt.Before(timeNow) {
+			timeAt = timeAt.Add(time.Minute)
 		}
 
 		showDuration = time.Second * 5
-	default:
-		return
-	}
-
-	if a.ValidTime != nil {
-		_, timeAt = a.ValidTime.FindAfterTime(timeAt)
-	}
-
-	if a.EarlyShowMinute > 0 {
-		showDuration = time.Minute * time.Duration(a.EarlyShowMinute)
-	}
-
-	timeShow := timeAt.Add(-showDuration)
-
-	rdNow.StartUTC = timeShow.Unix() // next show at
-	rdNow.EndUTC = timeAt.Unix()     // next expire at
-
-	if a.AType == TimeTypeOnce {
-		if timeAt.Before(timeNow) {
-			show = true
-			alarm = true
+	case RecycleTimeTypeCron:
+		timeAt = av.Cron.Next(timeNow)
+		if timeAt.IsZero() {
+			err = commerr.ErrNotFound
 
 			return
 		}
-	}
-
-	rd = rdNow
 
-	if timeShow.Before(timeNow) {
-		show = true
-
-		return
-	}
+		showDuration = cronShowDuration(av.Cron.Next(timeAt.Add(time.Second)).Sub(timeAt))
+	case RecycleTimeTypeRRule:
+		timeAt = av.RRule.Next(timeNow)
+		if timeAt.IsZero() {
+			// COUNT/UNTIL 已用完, 返回最后一次
+			timeAt = av.RRule.Prev(timeNow)
+			if timeAt.IsZero() {
+				err = commerr.ErrNotFound
+			}
 
-	if timeLastAt.Before(timeNow) {
-		alarm = true
+			return
+		}
 
-		return
+		if nextAt := av.RRule.Next(timeAt.Add(time.Second)); !nextAt.IsZero() {
+			showDuration = cronShowDuration(nextAt.Sub(timeAt))
+		} else if prevAt := av.RRule.Prev(timeAt); !prevAt.IsZero() {
+			showDuration = cronShowDuration(timeAt.Sub(prevAt))
+		} else {
+			showDuration = fnCalcDynamicDuration(timeNow, timeAt)
+		}
+	default:
+		err = commerr.ErrInvalidArgument
 	}
 
 	return
@@ -294,9 +397,16 @@ type AlarmValue struct {
 	Hour   int
 	Minute int
 	Second int
+
+	Cron  *CronSchedule // RecycleTimeTypeCron
+	RRule *RRule        // RecycleTimeTypeRRule
 }
 
 func (av *AlarmValue) StringNoNowTime(aType TimeType) (bool, string) {
+	return av.StringNoNowTimeLocale(aType, locale.Default)
+}
+
+func (av *AlarmValue) StringNoNowTimeLocale(aType TimeType, lc locale.Locale) (bool, string) {
 	var days int
 
 	var dayS string
@@ -327,13 +437,13 @@ func (av *AlarmValue) StringNoNowTime(aType TimeType) (bool, string) {
 
 		switch av.Day {
 		case -1:
-			dayS = fmt.Sprintf("%02d日(最后一天)", days)
+			dayS = lc.T("alarm.day_last", days)
 		case -2:
-			dayS = fmt.Sprintf("%02d日(倒数第二天)", days)
+			dayS = lc.T("alarm.day_last2", days)
 		case -3:
-			dayS = fmt.Sprintf("%02d日(倒数第三天)", days)
+			dayS = lc.T("alarm.day_last3", days)
 		default:
-			dayS = fmt.Sprintf("%02d日", av.Day)
+			dayS = lc.T("alarm.day", av.Day)
 		}
 
 		return dayS
@@ -341,37 +451,36 @@ func (av *AlarmValue) StringNoNowTime(aType TimeType) (bool, string) {
 
 	if aType == TimeTypeOnce {
 		if av.Lunar {
-			return false, fmt.Sprintf("阴历%04d年%02d月%s%02d时%02d分%02d秒", av.Year, av.Month, fnGetDay(), av.Hour, av.Minute, av.Second)
+			return false, lc.T("alarm.once_lunar", av.Year, av.Month, fnGetDay(), av.Hour, av.Minute, av.Second)
 		}
 
-		return false, fmt.Sprintf("%04d年%02d月%s%02d时%02d分%02d秒", av.Year, av.Month, fnGetDay(), av.Hour, av.Minute, av.Second)
+		return false, lc.T("alarm.once", av.Year, av.Month, fnGetDay(), av.Hour, av.Minute, av.Second)
 	}
 
-	yx := "阳历"
+	yx := lc.T("alarm.solar")
 	if av.Lunar {
-		yx = "阴历"
+		yx = lc.T("alarm.lunar")
 	}
 
 	var pre string
 
 	switch aType {
 	case RecycleTimeTypeYear:
-		pre = yx + fmt.Sprintf("每年%02d月%s%02d时%02d分%02d秒", av.Month, fnGetDay(), av.Hour, av.Minute, av.Second)
+		pre = lc.T("alarm.year", yx, av.Month, fnGetDay(), av.Hour, av.Minute, av.Second)
 	case RecycleTimeTypeMonth:
-		pre = yx + fmt.Sprintf("每月%s%02d时%02d分%02d秒", fnGetDay(), av.Hour, av.Minute, av.Second)
+		pre = lc.T("alarm.month", yx, fnGetDay(), av.Hour, av.Minute, av.Second)
 	case RecycleTimeTypeWeek:
-		week := fmt.Sprintf("周%d", av.Week)
-		if av.Week == 0 {
-			week = "周日"
-		}
-
-		pre = fmt.Sprintf("每周周%s%02d时%02d分%02d秒", week, av.Hour, av.Minute, av.Second)
+		pre = lc.T("alarm.week", lc.T(fmt.Sprintf("week.%d", av.Week)), av.Hour, av.Minute, av.Second)
 	case RecycleTimeTypeDay:
-		pre = fmt.Sprintf("每日%02d时%02d分%02d秒", av.Hour, av.Minute, av.Second)
+		pre = lc.T("alarm.day_time", av.Hour, av.Minute, av.Second)
 	case RecycleTimeTypeHour:
-		pre = fmt.Sprintf("每小时%02d分%02d秒", av.Minute, av.Second)
+		pre = lc.T("alarm.hour", av.Minute, av.Second)
 	case RecycleTimeTypeMinute:
-		pre = fmt.Sprintf("每分%02d秒", av.Second)
+		pre = lc.T("alarm.minute", av.Second)
+	case RecycleTimeTypeCron:
+		pre = lc.T("alarm.cron", av.Cron.String())
+	case RecycleTimeTypeRRule:
+		pre = lc.T("alarm.rrule", av.RRule.String())
 	default:
 		return true, ""
 	}
@@ -380,9 +489,13 @@ func (av *AlarmValue) StringNoNowTime(aType TimeType) (bool, string) {
 }
 
 func (av *AlarmValue) String(aType TimeType, timeAt time.Time) string {
-	shouldAppendTime, s := av.StringNoNowTime(aType)
+	return av.StringLocale(aType, timeAt, locale.Default)
+}
+
+func (av *AlarmValue) StringLocale(aType TimeType, timeAt time.Time, lc locale.Locale) string {
+	shouldAppendTime, s := av.StringNoNowTimeLocale(aType, lc)
 	if shouldAppendTime {
-		s += timeAt.Format("[2006年01月02日15时04分05秒]")
+		s += timeAt.Format(lc.T("alarm.at_layout"))
 	}
 
 	return s
@@ -434,6 +547,10 @@ func (av *AlarmValue) Valid(aType TimeType) bool {
 		}
 
 		return true
+	case RecycleTimeTypeCron:
+		return av.Cron != nil
+	case RecycleTimeTypeRRule:
+		return av.RRule != nil
 	}
 
 	return false
@@ -455,6 +572,10 @@ func ParseAlarmValue(value string, aType TimeType) (av *AlarmValue, err error) {
 		av, err = parseAlarmValueHour(value)
 	case RecycleTimeTypeMinute:
 		av, err = parseAlarmValueMinute(value)
+	case RecycleTimeTypeCron:
+		av, err = parseAlarmValueCron(value)
+	case RecycleTimeTypeRRule:
+		av, err = parseAlarmValueRRule(value)
 	default:
 		err = commerr.ErrUnimplemented
 	}
@@ -689,3 +810,29 @@ func parseAlarmValueMinute(value string) (av *AlarmValue, err error) {
 
 	return
 }
+
+func parseAlarmValueCron(value string) (av *AlarmValue, err error) {
+	schedule, err := ParseCron(value)
+	if err != nil {
+		return
+	}
+
+	av = &AlarmValue{
+		Cron: schedule,
+	}
+
+	return
+}
+
+func parseAlarmValueRRule(value string) (av *AlarmValue, err error) {
+	rule, err := ParseRRule(value)
+	if err != nil {
+		return
+	}
+
+	av = &AlarmValue{
+		RRule: rule,
+	}
+
+	return
+}
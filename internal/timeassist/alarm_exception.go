@@ -0,0 +1,167 @@
+package timeassist
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+// AlarmException 周期提醒的单次例外, 按原本的提醒时间 OccurrenceAt 区分:
+// Skip 时跳过这一次, 否则这一次改到 RescheduleAt 提醒
+type AlarmException struct {
+	OccurrenceAt int64 `yaml:"OccurrenceAt" json:"occurrence_at"` // unix 秒, 添加时为 0 表示下一次提醒
+	Skip         bool  `yaml:"Skip,omitempty" json:"skip,omitempty"`
+	RescheduleAt int64 `yaml:"RescheduleAt,omitempty" json:"reschedule_at,omitempty"` // unix 秒
+}
+
+func (a *Alarm) findException(occurrenceAt int64) (idx int, ok bool) {
+	for idx = range a.Exceptions {
+		if a.Exceptions[idx].OccurrenceAt == occurrenceAt {
+			return idx, true
+		}
+	}
+
+	return
+}
+
+// nextOccurrenceAt timeNow 之后下一次没有例外的周期提醒时间
+func (a *Alarm) nextOccurrenceAt(av *AlarmValue, timeNow time.Time, timeZone *time.Location, calendar HolidayCalendar) (timeAt time.Time, showDuration time.Duration, err error) {
+	from := timeNow
+
+	timeAt, showDuration, err = a.nextTimeAt(av, from, timeZone, calendar)
+
+	// 每个例外最多跳过一次
+	for idx := 0; err == nil && idx < len(a.Exceptions); idx++ {
+		if timeAt.Before(from) {
+			break
+		}
+
+		if _, ok := a.findException(timeAt.Unix()); !ok {
+			break
+		}
+
+		from = timeAt.Add(time.Second)
+
+		timeAt, showDuration, err = a.nextTimeAt(av, from, timeZone, calendar)
+	}
+
+	return
+}
+
+// rescheduledBefore 改期到 [timeNow, timeAt) 之间最早的一次; 没有下一次周期提醒时只看改期
+func (a *Alarm) rescheduledBefore(timeAt, timeNow time.Time, timeZone *time.Location) time.Time {
+	for _, exception := range a.Exceptions {
+		if exception.Skip {
+			continue
+		}
+
+		rescheduleAt := time.Unix(exception.RescheduleAt, 0).In(timeZone)
+		if rescheduleAt.Before(timeNow) {
+			continue
+		}
+
+		if timeAt.Before(timeNow) || rescheduleAt.Before(timeAt) {
+			timeAt = rescheduleAt
+		}
+	}
+
+	return timeAt
+}
+
+// pruneExceptions 清理原本的提醒和改期都已经过去的例外
+func (a *Alarm) pruneExceptions(timeNow time.Time) {
+	exceptions := a.Exceptions[:0]
+
+	for _, exception := range a.Exceptions {
+		if exception.OccurrenceAt < timeNow.Unix() && (exception.Skip || exception.RescheduleAt < timeNow.Unix()) {
+			continue
+		}
+
+		exceptions = append(exceptions, exception)
+	}
+
+	if len(exceptions) == 0 {
+		exceptions = nil
+	}
+
+	a.Exceptions = exceptions
+}
+
+// AddException 同一次提醒的例外会被替换; 单次提醒直接修改, RRULE 使用 EXDATE
+func (a *Alarm) AddException(exception *AlarmException, timeNow time.Time, calendar HolidayCalendar) (err error) {
+	if exception == nil || a.AType == TimeTypeOnce || a.AType == RecycleTimeTypeRRule ||
+		exception.Skip == (exception.RescheduleAt != 0) {
+		return commerr.ErrInvalidArgument
+	}
+
+	if !exception.Skip && time.Unix(exception.RescheduleAt, 0).Before(timeNow) {
+		return commerr.ErrOutOfRange
+	}
+
+	av, err := a.ValidateEx(timeNow, calendar)
+	if err != nil {
+		return
+	}
+
+	timeZone, err := TimeLocation(a.Location, a.TimeZone)
+	if err != nil {
+		return
+	}
+
+	// 按提醒的时区计算周期
+	timeNow = timeNow.In(timeZone)
+
+	if exception.OccurrenceAt == 0 {
+		timeAt, _, e := a.nextOccurrenceAt(av, timeNow, timeZone, calendar)
+		if e != nil {
+			return e
+		}
+
+		exception.OccurrenceAt = timeAt.Unix()
+	} else {
+		occurrenceAt := time.Unix(exception.OccurrenceAt, 0).In(timeZone)
+		if occurrenceAt.Before(timeNow) {
+			return commerr.ErrOutOfRange
+		}
+
+		// 必须是原本的一次提醒
+		timeAt, _, e := a.nextTimeAt(av, occurrenceAt, timeZone, calendar)
+		if e != nil {
+			return e
+		}
+
+		if !timeAt.Equal(occurrenceAt) {
+			return commerr.ErrInvalidArgument
+		}
+	}
+
+	a.pruneExceptions(timeNow)
+
+	if idx, ok := a.findException(exception.OccurrenceAt); ok {
+		a.Exceptions[idx] = exception
+	} else {
+		a.Exceptions = append(a.Exceptions, exception)
+	}
+
+	sort.Slice(a.Exceptions, func(i, j int) bool {
+		return a.Exceptions[i].OccurrenceAt < a.Exceptions[j].OccurrenceAt
+	})
+
+	return
+}
+
+func (a *Alarm) RemoveException(occurrenceAt int64) (err error) {
+	idx, ok := a.findException(occurrenceAt)
+	if !ok {
+		return commerr.ErrNotFound
+	}
+
+	a.Exceptions = append(a.Exceptions[:idx], a.Exceptions[idx+1:]...)
+
+	if len(a.Exceptions) == 0 {
+		a.Exceptions = nil
+	}
+
+	return
+}
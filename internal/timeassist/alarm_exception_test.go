@@ -0,0 +1,145 @@
+package timeassist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func utPreviewFireAts(t *testing.T, alarm *Alarm, timeFrom time.Time, n int) (ats []time.Time) {
+	occurrences, err := PreviewAlarm(alarm, timeFrom, n)
+	assert.Nil(t, err)
+
+	for _, occurrence := range occurrences {
+		ats = append(ats, occurrence.FireAt.At)
+	}
+
+	return
+}
+
+func TestAlarmException(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+
+	// 传入的时间和本机时区都可能和提醒的时区不同
+	for _, loc := range []*time.Location{tz8, time.UTC, time.FixedZone("z-5", -5*3600), time.Local} {
+		t.Run(loc.String(), func(t *testing.T) {
+			// 2026-03-02 周一
+			utTestAlarmException(t, tz8, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8).In(loc))
+		})
+	}
+}
+
+func utTestAlarmException(t *testing.T, tz8 *time.Location, timeNow time.Time) {
+
+	alarm := &Alarm{
+		ID:       "Astandup",
+		AType:    RecycleTimeTypeWeek,
+		Text:     "standup",
+		Value:    "2090000",
+		TimeZone: 8,
+	}
+
+	// 跳过下一次
+	skip := &AlarmException{Skip: true}
+	assert.Nil(t, alarm.AddException(skip, timeNow, nil))
+	assert.Equal(t, time.Date(2026, 3, 3, 9, 0, 0, 0, tz8).Unix(), skip.OccurrenceAt)
+
+	assert.Nil(t, alarm.AddException(&AlarmException{
+		OccurrenceAt: time.Date(2026, 3, 10, 9, 0, 0, 0, tz8).Unix(),
+		RescheduleAt: time.Date(2026, 3, 11, 10, 0, 0, 0, tz8).Unix(),
+	}, timeNow, nil))
+
+	ats := utPreviewFireAts(t, alarm, timeNow, 3)
+	assert.Equal(t, 3, len(ats))
+	assert.True(t, ats[0].Equal(time.Date(2026, 3, 11, 10, 0, 0, 0, tz8)))
+	assert.True(t, ats[1].Equal(time.Date(2026, 3, 17, 9, 0, 0, 0, tz8)))
+	assert.True(t, ats[2].Equal(time.Date(2026, 3, 24, 9, 0, 0, 0, tz8)))
+
+	// 同一次提醒的例外被替换, 可以提前
+	assert.Nil(t, alarm.AddException(&AlarmException{
+		OccurrenceAt: time.Date(2026, 3, 10, 9, 0, 0, 0, tz8).Unix(),
+		RescheduleAt: time.Date(2026, 3, 9, 7, 0, 0, 0, tz8).Unix(),
+	}, timeNow, nil))
+	assert.Len(t, alarm.Exceptions, 2)
+
+	ats = utPreviewFireAts(t, alarm, timeNow, 2)
+	assert.True(t, ats[0].Equal(time.Date(2026, 3, 9, 7, 0, 0, 0, tz8)))
+	assert.True(t, ats[1].Equal(time.Date(2026, 3, 17, 9, 0, 0, 0, tz8)))
+
+	assert.Nil(t, alarm.RemoveException(skip.OccurrenceAt))
+	assert.ErrorIs(t, alarm.RemoveException(skip.OccurrenceAt), commerr.ErrNotFound)
+
+	ats = utPreviewFireAts(t, alarm, timeNow, 1)
+	assert.True(t, ats[0].Equal(time.Date(2026, 3, 3, 9, 0, 0, 0, tz8)))
+
+	for _, c := range []struct {
+		exception *AlarmException
+		err       error
+	}{
+		{&AlarmException{OccurrenceAt: time.Date(2026, 3, 10, 8, 0, 0, 0, tz8).Unix(), Skip: true}, commerr.ErrInvalidArgument},
+		{&AlarmException{OccurrenceAt: time.Date(2026, 2, 24, 9, 0, 0, 0, tz8).Unix(), Skip: true}, commerr.ErrOutOfRange},
+		{&AlarmException{Skip: true, RescheduleAt: time.Date(2026, 3, 4, 9, 0, 0, 0, tz8).Unix()}, commerr.ErrInvalidArgument},
+		{&AlarmException{}, commerr.ErrInvalidArgument},
+		{&AlarmException{RescheduleAt: time.Date(2026, 3, 1, 9, 0, 0, 0, tz8).Unix()}, commerr.ErrOutOfRange},
+	} {
+		assert.ErrorIs(t, alarm.AddException(c.exception, timeNow, nil), c.err)
+	}
+
+	once := &Alarm{ID: "Aonce", AType: TimeTypeOnce, Text: "once", Value: "S20260310090000", TimeZone: 8}
+	assert.ErrorIs(t, once.AddException(&AlarmException{Skip: true}, timeNow, nil), commerr.ErrInvalidArgument)
+
+	// 原本的提醒和改期都过去后清理
+	alarm.pruneExceptions(time.Date(2026, 3, 10, 0, 0, 0, 0, tz8).In(timeNow.Location()))
+	assert.Len(t, alarm.Exceptions, 1)
+
+	alarm.pruneExceptions(time.Date(2026, 3, 11, 0, 0, 0, 0, tz8).In(timeNow.Location()))
+	assert.Nil(t, alarm.Exceptions)
+}
+
+func TestAlarmManagerException(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeMonth,
+		Text:     "rent",
+		Value:    "S01090000",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	at, ok := env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 30, 9, 0, 0, 0, tz8)))
+
+	exception := &AlarmException{RescheduleAt: time.Date(2026, 4, 3, 9, 0, 0, 0, tz8).Unix()}
+	assert.Nil(t, env.alarmManager.AddException(alarm.ID, exception))
+	assert.Equal(t, time.Date(2026, 4, 1, 9, 0, 0, 0, tz8).Unix(), exception.OccurrenceAt)
+
+	at, ok = env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 4, 1, 9, 0, 0, 0, tz8)))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 4, 3, 8, 0, 0, 0, tz8))
+
+	showInfo, err := env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.False(t, showInfo.AlarmFlag)
+	assert.True(t, showInfo.AlarmAt.Equal(time.Date(2026, 4, 3, 9, 0, 0, 0, tz8)))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 4, 3, 9, 30, 0, 0, tz8))
+
+	showInfo, err = env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.True(t, showInfo.AlarmFlag)
+
+	at, ok = env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 4, 29, 9, 0, 0, 0, tz8)))
+
+	assert.ErrorIs(t, env.alarmManager.AddException("Anotexist", &AlarmException{Skip: true}), commerr.ErrNotFound)
+	assert.ErrorIs(t, env.alarmManager.RemoveException(alarm.ID, 1), commerr.ErrNotFound)
+}
@@ -0,0 +1,232 @@
+package timeassist
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/i/l"
+	"github.com/sgostarter/libeasygo/stg/kv"
+)
+
+type AlarmHistoryType string
+
+const (
+	AlarmHistoryShow         AlarmHistoryType = "show"
+	AlarmHistoryExpire       AlarmHistoryType = "expire"
+	AlarmHistoryNotifySent   AlarmHistoryType = "notify-sent"
+	AlarmHistoryNotifyFailed AlarmHistoryType = "notify-failed"
+	AlarmHistoryDone         AlarmHistoryType = "done"
+	AlarmHistorySnooze       AlarmHistoryType = "snooze"
+)
+
+const (
+	DefaultAlarmHistoryMaxRecords = 500
+	DefaultAlarmHistoryMaxDays    = 90
+)
+
+type AlarmHistoryRecord struct {
+	Type     AlarmHistoryType `yaml:"Type" json:"type"`
+	At       time.Time        `yaml:"At" json:"at"`
+	NotifyID string           `yaml:"NotifyID,omitempty" json:"notify_id,omitempty"`
+	AlarmAt  time.Time        `yaml:"AlarmAt,omitempty" json:"alarm_at,omitempty"` // show/expire 时提醒的时间
+	Message  string           `yaml:"Message,omitempty" json:"message,omitempty"`  // 发送失败的原因, 稍后提醒的分钟数等
+}
+
+// AlarmHistoryOptions 每个 Alarm 最多保留 MaxRecords 条, 最多保留 MaxDays 天
+type AlarmHistoryOptions struct {
+	MaxRecords int `yaml:"MaxRecords"`
+	MaxDays    int `yaml:"MaxDays"`
+}
+
+type AlarmHistory interface {
+	Record(alarmID string, record *AlarmHistoryRecord) error
+	// List startAt/endAt 为零时不限制
+	List(alarmID string, startAt, endAt time.Time) ([]*AlarmHistoryRecord, error)
+	// OnShowListEvent 作为 ShowList 的事件观察者, 记录 show/expire
+	OnShowListEvent(event *ShowListEvent)
+}
+
+func NewAlarmHistory(fileName string, opts *AlarmHistoryOptions, logger l.Wrapper, clock Clock) AlarmHistory {
+	if logger == nil {
+		logger = l.NewNopLoggerWrapper()
+	}
+
+	storage, err := kv.NewMemoryFileStorageEx(fileName, false)
+	if err != nil {
+		logger.WithFields(l.ErrorField(err)).Fatal("open alarm history storage failed")
+
+		return nil
+	}
+
+	impl := &alarmHistoryImpl{
+		logger:  logger.WithFields(l.StringField(l.ClsKey, "alarmHistoryImpl")),
+		storage: storage,
+		clock:   fixClock(clock),
+	}
+
+	if opts != nil {
+		impl.opts = *opts
+	}
+
+	impl.init()
+
+	return impl
+}
+
+type alarmHistoryImpl struct {
+	logger  l.Wrapper
+	storage kv.StorageTiny
+	clock   Clock
+	opts    AlarmHistoryOptions
+
+	lock sync.Mutex
+}
+
+func (impl *alarmHistoryImpl) init() {
+	if impl.opts.MaxRecords <= 0 {
+		impl.opts.MaxRecords = DefaultAlarmHistoryMaxRecords
+	}
+
+	if impl.opts.MaxDays <= 0 {
+		impl.opts.MaxDays = DefaultAlarmHistoryMaxDays
+	}
+
+	impl.pruneAll()
+}
+
+// pruneAll 启动时清理, 包括已经删除的 Alarm 留下的记录
+func (impl *alarmHistoryImpl) pruneAll() {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	ds, err := impl.storage.GetMap(func(_ string) interface{} {
+		return &[]*AlarmHistoryRecord{}
+	})
+	if err != nil {
+		impl.logger.WithFields(l.ErrorField(err)).Error("load alarm history failed")
+
+		return
+	}
+
+	for alarmID, d := range ds {
+		records, ok := d.(*[]*AlarmHistoryRecord)
+		if !ok {
+			continue
+		}
+
+		if pruned := impl.prune(*records); len(pruned) != len(*records) {
+			_ = impl.save(alarmID, pruned)
+		}
+	}
+}
+
+func (impl *alarmHistoryImpl) prune(records []*AlarmHistoryRecord) []*AlarmHistoryRecord {
+	expireAt := impl.clock.Now().AddDate(0, 0, -impl.opts.MaxDays)
+
+	idx := 0
+	for idx < len(records) && records[idx].At.Before(expireAt) {
+		idx++
+	}
+
+	if len(records)-idx > impl.opts.MaxRecords {
+		idx = len(records) - impl.opts.MaxRecords
+	}
+
+	return records[idx:]
+}
+
+func (impl *alarmHistoryImpl) save(alarmID string, records []*AlarmHistoryRecord) error {
+	if len(records) == 0 {
+		return impl.storage.Del(alarmID)
+	}
+
+	return impl.storage.Set(alarmID, records)
+}
+
+func (impl *alarmHistoryImpl) load(alarmID string) (records []*AlarmHistoryRecord, err error) {
+	_, err = impl.storage.Get(alarmID, &records)
+
+	return
+}
+
+func (impl *alarmHistoryImpl) Record(alarmID string, record *AlarmHistoryRecord) (err error) {
+	if record == nil || ParsePreOnID(alarmID) != AlarmIDPre {
+		return commerr.ErrInvalidArgument
+	}
+
+	if record.At.IsZero() {
+		record.At = impl.clock.Now()
+	}
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	records, err := impl.load(alarmID)
+	if err != nil {
+		return
+	}
+
+	return impl.save(alarmID, impl.prune(append(records, record)))
+}
+
+func (impl *alarmHistoryImpl) List(alarmID string, startAt, endAt time.Time) (records []*AlarmHistoryRecord, err error) {
+	if ParsePreOnID(alarmID) != AlarmIDPre {
+		return nil, commerr.ErrInvalidArgument
+	}
+
+	impl.lock.Lock()
+	all, err := impl.load(alarmID)
+	impl.lock.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	records = make([]*AlarmHistoryRecord, 0, len(all))
+
+	for _, record := range impl.prune(all) {
+		if !startAt.IsZero() && record.At.Before(startAt) {
+			continue
+		}
+
+		if !endAt.IsZero() && !record.At.Before(endAt) {
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return
+}
+
+// OnShowListEvent 只记录需要通知的变化: 新显示或 NotifyID 改变
+func (impl *alarmHistoryImpl) OnShowListEvent(event *ShowListEvent) {
+	if event.Show == nil || ParsePreOnID(event.Show.ID) != AlarmIDPre {
+		return
+	}
+
+	switch event.Type {
+	case ShowListEventAdd, ShowListEventUpdate, ShowListEventFlagChange:
+	default:
+		return
+	}
+
+	if event.Old != nil && event.Old.NotifyID == event.Show.NotifyID {
+		return
+	}
+
+	record := &AlarmHistoryRecord{
+		Type:     AlarmHistoryShow,
+		NotifyID: event.Show.NotifyID,
+		AlarmAt:  event.Show.AlarmAt,
+	}
+
+	if event.Show.AlarmFlag {
+		record.Type = AlarmHistoryExpire
+	}
+
+	if err := impl.Record(event.Show.ID, record); err != nil {
+		impl.logger.WithFields(l.ErrorField(err), l.StringField("id", event.Show.ID)).Error("record alarm history failed")
+	}
+}
@@ -0,0 +1,100 @@
+package timeassist
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func utHistoryTypes(records []*AlarmHistoryRecord) (types []AlarmHistoryType) {
+	for _, record := range records {
+		types = append(types, record.Type)
+	}
+
+	return
+}
+
+func TestAlarmHistoryShowList(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+
+	history := NewAlarmHistory(filepath.Join(t.TempDir(), "alarm_history"), nil, nil, env.clock)
+	env.showList.AddEventOb(history.OnShowListEvent)
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeDay,
+		Text:     "吃药",
+		Value:    "083000",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	// 内容不变的重新加入不记录
+	assert.Nil(t, env.alarmManager.Update(alarm))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 2, 9, 0, 0, 0, tz8))
+
+	assert.Nil(t, history.Record(alarm.ID, &AlarmHistoryRecord{Type: AlarmHistoryDone}))
+	assert.Nil(t, env.alarmManager.Done(alarm.ID))
+	// 已经结束的显示和不存在的提醒
+	assert.ErrorIs(t, env.alarmManager.Done(alarm.ID), commerr.ErrNotFound)
+	assert.ErrorIs(t, env.alarmManager.Done("Anotexist"), commerr.ErrNotFound)
+
+	records, err := history.List(alarm.ID, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, []AlarmHistoryType{AlarmHistoryShow, AlarmHistoryExpire, AlarmHistoryDone}, utHistoryTypes(records))
+	assert.True(t, records[0].AlarmAt.Equal(time.Date(2026, 3, 2, 8, 30, 0, 0, tz8)))
+	assert.NotEqual(t, records[0].NotifyID, records[1].NotifyID)
+
+	records, err = history.List(alarm.ID, time.Date(2026, 3, 2, 8, 10, 0, 0, tz8), time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, []AlarmHistoryType{AlarmHistoryExpire, AlarmHistoryDone}, utHistoryTypes(records))
+
+	_, err = history.List("Tx", time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, commerr.ErrInvalidArgument)
+}
+
+func TestAlarmHistoryRetention(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "alarm_history")
+	clock := NewFakeClock(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	opts := &AlarmHistoryOptions{MaxRecords: 3, MaxDays: 2}
+
+	history := NewAlarmHistory(fileName, opts, nil, clock)
+
+	for idx := 0; idx < 5; idx++ {
+		assert.Nil(t, history.Record("Aa", &AlarmHistoryRecord{Type: AlarmHistoryNotifySent, NotifyID: string(rune('a' + idx))}))
+		clock.Advance(time.Hour)
+	}
+
+	records, err := history.List("Aa", time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, records, 3)
+	assert.Equal(t, "c", records[0].NotifyID)
+
+	assert.Nil(t, history.Record("Ab", &AlarmHistoryRecord{Type: AlarmHistorySnooze}))
+
+	clock.Advance(47 * time.Hour)
+
+	assert.Nil(t, history.Record("Ab", &AlarmHistoryRecord{Type: AlarmHistoryDone}))
+
+	records, err = history.List("Aa", time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, records, 1)
+
+	// 重启时清理过期的
+	clock.Advance(24 * time.Hour)
+
+	history = NewAlarmHistory(fileName, opts, nil, clock)
+
+	records, err = history.List("Aa", time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, records, 0)
+
+	records, err = history.List("Ab", time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, []AlarmHistoryType{AlarmHistoryDone}, utHistoryTypes(records))
+}
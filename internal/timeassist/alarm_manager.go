@@ -3,17 +3,31 @@ package timeassist
 import (
 	"time"
 
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/sgostarter/i/commerr"
 	"github.com/sgostarter/i/l"
 	"github.com/sgostarter/libeasygo/stg/kv"
 )
 
 type AlarmManager interface {
 	Add(alarm *Alarm) error
+	Update(alarm *Alarm) error
+	Get(id string) (*Alarm, error)
+	List() ([]*Alarm, error)
 	Remove(id string) error
 	Done(id string) error
+	Snooze(id string, minutes int) error
+	Pause(id string, resumeAt time.Time) error
+	Resume(id string) error
+	AddException(id string, exception *AlarmException) error
+	RemoveException(id string, occurrenceAt int64) error
 }
 
-func NewAlarmManager(storage kv.Storage, timer BizTaskTimer, taskList ShowList, logger l.Wrapper) AlarmManager {
+const (
+	DefaultSnoozeMinutes = 10
+)
+
+func NewAlarmManager(storage kv.StorageTiny, timer BizTaskTimer, taskList ShowList, calendar HolidayCalendar, logger l.Wrapper, clock Clock) AlarmManager {
 	if logger == nil {
 		logger = l.NewNopLoggerWrapper()
 	}
@@ -27,6 +41,8 @@ func NewAlarmManager(storage kv.Storage, timer BizTaskTimer, taskList ShowList,
 		storage:  storage,
 		timer:    timer,
 		taskList: taskList,
+		calendar: fixHolidayCalendar(calendar),
+		clock:    fixClock(clock),
 	}
 
 	impl.init()
@@ -36,9 +52,11 @@ func NewAlarmManager(storage kv.Storage, timer BizTaskTimer, taskList ShowList,
 
 type alarmManagerImpl struct {
 	logger   l.Wrapper
-	storage  kv.Storage
+	storage  kv.StorageTiny
 	timer    BizTaskTimer
 	taskList ShowList
+	calendar HolidayCalendar
+	clock    Clock
 }
 
 func (impl *alarmManagerImpl) Add(alarm *Alarm) (err error) {
@@ -48,109 +66,451 @@ func (impl *alarmManagerImpl) Add(alarm *Alarm) (err error) {
 
 	alarm.ID = FixAlarmID(alarm.ID)
 
-	av, timeAt, rd, show, alarmFlag, err := alarm.GenRecycleData()
+	return impl.schedule(alarm)
+}
+
+func (impl *alarmManagerImpl) Update(alarm *Alarm) (err error) {
+	if alarm == nil || ParsePreOnID(alarm.ID) != AlarmIDPre {
+		return commerr.ErrInvalidArgument
+	}
+
+	old, err := impl.Get(alarm.ID)
 	if err != nil {
 		return
 	}
 
-	alarm.TimeLastAt = 0
+	if old == nil {
+		return commerr.ErrNotFound
+	}
+
+	// 暂停状态和例外只通过各自的接口修改
+	alarm.Paused = old.Paused
+	alarm.ResumeAt = old.ResumeAt
+	alarm.Exceptions = old.Exceptions
+
+	// 稍后提醒和升级属于修改前的那一次提醒
+	_ = impl.timer.RemoveTimer(SnoozeTimerID(alarm.ID))
+	_ = impl.timer.RemoveTimer(EscalateTimerID(alarm.ID))
+
+	return impl.schedule(alarm)
+}
+
+func newAlarmShowInfo(alarm *Alarm, av *AlarmValue, timeAt time.Time, alarmFlag bool) *ShowInfo {
+	subTitle, subTitles := newSubTitles(func(lc locale.Locale) string {
+		return av.StringLocale(alarm.AType, timeAt, lc)
+	})
+
+	return &ShowInfo{
+		ID:        alarm.ID,
+		Value:     alarm.Text,
+		SubTitle:  subTitle,
+		SubTitles: subTitles,
+		AlarmFlag: alarmFlag,
+		AlarmAt:   timeAt,
+	}
+}
+
+// newExpiredAlarmShowInfo SubTitle 为过期的那一次 timeLastAt, AlarmAt 为下一次 timeAt
+func newExpiredAlarmShowInfo(alarm *Alarm, av *AlarmValue, timeLastAt, timeAt time.Time) *ShowInfo {
+	subTitle, subTitles := newSubTitles(func(lc locale.Locale) string {
+		return av.StringLocale(alarm.AType, timeLastAt, lc) + lc.T("alarm.expired_suffix")
+	})
+
+	return &ShowInfo{
+		ID:        alarm.ID,
+		Value:     alarm.Text,
+		SubTitle:  subTitle,
+		SubTitles: subTitles,
+		AlarmFlag: true,
+		AlarmAt:   timeAt,
+	}
+}
+
+// schedule 重新计算 alarm 的显示和定时, 与 meta 一起原子地生效
+func (impl *alarmManagerImpl) schedule(alarm *Alarm) (err error) {
+	intent := &Intent{
+		ID: alarm.ID,
+	}
+
+	if alarm.Paused {
+		if _, err = alarm.ValidateEx(impl.clock.Now(), impl.calendar); err != nil {
+			return
+		}
+
+		intent.pause(alarm.ResumeAt)
+		intent.SetAlarm(alarm)
+
+		return impl.timer.ApplyIntent(intent)
+	}
 
-	err = impl.storage.Set(alarm.ID, alarm)
+	intent.TimerAt, intent.TimerData, err = impl.planSchedule(alarm, intent)
 	if err != nil {
 		return
 	}
 
+	return impl.timer.ApplyIntent(intent)
+}
+
+// planSchedule 从现在开始计算显示和 meta 并记录到 intent, 返回周期定时
+func (impl *alarmManagerImpl) planSchedule(alarm *Alarm, intent *Intent) (at time.Time, data *ShowItem, err error) {
+	timeNow := impl.clock.Now()
+
+	av, timeAt, rd, show, alarmFlag, err := alarm.GenRecycleDataEx(timeNow, timeNow, impl.calendar)
+	if err != nil {
+		return
+	}
+
+	alarm.TimeLastAt = 0
+	alarm.Finished = rd == nil
+	alarm.resetSnooze()
+	alarm.pruneExceptions(timeNow)
+
 	if show {
-		_ = impl.taskList.Add(&ShowInfo{
-			ID:        alarm.ID,
-			Value:     alarm.Text,
-			SubTitle:  av.String(alarm.AType, timeAt),
-			AlarmFlag: alarmFlag,
-			AlarmAt:   timeAt,
-		})
+		intent.AddShow(newAlarmShowInfo(alarm, av, timeAt, alarmFlag))
 
 		if rd != nil {
-			err = impl.timer.AddTimer(time.Unix(rd.EndUTC, 0), rd)
-		}
+			at = time.Unix(rd.EndUTC, 0)
+			data = rd
 
-		alarm.TimeLastAt = rd.EndUTC
-		_ = impl.storage.Set(alarm.ID, alarm)
+			alarm.TimeLastAt = rd.EndUTC
+		}
 	} else {
-		err = impl.timer.AddTimer(time.Unix(rd.StartUTC, 0), rd)
+		intent.RemoveShow()
+
+		at = time.Unix(rd.StartUTC, 0)
+		data = rd
+	}
+
+	intent.SetAlarm(alarm)
+
+	return
+}
+
+func (impl *alarmManagerImpl) Get(id string) (alarm *Alarm, err error) {
+	if ParsePreOnID(id) != AlarmIDPre {
+		return
 	}
 
+	alarm = &Alarm{}
+
+	ok, err := impl.storage.Get(id, alarm)
+	if err != nil || !ok {
+		alarm = nil
+	}
+
+	return
+}
+
+func (impl *alarmManagerImpl) List() (alarms []*Alarm, err error) {
+	ids, err := listMetaIDs(impl.storage, AlarmIDPre)
 	if err != nil {
-		_ = impl.storage.Del(alarm.ID)
+		return
+	}
+
+	for _, id := range ids {
+		alarm, e := impl.Get(id)
+		if e != nil || alarm == nil {
+			continue
+		}
+
+		alarms = append(alarms, alarm)
 	}
 
 	return
 }
 
 func (impl *alarmManagerImpl) Remove(id string) error {
+	_ = impl.timer.RemoveTimer(ResumeTimerID(id))
+	_ = impl.timer.RemoveTimer(SnoozeTimerID(id))
+	_ = impl.timer.RemoveTimer(EscalateTimerID(id))
+	_ = impl.timer.RemoveTimer(id)
 	_ = impl.taskList.Remove(id)
 	_ = impl.storage.Del(id)
 
 	return nil
 }
 
-func (impl *alarmManagerImpl) Done(id string) error {
-	alarm := &Alarm{}
+// Done 结束当前的显示和稍后提醒, 提醒不存在或没有在显示时返回 ErrNotFound
+func (impl *alarmManagerImpl) Done(id string) (err error) {
+	alarm, err := impl.Get(id)
+	if err != nil {
+		return
+	}
 
-	ok, err := impl.storage.Get(id, alarm)
-	if err == nil && ok {
-		alarm.TimeLastAt = 0
+	if alarm == nil {
+		return commerr.ErrNotFound
+	}
 
-		_ = impl.storage.Set(id, alarm)
+	alarm.TimeLastAt = 0
+	alarm.resetSnooze()
+
+	_ = impl.storage.Set(id, alarm)
+
+	_ = impl.timer.RemoveTimer(SnoozeTimerID(id))
+	_ = impl.timer.RemoveTimer(EscalateTimerID(id))
+
+	showInfo, err := impl.taskList.Get(id)
+	if err != nil {
+		return
+	}
+
+	if showInfo == nil {
+		return commerr.ErrNotFound
 	}
 
 	return impl.taskList.Remove(id)
 }
 
+// Snooze 隐藏当前显示, minutes 分钟后重新显示, 不影响周期定时
+func (impl *alarmManagerImpl) Snooze(id string, minutes int) (err error) {
+	if minutes <= 0 {
+		minutes = DefaultSnoozeMinutes
+	}
+
+	alarm, err := impl.Get(id)
+	if err != nil {
+		return
+	}
+
+	if alarm == nil {
+		return commerr.ErrNotFound
+	}
+
+	showInfo, err := impl.taskList.Get(id)
+	if err != nil {
+		return
+	}
+
+	if showInfo == nil {
+		return commerr.ErrNotFound
+	}
+
+	if alarm.MaxSnoozeCount > 0 && alarm.SnoozeCount >= alarm.MaxSnoozeCount {
+		return commerr.ErrResourceExhausted
+	}
+
+	// 重新显示时需要重新通知
+	showInfo.NotifyID = ""
+
+	alarm.SnoozeCount++
+	alarm.SnoozeShow = showInfo
+
+	snoozeID := SnoozeTimerID(id)
+	snoozeAt := impl.clock.Now().Add(time.Duration(minutes) * time.Minute)
+
+	intent := &Intent{
+		ID:       snoozeID,
+		TargetID: id,
+		TimerAt:  snoozeAt,
+		TimerData: &ShowItem{
+			ID:       snoozeID,
+			StartUTC: snoozeAt.Unix(),
+			EndUTC:   snoozeAt.Unix(),
+		},
+	}
+
+	intent.RemoveShow()
+	intent.SetAlarm(alarm)
+
+	// 稍后提醒期间不再重新通知, 重新显示后由用户再次处理
+	_ = impl.timer.RemoveTimer(EscalateTimerID(id))
+
+	return impl.timer.ApplyIntent(intent)
+}
+
+// Pause 保留定义, 移除显示和所有定时; resumeAt 不为零时到时自动恢复
+func (impl *alarmManagerImpl) Pause(id string, resumeAt time.Time) (err error) {
+	alarm, err := impl.Get(id)
+	if err != nil {
+		return
+	}
+
+	if alarm == nil {
+		return commerr.ErrNotFound
+	}
+
+	alarm.ResumeAt, err = checkResumeAt(resumeAt, impl.clock.Now())
+	if err != nil {
+		return
+	}
+
+	alarm.Paused = true
+	alarm.TimeLastAt = 0
+	alarm.resetSnooze()
+
+	_ = impl.timer.RemoveTimer(ResumeTimerID(id))
+	_ = impl.timer.RemoveTimer(SnoozeTimerID(id))
+	_ = impl.timer.RemoveTimer(EscalateTimerID(id))
+
+	return impl.schedule(alarm)
+}
+
+// Resume 从现在开始重新计算下一次提醒
+func (impl *alarmManagerImpl) Resume(id string) (err error) {
+	alarm, err := impl.Get(id)
+	if err != nil {
+		return
+	}
+
+	if alarm == nil {
+		return commerr.ErrNotFound
+	}
+
+	if !alarm.Paused {
+		return
+	}
+
+	alarm.Paused = false
+	alarm.ResumeAt = 0
+
+	_ = impl.timer.RemoveTimer(ResumeTimerID(id))
+
+	return impl.schedule(alarm)
+}
+
+// AddException 跳过或改期一次提醒, 从现在开始重新计算下一次提醒
+func (impl *alarmManagerImpl) AddException(id string, exception *AlarmException) (err error) {
+	alarm, err := impl.Get(id)
+	if err != nil {
+		return
+	}
+
+	if alarm == nil {
+		return commerr.ErrNotFound
+	}
+
+	err = alarm.AddException(exception, impl.clock.Now(), impl.calendar)
+	if err != nil {
+		return
+	}
+
+	return impl.schedule(alarm)
+}
+
+func (impl *alarmManagerImpl) RemoveException(id string, occurrenceAt int64) (err error) {
+	alarm, err := impl.Get(id)
+	if err != nil {
+		return
+	}
+
+	if alarm == nil {
+		return commerr.ErrNotFound
+	}
+
+	err = alarm.RemoveException(occurrenceAt)
+	if err != nil {
+		return
+	}
+
+	return impl.schedule(alarm)
+}
+
 func (impl *alarmManagerImpl) init() {
 	impl.timer.SetCallback(AlarmIDPre, impl.timerCb)
 }
 
-func (impl *alarmManagerImpl) timerCb(dRemoved *ShowItem) (at time.Time, data *ShowItem, err error) {
+func (impl *alarmManagerImpl) snoozeTimerCb(alarmID string, intent *Intent) {
+	alarm, err := impl.Get(alarmID)
+	if err != nil || alarm == nil || alarm.SnoozeShow == nil {
+		return
+	}
+
+	intent.TargetID = alarmID
+
+	intent.AddShow(alarm.SnoozeShow)
+
+	alarm.SnoozeShow = nil
+	intent.SetAlarm(alarm)
+}
+
+// resumeTimerCb 自动恢复, 周期定时记录到 intent.Timers
+func (impl *alarmManagerImpl) resumeTimerCb(alarmID string, intent *Intent) {
+	alarm, err := impl.Get(alarmID)
+	if err != nil || alarm == nil || !alarm.Paused {
+		return
+	}
+
+	alarm.Paused = false
+	alarm.ResumeAt = 0
+
+	intent.TargetID = alarmID
+
+	at, data, err := impl.planSchedule(alarm, intent)
+	if err != nil {
+		impl.logger.WithFields(l.ErrorField(err), l.StringField("id", alarmID)).Error("resume alarm failed")
+
+		return
+	}
+
+	if data != nil {
+		intent.AddTimer(&D{Data: data, At: at})
+	}
+}
+
+func (impl *alarmManagerImpl) timerCb(dRemoved *ShowItem, intent *Intent) (at time.Time, data *ShowItem, err error) {
+	if alarmID, ok := ParseSnoozeTimerID(dRemoved.ID); ok {
+		impl.snoozeTimerCb(alarmID, intent)
+
+		return
+	}
+
+	if alarmID, ok := ParseEscalateTimerID(dRemoved.ID); ok {
+		alarm, e := impl.Get(alarmID)
+		if e != nil || alarm == nil {
+			return
+		}
+
+		return escalate(impl.taskList, alarmID, alarm.Escalation, intent, impl.clock.Now())
+	}
+
+	if alarmID, ok := ParseResumeTimerID(dRemoved.ID); ok {
+		impl.resumeTimerCb(alarmID, intent)
+
+		return
+	}
+
 	alarm := &Alarm{}
 
 	ok, err := impl.storage.Get(dRemoved.ID, alarm)
-	if err != nil || !ok {
+	if err != nil || !ok || alarm.Paused {
 		return
 	}
 
-	timeNow := time.Now()
+	timeNow := impl.clock.Now()
 	timeLastAt := timeNow
 
 	if alarm.TimeLastAt > 0 {
 		timeLastAt = time.Unix(alarm.TimeLastAt, 0)
 	}
 
-	av, timeAt, rd, show, alarmFlag, err := alarm.GenRecycleDataEx(timeNow, timeLastAt)
+	av, timeAt, rd, show, alarmFlag, err := alarm.GenRecycleDataEx(timeNow, timeLastAt, impl.calendar)
 	if err != nil {
 		return
 	}
 
 	if alarmFlag {
-		_ = impl.taskList.Add(&ShowInfo{
-			ID:        alarm.ID,
-			Value:     alarm.Text,
-			SubTitle:  av.String(alarm.AType, timeLastAt) + " - 过期",
-			AlarmFlag: alarmFlag,
-			AlarmAt:   timeAt,
-		})
+		// rd 为空表示没有下一次提醒了
+		if alarm.SnoozeShow != nil || rd == nil {
+			alarm.SnoozeShow = nil
+			alarm.Finished = rd == nil
+			intent.SetAlarm(alarm)
+		}
+
+		showInfo := newExpiredAlarmShowInfo(alarm, av, timeLastAt, timeAt)
+
+		intent.AddShow(showInfo)
+		intent.AddTimer(alarm.Escalation.escalationTimer(alarm.ID, timeNow))
+
+		expiredShowInfo := *showInfo
+
+		intent.AddEvent(EventAlarmExpired, alarm.ID, &expiredShowInfo)
 
 		if rd != nil {
 			at = time.Unix(rd.StartUTC, 0)
 			data = rd
 		}
 	} else if show {
-		_ = impl.taskList.Add(&ShowInfo{
-			ID:        alarm.ID,
-			Value:     alarm.Text,
-			SubTitle:  av.String(alarm.AType, timeAt),
-			AlarmFlag: alarmFlag,
-			AlarmAt:   timeAt,
-		})
+		intent.AddShow(newAlarmShowInfo(alarm, av, timeAt, alarmFlag))
 
 		if rd != nil {
 			at = time.Unix(rd.EndUTC, 0)
@@ -158,7 +518,8 @@ func (impl *alarmManagerImpl) timerCb(dRemoved *ShowItem) (at time.Time, data *S
 		}
 
 		alarm.TimeLastAt = rd.EndUTC
-		_ = impl.storage.Set(dRemoved.ID, alarm)
+		alarm.resetSnooze()
+		intent.SetAlarm(alarm)
 	} else {
 		at = time.Unix(rd.StartUTC, 0)
 		data = rd
@@ -0,0 +1,529 @@
+package timeassist
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/libeasygo/stg/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+type utEnv struct {
+	clock        *FakeClock
+	metaStorage  kv.StorageTiny
+	bus          EventBus
+	timer        TaskTimer
+	showList     ShowList
+	alarmManager AlarmManager
+	taskManager  TaskManager
+}
+
+func utNewEnv(t *testing.T, now time.Time, ob ShowInfoListChangeObserver) *utEnv {
+	dir := t.TempDir()
+
+	env := &utEnv{
+		clock: NewFakeClock(now),
+	}
+
+	var err error
+
+	env.metaStorage, err = kv.NewMemoryFileStorageEx(filepath.Join(dir, "task_meta"), false)
+	assert.Nil(t, err)
+
+	env.bus = NewEventBus(0, env.clock)
+	env.showList = NewShowList(filepath.Join(dir, "task_list"), ob, env.bus, env.clock)
+	env.timer = NewTaskTimer(filepath.Join(dir, "task_timer"),
+		NewIntentJournal(filepath.Join(dir, "task_journal"), env.metaStorage, env.showList), env.bus, env.clock)
+
+	bizTimer := NewBizTimer(env.timer)
+
+	env.alarmManager = NewAlarmManager(env.metaStorage, bizTimer, env.showList, nil, nil, env.clock)
+	env.taskManager = NewTaskManager(env.metaStorage, bizTimer, env.showList, nil, nil, env.clock)
+
+	return env
+}
+
+func (env *utEnv) timerAt(t *testing.T, id string) (at time.Time, ok bool) {
+	items, err := env.timer.List()
+	assert.Nil(t, err)
+
+	for _, item := range items {
+		if item.Data.ID == id {
+			return item.At, true
+		}
+	}
+
+	return
+}
+
+func TestAlarmManagerUpdate(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeDay,
+		Text:     "early",
+		Value:    "083000",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	id := alarm.ID
+
+	showInfo, err := env.showList.Get(id)
+	assert.Nil(t, err)
+	assert.NotNil(t, showInfo)
+
+	at, ok := env.timerAt(t, id)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 2, 8, 30, 0, 0, tz8)))
+
+	err = env.alarmManager.Update(&Alarm{
+		ID:       id,
+		AType:    RecycleTimeTypeDay,
+		Text:     "late",
+		Value:    "200000",
+		TimeZone: 8,
+	})
+	assert.Nil(t, err)
+
+	showInfo, err = env.showList.Get(id)
+	assert.Nil(t, err)
+	assert.Nil(t, showInfo)
+
+	at, ok = env.timerAt(t, id)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 2, 19, 0, 0, 0, tz8)))
+
+	stored, err := env.alarmManager.Get(id)
+	assert.Nil(t, err)
+	assert.Equal(t, "late", stored.Text)
+
+	alarms, err := env.alarmManager.List()
+	assert.Nil(t, err)
+	assert.Len(t, alarms, 1)
+
+	err = env.alarmManager.Update(&Alarm{
+		ID:    FixAlarmID(""),
+		AType: RecycleTimeTypeDay,
+		Text:  "none",
+		Value: "200000",
+	})
+	assert.ErrorIs(t, err, commerr.ErrNotFound)
+}
+
+func TestTaskManagerUpdate(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+
+	task := &Task{
+		TType:    RecycleTimeTypeDay,
+		Value:    1,
+		Text:     "daily",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+
+	at, ok := env.timerAt(t, task.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 3, 0, 0, 0, 0, tz8)))
+
+	task.TType = RecycleTimeTypeWeek
+	task.Text = "weekly"
+
+	assert.Nil(t, env.taskManager.Update(task))
+
+	at, ok = env.timerAt(t, task.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 8, 0, 0, 0, 0, tz8)))
+
+	showInfo, err := env.showList.Get(task.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "weekly", showInfo.Value)
+
+	tasks, err := env.taskManager.List()
+	assert.Nil(t, err)
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, task.ID, tasks[0].ID)
+
+	assert.Nil(t, env.taskManager.Remove(task.ID))
+
+	_, ok = env.timerAt(t, task.ID)
+	assert.False(t, ok)
+}
+
+func TestAlarmManagerSnooze(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+
+	var notifyCount int
+
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), func(_ *ShowInfo, visible bool) {
+		if visible {
+			notifyCount++
+		}
+	})
+
+	alarm := &Alarm{
+		AType:          RecycleTimeTypeDay,
+		Text:           "standup",
+		Value:          "083000",
+		TimeZone:       8,
+		MaxSnoozeCount: 1,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+	assert.Equal(t, 1, notifyCount)
+
+	assert.Nil(t, env.alarmManager.Snooze(alarm.ID, 5))
+
+	showInfo, err := env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, showInfo)
+
+	at, ok := env.timerAt(t, SnoozeTimerID(alarm.ID))
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 2, 8, 5, 0, 0, tz8)))
+
+	at, ok = env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 2, 8, 30, 0, 0, tz8)))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 2, 8, 10, 0, 0, tz8))
+
+	showInfo, err = env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.NotNil(t, showInfo)
+	assert.Equal(t, 2, notifyCount)
+
+	_, ok = env.timerAt(t, SnoozeTimerID(alarm.ID))
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, env.alarmManager.Snooze(alarm.ID, 5), commerr.ErrResourceExhausted)
+
+	// 下一次提醒重置次数
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 3, 8, 0, 0, 0, tz8))
+
+	assert.Nil(t, env.alarmManager.Snooze(alarm.ID, 5))
+}
+
+func TestGetNotifyRoute(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 1, 1, 0, 0, 0, 0, tz8), nil)
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeDay,
+		Text:     "drink",
+		Value:    "090000",
+		TimeZone: 8,
+		Notify:   &NotifyRoute{Backends: []string{"mail"}, Template: "short"},
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	task := &Task{
+		TType:    RecycleTimeTypeDay,
+		Value:    1,
+		Text:     "daily",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+
+	route, err := GetNotifyRoute(env.metaStorage, &ShowInfo{ID: alarm.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, alarm.Notify, route)
+
+	route, err = GetNotifyRoute(env.metaStorage, &ShowInfo{ID: task.ID})
+	assert.Nil(t, err)
+	assert.Nil(t, route)
+
+	route, err = GetNotifyRoute(env.metaStorage, &ShowInfo{ID: "Anotexist"})
+	assert.Nil(t, err)
+	assert.Nil(t, route)
+}
+
+func TestAlarmManagerEscalation(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+
+	notifyIDs := make(map[string]int)
+
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), func(showInfo *ShowInfo, visible bool) {
+		if visible {
+			notifyIDs[showInfo.NotifyID] = showInfo.EscalationCount
+		}
+	})
+
+	switchRoute := &NotifyRoute{Receivers: []string{"users"}}
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeDay,
+		Text:     "standup",
+		Value:    "083000",
+		TimeZone: 8,
+		Notify:   &NotifyRoute{Receivers: []string{"admin_users"}},
+		Escalation: &Escalation{
+			IntervalMinutes: 10,
+			MaxTimes:        2,
+			SwitchAfter:     2,
+			SwitchRoute:     switchRoute,
+		},
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 2, 8, 35, 0, 0, tz8))
+	assert.Len(t, notifyIDs, 2)
+
+	_, ok := env.timerAt(t, EscalateTimerID(alarm.ID))
+	assert.True(t, ok)
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 2, 8, 45, 0, 0, tz8))
+	assert.Len(t, notifyIDs, 3)
+
+	showInfo, err := env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.True(t, showInfo.AlarmFlag)
+	assert.Equal(t, 1, showInfo.EscalationCount)
+
+	route, err := GetNotifyRoute(env.metaStorage, showInfo)
+	assert.Nil(t, err)
+	assert.Equal(t, alarm.Notify, route)
+
+	// 达到 MaxTimes 后不再通知
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 2, 12, 0, 0, 0, tz8))
+	assert.Len(t, notifyIDs, 4)
+
+	showInfo, err = env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, showInfo.EscalationCount)
+
+	route, err = GetNotifyRoute(env.metaStorage, showInfo)
+	assert.Nil(t, err)
+	assert.Equal(t, switchRoute, route)
+
+	_, ok = env.timerAt(t, EscalateTimerID(alarm.ID))
+	assert.False(t, ok)
+}
+
+func TestTaskManagerEscalationDone(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+
+	var notifyCount int
+
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), func(showInfo *ShowInfo, visible bool) {
+		if visible && showInfo.EscalationCount > 0 {
+			notifyCount++
+		}
+	})
+
+	task := &Task{
+		TType:      RecycleTimeTypeDay,
+		Value:      1,
+		Text:       "daily",
+		TimeZone:   8,
+		Escalation: &Escalation{IntervalMinutes: 10, MaxTimes: 5},
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 3, 0, 15, 0, 0, tz8))
+	assert.Equal(t, 1, notifyCount)
+
+	showInfo, err := env.showList.Get(task.ID)
+	assert.Nil(t, err)
+	assert.True(t, showInfo.AlarmFlag)
+
+	assert.Nil(t, env.taskManager.Done(task.ID))
+
+	_, ok := env.timerAt(t, EscalateTimerID(task.ID))
+	assert.False(t, ok)
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 3, 1, 0, 0, 0, tz8))
+	assert.Equal(t, 1, notifyCount)
+}
+
+func TestManagerUpdateRemoveTimers(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+
+	alarm := &Alarm{
+		AType:      RecycleTimeTypeDay,
+		Text:       "standup",
+		Value:      "083000",
+		TimeZone:   8,
+		Escalation: &Escalation{IntervalMinutes: 10, MaxTimes: 2},
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 2, 8, 35, 0, 0, tz8))
+
+	_, ok := env.timerAt(t, EscalateTimerID(alarm.ID))
+	assert.True(t, ok)
+
+	assert.Nil(t, env.alarmManager.Snooze(alarm.ID, 5))
+
+	_, ok = env.timerAt(t, SnoozeTimerID(alarm.ID))
+	assert.True(t, ok)
+
+	alarm.Value = "200000"
+	assert.Nil(t, env.alarmManager.Update(alarm))
+
+	_, ok = env.timerAt(t, SnoozeTimerID(alarm.ID))
+	assert.False(t, ok)
+
+	_, ok = env.timerAt(t, EscalateTimerID(alarm.ID))
+	assert.False(t, ok)
+
+	task := &Task{
+		TType:      RecycleTimeTypeDay,
+		Value:      1,
+		Text:       "daily",
+		TimeZone:   8,
+		Escalation: &Escalation{IntervalMinutes: 10, MaxTimes: 5},
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 3, 0, 15, 0, 0, tz8))
+
+	_, ok = env.timerAt(t, EscalateTimerID(task.ID))
+	assert.True(t, ok)
+
+	task.Text = "weekly"
+	task.TType = RecycleTimeTypeWeek
+	assert.Nil(t, env.taskManager.Update(task))
+
+	_, ok = env.timerAt(t, EscalateTimerID(task.ID))
+	assert.False(t, ok)
+}
+
+func TestAlarmManagerPause(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+
+	var notifyCount int
+
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), func(_ *ShowInfo, visible bool) {
+		if visible {
+			notifyCount++
+		}
+	})
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeDay,
+		Text:     "standup",
+		Value:    "083000",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+	assert.Equal(t, 1, notifyCount)
+
+	assert.ErrorIs(t, env.alarmManager.Pause("Anotexist", time.Time{}), commerr.ErrNotFound)
+	assert.ErrorIs(t, env.alarmManager.Pause(alarm.ID, time.Date(2026, 3, 1, 0, 0, 0, 0, tz8)), commerr.ErrInvalidArgument)
+
+	resumeAt := time.Date(2026, 3, 3, 12, 0, 0, 0, tz8)
+	assert.Nil(t, env.alarmManager.Pause(alarm.ID, resumeAt))
+
+	showInfo, err := env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, showInfo)
+
+	_, ok := env.timerAt(t, alarm.ID)
+	assert.False(t, ok)
+
+	at, ok := env.timerAt(t, ResumeTimerID(alarm.ID))
+	assert.True(t, ok)
+	assert.True(t, at.Equal(resumeAt))
+
+	// 修改定义不会恢复
+	alarm.Text = "daily standup"
+	assert.Nil(t, env.alarmManager.Update(alarm))
+
+	paused, err := env.alarmManager.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.True(t, paused.Paused)
+	assert.Equal(t, resumeAt.Unix(), paused.ResumeAt)
+	assert.Equal(t, "daily standup", paused.Text)
+
+	_, ok = env.timerAt(t, alarm.ID)
+	assert.False(t, ok)
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 3, 11, 0, 0, 0, tz8))
+	assert.Equal(t, 1, notifyCount)
+
+	// 自动恢复后从现在开始计算下一次提醒
+	utRunTimerUntil(t, env.clock, env.timer, resumeAt)
+
+	resumed, err := env.alarmManager.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.False(t, resumed.Paused)
+	assert.Zero(t, resumed.ResumeAt)
+
+	_, ok = env.timerAt(t, ResumeTimerID(alarm.ID))
+	assert.False(t, ok)
+
+	_, ok = env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 4, 8, 0, 0, 0, tz8))
+	assert.Equal(t, 2, notifyCount)
+
+	// 手动恢复
+	assert.Nil(t, env.alarmManager.Pause(alarm.ID, time.Time{}))
+
+	_, ok = env.timerAt(t, ResumeTimerID(alarm.ID))
+	assert.False(t, ok)
+
+	assert.Nil(t, env.alarmManager.Resume(alarm.ID))
+	assert.Equal(t, 3, notifyCount)
+
+	showInfo, err = env.showList.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.NotNil(t, showInfo)
+
+	_, ok = env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+}
+
+func TestTaskManagerPause(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+
+	task := &Task{
+		TType:    RecycleTimeTypeDay,
+		Value:    1,
+		Text:     "daily",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+	assert.Nil(t, env.taskManager.Pause(task.ID, time.Time{}))
+
+	showInfo, err := env.showList.Get(task.ID)
+	assert.Nil(t, err)
+	assert.Nil(t, showInfo)
+
+	env.taskManager.TaskDone(task.ID)
+
+	_, ok := env.timerAt(t, task.ID)
+	assert.False(t, ok)
+
+	env.clock.Set(time.Date(2026, 3, 4, 9, 0, 0, 0, tz8))
+
+	assert.Nil(t, env.taskManager.Resume(task.ID))
+
+	showInfo, err = env.showList.Get(task.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 3, 4, 0, 0, 0, 0, tz8).Unix(), showInfo.StartUTC)
+
+	at, ok := env.timerAt(t, task.ID)
+	assert.True(t, ok)
+	assert.True(t, at.Equal(time.Date(2026, 3, 5, 0, 0, 0, 0, tz8)))
+
+	assert.ErrorIs(t, env.taskManager.Resume("Tnotexist"), commerr.ErrNotFound)
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/s-min-sys/timeassistbe/internal/locale"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -260,9 +261,60 @@ func TestAlarm_GenRecycleDataEx(t *testing.T) {
 				Value:    tt.fields.Value,
 				TimeZone: tt.fields.TimeZone,
 			}
-			_, _, gotRd, gotNowIsValid, _, _ := a.GenRecycleDataEx(tt.args.timeNow, tt.args.timeNow)
+			_, _, gotRd, gotNowIsValid, _, _ := a.GenRecycleDataEx(tt.args.timeNow, tt.args.timeNow, nil)
 			assert.Equalf(t, tt.wantRd, gotRd, "GenRecycleDataEx(%v)", tt.args.timeNow)
 			assert.Equalf(t, tt.wantNowIsValid, gotNowIsValid, "GenRecycleDataEx(%v)", tt.args.timeNow)
 		})
 	}
 }
+
+func TestAlarmValueStringLocale(t *testing.T) {
+	av, err := ParseAlarmValue("S15200000", RecycleTimeTypeMonth)
+	assert.Nil(t, err)
+
+	_, s := av.StringNoNowTime(RecycleTimeTypeMonth)
+	assert.Equal(t, "阳历每月15日20时00分00秒", s)
+
+	_, s = av.StringNoNowTimeLocale(RecycleTimeTypeMonth, locale.EnUS)
+	assert.Equal(t, "every month on day 15 at 20:00:00", s)
+
+	av, err = ParseAlarmValue("3090000", RecycleTimeTypeWeek)
+	assert.Nil(t, err)
+
+	timeAt := time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, "every Wednesday at 09:00:00[2026-03-04 09:00:00]", av.StringLocale(RecycleTimeTypeWeek, timeAt, locale.EnUS))
+
+	av, err = ParseAlarmValue("L20260815200000", TimeTypeOnce)
+	assert.Nil(t, err)
+
+	_, s = av.StringNoNowTimeLocale(TimeTypeOnce, locale.EnUS)
+	assert.Equal(t, "lunar 2026-08-15 20:00:00", s)
+
+	task := &Task{TType: RecycleTimeTypeDay, Value: 2, Auto: true}
+	assert.Equal(t, "2 天一次 自动过期刷新", task.Desc())
+	assert.Equal(t, "every 2 day(s) auto refresh", task.DescLocale(locale.EnUS))
+}
+
+func TestShowInfoLocalize(t *testing.T) {
+	timeNow := time.Date(2026, 3, 4, 7, 0, 0, 0, time.UTC)
+
+	av, err := ParseAlarmValue("090000", RecycleTimeTypeDay)
+	assert.Nil(t, err)
+
+	showInfo := newExpiredAlarmShowInfo(&Alarm{ID: "Aa", AType: RecycleTimeTypeDay, Text: "起床"}, av,
+		time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC))
+	showInfo.AutoFill(timeNow)
+
+	assert.Equal(t, "每日09时00分00秒[2026年03月03日09时00分00秒] - 过期", showInfo.SubTitle)
+	assert.Equal(t, "2小时0分", showInfo.LeftTimeS)
+
+	localized := showInfo.Localize(locale.EnUS, timeNow)
+	assert.Equal(t, "every day at 09:00:00[2026-03-03 09:00:00] - expired", localized.SubTitle)
+	assert.Equal(t, "2h 0m", localized.LeftTimeS)
+	assert.Nil(t, localized.SubTitles)
+	assert.NotNil(t, showInfo.SubTitles)
+
+	// 旧数据没有 SubTitles 时保持原样
+	showInfo.SubTitles = nil
+	assert.Equal(t, showInfo.SubTitle, showInfo.Localize(locale.EnUS, timeNow).SubTitle)
+}
@@ -0,0 +1,693 @@
+package timeassist
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+// 自然语言描述转换为 Alarm, 如 "每周三早上9点 开会", "every day at 7:30", "in 45 minutes"
+// 先识别日期/周期, 再在剩下的部分识别时刻, 其余内容作为 Alarm.Text
+
+const cnNum = `[0-9零〇一二两三四五六七八九十廿卅]+`
+
+type alarmTextKind int
+
+const (
+	alarmTextOnceAt    alarmTextKind = iota + 1 // 只有时刻, 下一次出现的时间
+	alarmTextOnceAfter                          // N 分钟后
+	alarmTextOnceDay                            // 今天/明天 + 时刻
+	alarmTextOnceDate                           // [年]月日 + 时刻
+	alarmTextDay
+	alarmTextWeek
+	alarmTextMonth
+	alarmTextYear
+	alarmTextHour
+)
+
+type alarmTextResult struct {
+	kind      alarmTextKind
+	lunar     bool
+	year      int
+	month     int
+	day       int // 月份中的日期, -1/-2/-3 为倒数
+	week      int
+	dayOffset int
+	after     time.Duration
+	minute    int // 每小时的第几分
+	validTime *ValidTime
+
+	evening bool // tonight, 没有 am/pm 时按晚上
+
+	hasTime              bool
+	hour, min            int
+	dateMatch, timeMatch string
+}
+
+type alarmTextRule struct {
+	re    *regexp.Regexp
+	apply func(r *alarmTextResult, m []string) bool
+}
+
+var (
+	cnWeekdays = map[string]int{"日": 0, "天": 0, "7": 0, "一": 1, "1": 1, "二": 2, "2": 2, "三": 3, "3": 3,
+		"四": 4, "4": 4, "五": 5, "5": 5, "六": 6, "6": 6}
+	cnLunarMonths = map[string]int{"正": 1, "冬": 11, "腊": 12}
+
+	enWeekdays = map[string]int{"sunday": 0, "sun": 0, "monday": 1, "mon": 1, "tuesday": 2, "tue": 2, "tues": 2,
+		"wednesday": 3, "wed": 3, "thursday": 4, "thu": 4, "thur": 4, "thurs": 4, "friday": 5, "fri": 5,
+		"saturday": 6, "sat": 6}
+	enMonths = map[string]int{"january": 1, "jan": 1, "february": 2, "feb": 2, "march": 3, "mar": 3, "april": 4,
+		"apr": 4, "may": 5, "june": 6, "jun": 6, "july": 7, "jul": 7, "august": 8, "aug": 8, "september": 9,
+		"sep": 9, "sept": 9, "october": 10, "oct": 10, "november": 11, "nov": 11, "december": 12, "dec": 12}
+)
+
+const (
+	enWeekdayPattern = `(sunday|monday|tuesday|wednesday|thursday|friday|saturday|sun|mon|tues|tue|wed|thurs|thur|thu|fri|sat)`
+	enMonthPattern   = `(january|february|march|april|may|june|july|august|september|october|november|december|` +
+		`jan|feb|mar|apr|jun|jul|aug|sept|sep|oct|nov|dec)`
+	enOrdinal    = `(?:st|nd|rd|th)?`
+	cnLunarDay   = `(初[一二三四五六七八九十]|二十[一二三四五六七八九]?|三十|十[一二三四五六七八九]?|廿[一二三四五六七八九]?|[0-9]{1,2})`
+	cnLunarMonth = `(正|冬|腊|十[一二]?|[一二三四五六七八九]|[0-9]{1,2})`
+)
+
+func weekendValidTime() *ValidTime {
+	return &ValidTime{ValidDaysInWeek: &ValidRanges{ValidRanges: []ValidRange{{Start: 0, End: 1}, {Start: 6, End: 7}}}}
+}
+
+// alarmTextDateRules 按顺序匹配, 第一个匹配的生效
+var alarmTextDateRules = []alarmTextRule{
+	// 中文
+	{regexp.MustCompile(`(` + cnNum + `|半)\s*个?\s*(分钟|小时|钟头|天)\s*(?:以后|之后|后)`), func(r *alarmTextResult, m []string) bool {
+		unit := map[string]time.Duration{"分钟": time.Minute, "小时": time.Hour, "钟头": time.Hour, "天": 24 * time.Hour}[m[2]]
+
+		r.kind = alarmTextOnceAfter
+
+		if m[1] == "半" {
+			r.after = unit / 2
+
+			return true
+		}
+
+		n, ok := parseCNNumber(m[1])
+		r.after = time.Duration(n) * unit
+
+		return ok && n > 0
+	}},
+	{regexp.MustCompile(`每个?工作日`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextDay
+		r.validTime = &ValidTime{OnlyWorkDays: true}
+
+		return true
+	}},
+	{regexp.MustCompile(`每个?周末|周末`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextDay
+		r.validTime = weekendValidTime()
+
+		return true
+	}},
+	{regexp.MustCompile(`每天|每日|天天`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextDay
+
+		return true
+	}},
+	{regexp.MustCompile(`每个?(?:周|星期|礼拜)([一二三四五六日天1-7])`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextWeek
+		r.week = cnWeekdays[m[1]]
+
+		return true
+	}},
+	// 阴历日期一般是节日和生日, 没有 "每年" 时也按每年处理
+	{regexp.MustCompile(`(?:每年)?\s*(?:农历|阴历)\s*(?:每年)?\s*` + cnLunarMonth + `月\s*` + cnLunarDay + `[日号]?`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextYear
+		r.lunar = true
+
+		return parseCNLunarMonth(m[1], &r.month) && parseCNLunarDay(m[2], &r.day)
+	}},
+	{regexp.MustCompile(`(?:每月\s*(?:农历|阴历)|(?:农历|阴历)\s*每月)\s*` + cnLunarDay + `[日号]?`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextMonth
+		r.lunar = true
+
+		return parseCNLunarDay(m[1], &r.day)
+	}},
+	{regexp.MustCompile(`每个?月的?\s*(?:(最后一天)|倒数第([一二三1-3])天|(` + cnNum + `)\s*[号日])`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextMonth
+
+		switch {
+		case m[1] != "":
+			r.day = -1
+		case m[2] != "":
+			n, _ := parseCNNumber(m[2])
+			r.day = -n
+		default:
+			var ok bool
+
+			if r.day, ok = parseCNNumber(m[3]); !ok {
+				return false
+			}
+		}
+
+		return true
+	}},
+	{regexp.MustCompile(`每年\s*(` + cnNum + `)\s*月\s*(` + cnNum + `)\s*[号日]?`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextYear
+
+		return parseCNNumberTo(m[1], &r.month) && parseCNNumberTo(m[2], &r.day)
+	}},
+	{regexp.MustCompile(`每小时的?第?\s*(` + cnNum + `)\s*分`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextHour
+
+		return parseCNNumberTo(m[1], &r.minute)
+	}},
+	{regexp.MustCompile(`每小时|每个小时`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextHour
+
+		return true
+	}},
+	{regexp.MustCompile(`(?:([0-9]{4})\s*年)?\s*(` + cnNum + `)\s*月\s*(` + cnNum + `)\s*[号日]`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextOnceDate
+
+		if m[1] != "" {
+			r.year, _ = strconv.Atoi(m[1])
+		}
+
+		return parseCNNumberTo(m[2], &r.month) && parseCNNumberTo(m[3], &r.day)
+	}},
+	{regexp.MustCompile(`大后天|后天|明天|今天|今晚`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextOnceDay
+		r.dayOffset = map[string]int{"今天": 0, "今晚": 0, "明天": 1, "后天": 2, "大后天": 3}[m[0]]
+
+		// 今晚 同时表示时段
+		if m[0] == "今晚" {
+			r.dateMatch = ""
+		}
+
+		return true
+	}},
+
+	// 英文, 已经转为小写
+	{regexp.MustCompile(`\bin\s+(\d+|an?|half an?)\s*(minutes?|mins?|hours?|hrs?|days?)\b`), func(r *alarmTextResult, m []string) bool {
+		n := 1
+		half := strings.HasPrefix(m[1], "half")
+
+		if !half && m[1] != "a" && m[1] != "an" {
+			var err error
+
+			if n, err = strconv.Atoi(m[1]); err != nil || n <= 0 {
+				return false
+			}
+		}
+
+		unit := time.Minute
+
+		switch {
+		case strings.HasPrefix(m[2], "h"):
+			unit = time.Hour
+		case strings.HasPrefix(m[2], "d"):
+			unit = 24 * time.Hour
+		}
+
+		r.kind = alarmTextOnceAfter
+		r.after = time.Duration(n) * unit
+
+		if half {
+			r.after = unit / 2
+		}
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:every\s+weekday|on\s+weekdays|weekdays)\b`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextDay
+		r.validTime = &ValidTime{ValidDaysInWeek: &ValidRanges{ValidRanges: []ValidRange{{Start: 1, End: 6}}}}
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:every\s+weekend|on\s+weekends|weekends)\b`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextDay
+		r.validTime = weekendValidTime()
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:every\s*day|daily)\b`), func(r *alarmTextResult, _ []string) bool {
+		r.kind = alarmTextDay
+
+		return true
+	}},
+	{regexp.MustCompile(`\bevery\s+` + enWeekdayPattern + `\b`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextWeek
+		r.week = enWeekdays[m[1]]
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:every\s+month|monthly)\s+on\s+the\s+(?:(last)|(second)\s+to\s+last|(third)\s+to\s+last|(\d{1,2})` +
+		enOrdinal + `)(?:\s+day)?\b`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextMonth
+
+		switch {
+		case m[1] != "":
+			r.day = -1
+		case m[2] != "":
+			r.day = -2
+		case m[3] != "":
+			r.day = -3
+		default:
+			r.day, _ = strconv.Atoi(m[4])
+		}
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:every\s+year|yearly|annually)\s+on\s+` + enMonthPattern + `\s+(\d{1,2})` + enOrdinal + `\b`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextYear
+		r.month = enMonths[m[1]]
+		r.day, _ = strconv.Atoi(m[2])
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:every\s+hour|hourly)(?:\s+at\s+:(\d{2}))?`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextHour
+
+		if m[1] != "" {
+			r.minute, _ = strconv.Atoi(m[1])
+		}
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(?:on\s+)?` + enMonthPattern + `\s+(\d{1,2})` + enOrdinal + `(?:,?\s+(\d{4}))?\b`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextOnceDate
+		r.month = enMonths[m[1]]
+		r.day, _ = strconv.Atoi(m[2])
+
+		if m[3] != "" {
+			r.year, _ = strconv.Atoi(m[3])
+		}
+
+		return true
+	}},
+	{regexp.MustCompile(`\b(today|tonight|tomorrow)\b`), func(r *alarmTextResult, m []string) bool {
+		r.kind = alarmTextOnceDay
+
+		switch m[1] {
+		case "tomorrow":
+			r.dayOffset = 1
+		case "tonight":
+			r.evening = true
+		}
+
+		return true
+	}},
+}
+
+var (
+	cnTimeRe = regexp.MustCompile(`(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上|夜里|夜间|半夜|今晚)?\s*(` + cnNum + `)\s*(?:点钟?|时|:)\s*(?:(半)|(` +
+		cnNum + `)\s*分?)?`)
+	enTimeRe = regexp.MustCompile(`(?:\bat\s+)?\b(?:(noon)|(midnight)|(\d{1,2})(?::(\d{2}))?\s*(am|pm|a\.m\.|p\.m\.)|(\d{1,2}):(\d{2})|at\s+(\d{1,2}))\b`)
+
+	alarmTextLeadingRe  = regexp.MustCompile(`(?i)^(?:提醒我|提醒|叫我|的|(?:remind\s+me\s+to|remind\s+me|at|on|to)(?:\s|$))`)
+	alarmTextTrailingRe = regexp.MustCompile(`(?i)(?:\s(?:at|on)|的)$`)
+)
+
+const alarmTextCutset = " \t,，.。、:：;；!！"
+
+func (r *alarmTextResult) setTime(hour, minute int) error {
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return fmt.Errorf("%w: invalid time %q", commerr.ErrBadFormat, r.timeMatch)
+	}
+
+	r.hasTime = true
+	r.hour, r.min = hour, minute
+
+	return nil
+}
+
+// parseTime 中文的时刻优先, 只有 H:MM 时交给英文规则以识别 am/pm
+func (r *alarmTextResult) parseTime(s string) error {
+	if m := cnTimeRe.FindStringSubmatch(s); m != nil && (m[1] != "" || !strings.Contains(m[0], ":")) {
+		return r.parseCNTime(m)
+	}
+
+	if m := enTimeRe.FindStringSubmatch(s); m != nil {
+		return r.parseENTime(m)
+	}
+
+	return nil
+}
+
+func (r *alarmTextResult) parseCNTime(m []string) error {
+	r.timeMatch = m[0]
+
+	hour, ok := parseCNNumber(m[2])
+	if !ok {
+		return fmt.Errorf("%w: invalid time %q", commerr.ErrBadFormat, m[0])
+	}
+
+	minute := 0
+
+	switch {
+	case m[3] != "":
+		minute = 30
+	case m[4] != "":
+		if minute, ok = parseCNNumber(m[4]); !ok {
+			return fmt.Errorf("%w: invalid time %q", commerr.ErrBadFormat, m[0])
+		}
+	}
+
+	switch m[1] {
+	case "下午", "傍晚", "今晚":
+		if hour < 12 {
+			hour += 12
+		}
+	case "晚上", "夜里", "夜间":
+		// 晚上12点为零点
+		if hour == 12 {
+			hour = 0
+		} else if hour < 12 {
+			hour += 12
+		}
+	case "中午":
+		if hour < 11 {
+			hour += 12
+		}
+	case "凌晨", "半夜":
+		if hour == 12 {
+			hour = 0
+		}
+	}
+
+	return r.setTime(hour, minute)
+}
+
+func (r *alarmTextResult) parseENTime(m []string) error {
+	r.timeMatch = m[0]
+
+	switch {
+	case m[1] != "":
+		return r.setTime(12, 0)
+	case m[2] != "":
+		return r.setTime(0, 0)
+	case m[3] != "":
+		hour, _ := strconv.Atoi(m[3])
+		minute, _ := strconv.Atoi(m[4])
+
+		if hour < 1 || hour > 12 {
+			return fmt.Errorf("%w: invalid time %q", commerr.ErrBadFormat, m[0])
+		}
+
+		if strings.HasPrefix(m[5], "p") && hour < 12 {
+			hour += 12
+		} else if strings.HasPrefix(m[5], "a") && hour == 12 {
+			hour = 0
+		}
+
+		return r.setTime(hour, minute)
+	case m[6] != "":
+		hour, _ := strconv.Atoi(m[6])
+		minute, _ := strconv.Atoi(m[7])
+
+		if r.evening && hour < 12 {
+			hour += 12
+		}
+
+		return r.setTime(hour, minute)
+	default:
+		hour, _ := strconv.Atoi(m[8])
+
+		if r.evening && hour < 12 {
+			hour += 12
+		}
+
+		return r.setTime(hour, 0)
+	}
+}
+
+// ParseAlarmText location/timeZone 同 Alarm; 解析出的 Alarm 已经通过 Validate, 可以直接添加
+func ParseAlarmText(text string, location string, timeZone int, timeNow time.Time) (alarm *Alarm, err error) {
+	loc, err := TimeLocation(location, timeZone)
+	if err != nil {
+		return
+	}
+
+	// 规则匹配小写的 s, Text 从保留大小写的 origin 中截取
+	origin := normalizeAlarmText(text)
+	s := strings.ToLower(origin)
+
+	if len(s) != len(origin) {
+		origin = s
+	}
+
+	r := &alarmTextResult{}
+
+	for _, rule := range alarmTextDateRules {
+		m := rule.re.FindStringSubmatch(s)
+		if m == nil {
+			continue
+		}
+
+		r.dateMatch = m[0]
+
+		if !rule.apply(r, m) {
+			return nil, fmt.Errorf("%w: invalid date %q", commerr.ErrBadFormat, m[0])
+		}
+
+		break
+	}
+
+	s, origin = cutAlarmText(s, origin, r.dateMatch)
+
+	if err = r.parseTime(s); err != nil {
+		return
+	}
+
+	s, origin = cutAlarmText(s, origin, r.timeMatch)
+
+	if r.kind == 0 {
+		if !r.hasTime {
+			return nil, fmt.Errorf("%w: no date or time in %q", commerr.ErrBadFormat, text)
+		}
+
+		r.kind = alarmTextOnceAt
+	}
+
+	if !r.hasTime && r.kind != alarmTextOnceAfter && r.kind != alarmTextHour {
+		return nil, fmt.Errorf("%w: no time in %q", commerr.ErrBadFormat, text)
+	}
+
+	alarm = &Alarm{
+		Text:      cleanAlarmText(origin),
+		TimeZone:  timeZone,
+		Location:  location,
+		ValidTime: r.validTime,
+	}
+
+	if alarm.Text == "" {
+		alarm.Text = strings.TrimSpace(text)
+	}
+
+	if err = r.fill(alarm, timeNow.In(loc), loc); err != nil {
+		return nil, err
+	}
+
+	if _, err = alarm.Validate(); err != nil {
+		return nil, err
+	}
+
+	return alarm, nil
+}
+
+func (r *alarmTextResult) fill(alarm *Alarm, timeNow time.Time, loc *time.Location) error {
+	prefix := "S"
+	if r.lunar {
+		prefix = "L"
+	}
+
+	hms := fmt.Sprintf("%02d%02d00", r.hour, r.min)
+
+	fnOnce := func(t time.Time) error {
+		if !t.After(timeNow) {
+			return fmt.Errorf("%w: %s is in the past", commerr.ErrOutOfRange, t.Format(time.RFC3339))
+		}
+
+		alarm.AType = TimeTypeOnce
+		alarm.Value = "S" + t.Format("20060102150405")
+
+		return nil
+	}
+
+	fnDate := func(year, month, day int) time.Time {
+		return ToDateTime(year, month, day, r.hour, r.min, 0, loc)
+	}
+
+	switch r.kind {
+	case alarmTextOnceAfter:
+		t := timeNow.Add(r.after).Truncate(time.Second)
+		if r.hasTime {
+			t = fnDate(t.Year(), int(t.Month()), t.Day())
+		}
+
+		return fnOnce(t)
+	case alarmTextOnceAt:
+		t := fnDate(timeNow.Year(), int(timeNow.Month()), timeNow.Day())
+		if !t.After(timeNow) {
+			t = fnDate(timeNow.Year(), int(timeNow.Month()), timeNow.Day()+1)
+		}
+
+		return fnOnce(t)
+	case alarmTextOnceDay:
+		return fnOnce(fnDate(timeNow.Year(), int(timeNow.Month()), timeNow.Day()+r.dayOffset))
+	case alarmTextOnceDate:
+		year := r.year
+		if year == 0 {
+			year = timeNow.Year()
+
+			if !fnDate(year, r.month, r.day).After(timeNow) {
+				year++
+			}
+		}
+
+		if r.month < 1 || r.month > 12 || r.day < 1 || r.day > GetDaysOfMonth(year, r.month) {
+			return fmt.Errorf("%w: invalid date %d-%d", commerr.ErrBadFormat, r.month, r.day)
+		}
+
+		return fnOnce(fnDate(year, r.month, r.day))
+	case alarmTextDay:
+		alarm.AType = RecycleTimeTypeDay
+		alarm.Value = hms
+	case alarmTextWeek:
+		alarm.AType = RecycleTimeTypeWeek
+		alarm.Value = strconv.Itoa(r.week) + hms
+	case alarmTextMonth:
+		alarm.AType = RecycleTimeTypeMonth
+		alarm.Value = prefix + fmt.Sprintf("%02d", r.day) + hms
+	case alarmTextYear:
+		alarm.AType = RecycleTimeTypeYear
+		alarm.Value = prefix + fmt.Sprintf("%02d%02d", r.month, r.day) + hms
+	case alarmTextHour:
+		alarm.AType = RecycleTimeTypeHour
+		alarm.Value = fmt.Sprintf("%02d00", r.minute)
+	}
+
+	return nil
+}
+
+// cutAlarmText 在 s 和 origin 中同样的位置去掉 match
+func cutAlarmText(s, origin, match string) (string, string) {
+	idx := strings.Index(s, match)
+	if match == "" || idx < 0 {
+		return s, origin
+	}
+
+	end := idx + len(match)
+
+	return s[:idx] + " " + s[end:], origin[:idx] + " " + origin[end:]
+}
+
+// normalizeAlarmText 全角数字和标点转为半角
+func normalizeAlarmText(text string) string {
+	var sb strings.Builder
+
+	for _, c := range strings.TrimSpace(text) {
+		switch {
+		case c >= '０' && c <= '９':
+			c = '0' + (c - '０')
+		case c == '：':
+			c = ':'
+		case c == '　':
+			c = ' '
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return sb.String()
+}
+
+func cleanAlarmText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+
+	for {
+		trimmed := strings.Trim(s, alarmTextCutset)
+		trimmed = alarmTextLeadingRe.ReplaceAllString(trimmed, "")
+		trimmed = alarmTextTrailingRe.ReplaceAllString(trimmed, "")
+
+		if trimmed == s {
+			return s
+		}
+
+		s = trimmed
+	}
+}
+
+// parseCNNumber 支持阿拉伯数字和 0-99 的中文数字, 如 十五, 二十三, 廿五
+func parseCNNumber(s string) (n int, ok bool) {
+	if v, err := strconv.Atoi(s); err == nil {
+		return v, true
+	}
+
+	digits := map[rune]int{'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	tens := map[rune]int{'十': 10, '廿': 20, '卅': 30}
+
+	rs := []rune(s)
+	if len(rs) == 0 || len(rs) > 3 {
+		return
+	}
+
+	var cur int
+
+	var hasTen bool
+
+	for _, c := range rs {
+		if d, isDigit := digits[c]; isDigit {
+			cur = cur*10 + d
+
+			continue
+		}
+
+		ten, isTen := tens[c]
+		if !isTen || hasTen {
+			return 0, false
+		}
+
+		hasTen = true
+
+		switch {
+		case c != '十':
+			if cur != 0 {
+				return 0, false
+			}
+
+			n = ten
+		case cur == 0:
+			n = 10
+		default:
+			n = cur * 10
+		}
+
+		cur = 0
+	}
+
+	return n + cur, true
+}
+
+func parseCNNumberTo(s string, v *int) (ok bool) {
+	*v, ok = parseCNNumber(s)
+
+	return
+}
+
+func parseCNLunarMonth(s string, month *int) bool {
+	if m, ok := cnLunarMonths[s]; ok {
+		*month = m
+
+		return true
+	}
+
+	return parseCNNumberTo(s, month) && *month >= 1 && *month <= 12
+}
+
+// parseCNLunarDay 初一 ~ 三十
+func parseCNLunarDay(s string, day *int) bool {
+	return parseCNNumberTo(strings.TrimPrefix(s, "初"), day) && *day >= 1 && *day <= 30
+}
@@ -0,0 +1,101 @@
+package timeassist
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAlarmText(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	// 2026-03-02 周一
+	timeNow := time.Date(2026, 3, 2, 8, 0, 0, 0, tz8)
+
+	for _, c := range []struct {
+		text      string
+		aType     TimeType
+		value     string
+		alarmText string
+		validTime *ValidTime
+	}{
+		{"每周三早上9点开会", RecycleTimeTypeWeek, "3090000", "开会", nil},
+		{"每月最后一天晚上8点 交房租", RecycleTimeTypeMonth, "S-1200000", "交房租", nil},
+		{"农历八月十五 20:00", RecycleTimeTypeYear, "L0815200000", "农历八月十五 20:00", nil},
+		{"每年农历腊月二十三下午三点半 小年", RecycleTimeTypeYear, "L1223153000", "小年", nil},
+		{"阴历每月初一 早上7点 上香", RecycleTimeTypeMonth, "L01070000", "上香", nil},
+		{"提醒我每天22:30吃药", RecycleTimeTypeDay, "223000", "吃药", nil},
+		{"每天晚上12点 睡觉", RecycleTimeTypeDay, "000000", "睡觉", nil},
+		{"每天夜里12点半 关灯", RecycleTimeTypeDay, "003000", "关灯", nil},
+		{"每天半夜12点 备份", RecycleTimeTypeDay, "000000", "备份", nil},
+		{"每天凌晨12点 签到", RecycleTimeTypeDay, "000000", "签到", nil},
+		{"每天下午12点 午饭", RecycleTimeTypeDay, "120000", "午饭", nil},
+		{"每个工作日早上七点十分 起床", RecycleTimeTypeDay, "071000", "起床", &ValidTime{OnlyWorkDays: true}},
+		{"周末上午10点 打扫", RecycleTimeTypeDay, "100000", "打扫", weekendValidTime()},
+		{"每年3月12日 9点 植树", RecycleTimeTypeYear, "S0312090000", "植树", nil},
+		{"每月15号 中午12点 还信用卡", RecycleTimeTypeMonth, "S15120000", "还信用卡", nil},
+		{"每小时第15分 喝水", RecycleTimeTypeHour, "1500", "喝水", nil},
+		{"45分钟后 关火", TimeTypeOnce, "S20260302084500", "关火", nil},
+		{"半小时后", TimeTypeOnce, "S20260302083000", "半小时后", nil},
+		{"明天下午3点 开会", TimeTypeOnce, "S20260303150000", "开会", nil},
+		{"今晚8点 看球", TimeTypeOnce, "S20260302200000", "看球", nil},
+		{"3月1日上午9点 体检", TimeTypeOnce, "S20270301090000", "体检", nil},
+		{"7点 跑步", TimeTypeOnce, "S20260303070000", "跑步", nil},
+		{"every day at 7:30 Wake Up", RecycleTimeTypeDay, "073000", "Wake Up", nil},
+		{"Remind me to stretch every weekday at 10am", RecycleTimeTypeDay, "100000", "stretch",
+			&ValidTime{ValidDaysInWeek: &ValidRanges{ValidRanges: []ValidRange{{Start: 1, End: 6}}}}},
+		{"every Friday at 5:30 pm review", RecycleTimeTypeWeek, "5173000", "review", nil},
+		{"every month on the last day at 9pm pay rent", RecycleTimeTypeMonth, "S-1210000", "pay rent", nil},
+		{"every month on the 1st at noon", RecycleTimeTypeMonth, "S01120000", "every month on the 1st at noon", nil},
+		{"every year on March 5 at 8am birthday", RecycleTimeTypeYear, "S0305080000", "birthday", nil},
+		{"in 45 minutes check oven", TimeTypeOnce, "S20260302084500", "check oven", nil},
+		{"in half an hour stretch", TimeTypeOnce, "S20260302083000", "stretch", nil},
+		{"tomorrow at 9 dentist", TimeTypeOnce, "S20260303090000", "dentist", nil},
+		{"tonight at 8 movie", TimeTypeOnce, "S20260302200000", "movie", nil},
+		{"on Dec 25, 2026 at 10:00 gifts", TimeTypeOnce, "S20261225100000", "gifts", nil},
+	} {
+		alarm, err := ParseAlarmText(c.text, "", 8, timeNow)
+		if !assert.Nil(t, err, c.text) {
+			continue
+		}
+
+		assert.Equal(t, c.aType, alarm.AType, c.text)
+		assert.Equal(t, c.value, alarm.Value, c.text)
+		assert.Equal(t, c.alarmText, alarm.Text, c.text)
+		assert.Equal(t, c.validTime, alarm.ValidTime, c.text)
+		assert.Equal(t, 8, alarm.TimeZone, c.text)
+	}
+}
+
+func TestParseAlarmTextPreview(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	timeNow := time.Date(2026, 3, 2, 8, 0, 0, 0, tz8)
+
+	alarm, err := ParseAlarmText("农历八月十五 20:00 中秋", "Asia/Shanghai", 0, timeNow)
+	assert.Nil(t, err)
+	assert.Equal(t, "Asia/Shanghai", alarm.Location)
+
+	occurrences, err := PreviewAlarm(alarm, timeNow, 1)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 1)
+	assert.Equal(t, "丙午年八月十五 20时00分00秒", occurrences[0].FireAt.Lunar)
+}
+
+func TestParseAlarmTextError(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	timeNow := time.Date(2026, 3, 2, 8, 0, 0, 0, tz8)
+
+	for _, text := range []string{"开会", "每天 开会", "每天25点", "every day", "2月30日 9点", "每月40号 9点", "每年13月1日 9点",
+		"in 0 hours stretch"} {
+		_, err := ParseAlarmText(text, "", 8, timeNow)
+		assert.True(t, errors.Is(err, commerr.ErrBadFormat), text)
+	}
+
+	_, err := ParseAlarmText("今天7点", "", 8, timeNow)
+	assert.True(t, errors.Is(err, commerr.ErrOutOfRange))
+
+	_, err = ParseAlarmText("每天7点", "Mars/Base", 8, timeNow)
+	assert.NotNil(t, err)
+}
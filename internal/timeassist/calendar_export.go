@@ -0,0 +1,494 @@
+package timeassist
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultCalendarExportDays = 90
+
+	// maxCalendarOccurrences 每个 Alarm/Task 展开的最大次数, 防止按分钟重复的提醒撑爆日历
+	maxCalendarOccurrences = 500
+
+	calendarUIDSuffix = "@timeassistbe"
+)
+
+var icsWeekdays = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ExportCalendar 输出 iCalendar(RFC 5545), Alarm 为 VEVENT, Task 为 VTODO.
+// 能用 RRULE 表达的输出 RRULE, 否则(阴历, cron, 有 ValidTime 等)展开 [timeNow, timeNow+window] 内的每一次;
+// 暂停的不输出, 恢复后重新导出; calendar 为空时使用内置的节假日数据
+func ExportCalendar(w io.Writer, alarms []*Alarm, tasks []*Task, timeNow time.Time, window time.Duration,
+	calendar HolidayCalendar) error {
+	e := &calendarExporter{
+		calendar:  fixHolidayCalendar(calendar),
+		timeNow:   timeNow,
+		timeEnd:   timeNow.Add(window),
+		stamp:     formatICSTimeUTC(timeNow),
+		timeZones: make(map[string]*icsTimeZone),
+	}
+
+	for _, alarm := range alarms {
+		if !alarm.Paused {
+			e.addAlarm(alarm)
+		}
+	}
+
+	for _, task := range tasks {
+		if !task.Paused {
+			e.addTask(task)
+		}
+	}
+
+	return e.write(w)
+}
+
+type calendarExporter struct {
+	calendar HolidayCalendar
+	timeNow  time.Time
+	timeEnd  time.Time
+	stamp    string
+
+	// timeZones 引用到的每个 TZID 都要输出 VTIMEZONE
+	timeZones  map[string]*icsTimeZone
+	components [][]string
+}
+
+// icsTimeZone from 为引用到的最早时间, IANA 时区输出 [from, timeEnd] 内的偏移变化
+type icsTimeZone struct {
+	loc   *time.Location
+	fixed bool
+	from  time.Time
+}
+
+func (e *calendarExporter) write(w io.Writer) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//s-min-sys//timeassistbe//CN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:timeassist",
+	}
+
+	tzIDs := make([]string, 0, len(e.timeZones))
+	for tzID := range e.timeZones {
+		tzIDs = append(tzIDs, tzID)
+	}
+
+	sort.Strings(tzIDs)
+
+	for _, tzID := range tzIDs {
+		tz := e.timeZones[tzID]
+
+		lines = append(lines, "BEGIN:VTIMEZONE", "TZID:"+tzID)
+
+		if tz.fixed {
+			_, offset := e.timeNow.In(tz.loc).Zone()
+
+			lines = append(lines,
+				"BEGIN:STANDARD",
+				"DTSTART:19700101T000000",
+				"TZOFFSETFROM:"+formatICSOffset(offset),
+				"TZOFFSETTO:"+formatICSOffset(offset),
+				"TZNAME:"+tzID,
+				"END:STANDARD",
+			)
+		} else {
+			lines = append(lines, icsObservances(tz.loc, tz.from, e.timeEnd)...)
+		}
+
+		lines = append(lines, "END:VTIMEZONE")
+	}
+
+	for _, component := range e.components {
+		lines = append(lines, component...)
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	var sb strings.Builder
+
+	for _, line := range lines {
+		writeICSLine(&sb, line)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+
+	return err
+}
+
+// tzID IANA 时区直接使用时区名, 固定偏移时区使用 UTC+0800 这样的名字
+func (e *calendarExporter) tzID(location string, loc *time.Location) string {
+	tzID := location
+
+	if location == "" {
+		_, offset := e.timeNow.In(loc).Zone()
+
+		tzID = "UTC" + formatICSOffset(offset)
+	}
+
+	if _, ok := e.timeZones[tzID]; !ok {
+		e.timeZones[tzID] = &icsTimeZone{
+			loc:   loc,
+			fixed: location == "",
+			from:  e.timeNow,
+		}
+	}
+
+	return tzID
+}
+
+// icsTime t 在 tzID 时区的本地时间, 同时记录 VTIMEZONE 需要覆盖的最早时间
+func (e *calendarExporter) icsTime(tzID string, t time.Time) string {
+	tz := e.timeZones[tzID]
+	if t.Before(tz.from) {
+		tz.from = t
+	}
+
+	return formatICSTime(t.In(tz.loc))
+}
+
+func (e *calendarExporter) addAlarm(alarm *Alarm) {
+	av, err := alarm.ValidateEx(e.timeNow, e.calendar)
+	if err != nil {
+		return
+	}
+
+	loc, err := TimeLocation(alarm.Location, alarm.TimeZone)
+	if err != nil {
+		return
+	}
+
+	tzID := e.tzID(alarm.Location, loc)
+
+	_, desc := av.StringNoNowTime(alarm.AType)
+
+	fnEvent := func(uid string, at time.Time, extra ...string) {
+		lines := []string{
+			"BEGIN:VEVENT",
+			"UID:" + uid,
+			"DTSTAMP:" + e.stamp,
+			"DTSTART;TZID=" + tzID + ":" + e.icsTime(tzID, at),
+			"SUMMARY:" + escapeICSText(alarm.Text),
+		}
+
+		if desc != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeICSText(desc))
+		}
+
+		lines = append(lines, extra...)
+
+		trigger := "PT0S"
+		if alarm.EarlyShowMinute > 0 {
+			trigger = fmt.Sprintf("-PT%dM", alarm.EarlyShowMinute)
+		}
+
+		lines = append(lines,
+			"BEGIN:VALARM",
+			"ACTION:DISPLAY",
+			"DESCRIPTION:"+escapeICSText(alarm.Text),
+			"TRIGGER:"+trigger,
+			"END:VALARM",
+			"END:VEVENT",
+		)
+
+		e.components = append(e.components, lines)
+	}
+
+	if alarm.AType == TimeTypeOnce {
+		_, timeAt, _, _, _, err := alarm.GenRecycleDataEx(e.timeNow, e.timeNow, e.calendar)
+		if err == nil {
+			fnEvent(alarm.ID+calendarUIDSuffix, timeAt)
+		}
+
+		return
+	}
+
+	// 有例外时逐次展开
+	if alarm.ValidTime == nil && len(alarm.Exceptions) == 0 {
+		if dtStart, extra, ok := alarmICSRule(alarm, av, loc, e.timeNow, tzID); ok {
+			fnEvent(alarm.ID+calendarUIDSuffix, dtStart, extra...)
+
+			return
+		}
+	}
+
+	timeNow := e.timeNow
+
+	for idx := 0; idx < maxCalendarOccurrences; idx++ {
+		_, timeAt, rd, _, _, err := alarm.GenRecycleDataEx(timeNow, timeNow, e.calendar)
+		if err != nil || timeAt.Before(timeNow) || timeAt.After(e.timeEnd) {
+			break
+		}
+
+		fnEvent(fmt.Sprintf("%s-%d%s", alarm.ID, timeAt.Unix(), calendarUIDSuffix), timeAt)
+
+		if rd == nil {
+			break
+		}
+
+		timeNow = timeAt.Add(time.Second)
+	}
+}
+
+// alarmICSRule Alarm 能用 RRULE 表达时返回 DTSTART 和 RRULE/EXDATE
+func alarmICSRule(alarm *Alarm, av *AlarmValue, loc *time.Location, timeNow time.Time, tzID string) (dtStart time.Time, extra []string, ok bool) {
+	var rule string
+
+	fnMonthDay := func() (string, bool) {
+		// 大于 28 的日期在短的月份会提前到最后一天, RRULE 无法表达
+		if av.Day == -1 || (av.Day >= 1 && av.Day <= 28) {
+			return fmt.Sprintf("BYMONTHDAY=%d", av.Day), true
+		}
+
+		return "", false
+	}
+
+	switch alarm.AType {
+	case RecycleTimeTypeRRule:
+		dtStart = av.RRule.dtStart.in(loc)
+		extra = append(extra, "RRULE:"+av.RRule.rule)
+
+		for _, ex := range av.RRule.exDates {
+			exAt := ex.in(loc)
+			if ex.dateOnly {
+				exAt = localDate(ex.year, time.Month(ex.month), ex.day, dtStart.Hour(), dtStart.Minute(), dtStart.Second(), 0, loc)
+			}
+
+			extra = append(extra, "EXDATE;TZID="+tzID+":"+formatICSTime(exAt))
+		}
+
+		ok = true
+
+		return
+	case RecycleTimeTypeYear, RecycleTimeTypeMonth:
+		if av.Lunar {
+			return
+		}
+
+		monthDay, monthDayOK := fnMonthDay()
+		if !monthDayOK {
+			return
+		}
+
+		if alarm.AType == RecycleTimeTypeYear {
+			rule = fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;%s", av.Month, monthDay)
+		} else {
+			rule = "FREQ=MONTHLY;" + monthDay
+		}
+	case RecycleTimeTypeWeek:
+		rule = "FREQ=WEEKLY;BYDAY=" + icsWeekdays[av.Week]
+	case RecycleTimeTypeDay:
+		rule = "FREQ=DAILY"
+	case RecycleTimeTypeHour:
+		rule = "FREQ=HOURLY"
+	case RecycleTimeTypeMinute:
+		rule = "FREQ=MINUTELY"
+	default:
+		return
+	}
+
+	// 只有没有 ValidTime 的提醒会输出 RRULE, 不需要节假日数据
+	_, dtStart, _, _, _, err := alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	if err != nil {
+		return
+	}
+
+	extra = append(extra, "RRULE:"+rule)
+	ok = true
+
+	return
+}
+
+func (e *calendarExporter) addTask(task *Task) {
+	if task.ValidEx(e.timeNow, e.calendar) != nil {
+		return
+	}
+
+	loc, err := TimeLocation(task.Location, task.TimeZone)
+	if err != nil {
+		return
+	}
+
+	tzID := e.tzID(task.Location, loc)
+
+	fnTodo := func(uid string, rd *ShowItem, extra ...string) {
+		lines := []string{
+			"BEGIN:VTODO",
+			"UID:" + uid,
+			"DTSTAMP:" + e.stamp,
+			"SUMMARY:" + escapeICSText(task.Text),
+			"DESCRIPTION:" + escapeICSText(task.Desc()),
+		}
+
+		if rd != nil {
+			lines = append(lines,
+				"DTSTART;TZID="+tzID+":"+e.icsTime(tzID, time.Unix(rd.StartUTC, 0)),
+				"DUE;TZID="+tzID+":"+e.icsTime(tzID, time.Unix(rd.EndUTC, 0)),
+			)
+		}
+
+		lines = append(lines, extra...)
+		lines = append(lines, "END:VTODO")
+
+		e.components = append(e.components, lines)
+	}
+
+	if task.TType == TimeTypeOnce {
+		fnTodo(task.ID+calendarUIDSuffix, nil)
+
+		return
+	}
+
+	rd, _ := task.GenRecycleDataEx(e.timeNow, e.calendar)
+
+	if freq, ok := taskICSFreq(task); ok {
+		fnTodo(task.ID+calendarUIDSuffix, rd, fmt.Sprintf("RRULE:FREQ=%s;INTERVAL=%d", freq, task.Value))
+
+		return
+	}
+
+	for idx := 0; idx < maxCalendarOccurrences && rd.StartUTC <= e.timeEnd.Unix(); idx++ {
+		fnTodo(fmt.Sprintf("%s-%d%s", task.ID, rd.StartUTC, calendarUIDSuffix), rd)
+
+		rd, _ = task.GenRecycleDataEx(time.Unix(rd.EndUTC, 0), e.calendar)
+	}
+}
+
+// taskICSFreq 阴历和有 ValidTime 的周期不是固定长度, 无法用 RRULE 表达
+func taskICSFreq(task *Task) (freq string, ok bool) {
+	if task.LunarFlag || task.ValidTime != nil {
+		return
+	}
+
+	switch task.TType {
+	case RecycleTimeTypeYear:
+		freq = "YEARLY"
+	case RecycleTimeTypeMonth:
+		freq = "MONTHLY"
+	case RecycleTimeTypeWeek:
+		freq = "WEEKLY"
+	case RecycleTimeTypeDay:
+		freq = "DAILY"
+	case RecycleTimeTypeHour:
+		freq = "HOURLY"
+	case RecycleTimeTypeMinute:
+		freq = "MINUTELY"
+	default:
+		return
+	}
+
+	ok = true
+
+	return
+}
+
+// icsObservances 时区在 [from, to] 内的 STANDARD/DAYLIGHT, 第一个从 from 开始, 之后每次偏移变化一个
+func icsObservances(loc *time.Location, from, to time.Time) (lines []string) {
+	fnObservance := func(at time.Time, offsetFrom int) {
+		at = at.In(loc)
+
+		name, offset := at.Zone()
+
+		kind := "STANDARD"
+		if at.IsDST() {
+			kind = "DAYLIGHT"
+		}
+
+		lines = append(lines,
+			"BEGIN:"+kind,
+			"DTSTART:"+formatICSTime(at.In(time.FixedZone(name, offsetFrom))),
+			"TZOFFSETFROM:"+formatICSOffset(offsetFrom),
+			"TZOFFSETTO:"+formatICSOffset(offset),
+			"TZNAME:"+name,
+			"END:"+kind,
+		)
+	}
+
+	from = from.Truncate(time.Second)
+
+	_, offset := from.In(loc).Zone()
+	fnObservance(from, offset)
+
+	for day := from; day.Before(to); day = day.Add(24 * time.Hour) {
+		next := day.Add(24 * time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset == offset {
+			continue
+		}
+
+		// 二分查找偏移变化的时刻
+		lo, hi := day, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add((hi.Sub(lo) / 2).Truncate(time.Second))
+			if _, midOffset := mid.In(loc).Zone(); midOffset == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+
+		fnObservance(hi, offset)
+
+		_, offset = hi.In(loc).Zone()
+	}
+
+	return
+}
+
+func formatICSTime(t time.Time) string {
+	return t.Format("20060102T150405")
+}
+
+func formatICSTimeUTC(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func formatICSOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICSLine 超过 75 字节的行折行, 不拆开 UTF-8 字符
+func writeICSLine(sb *strings.Builder, line string) {
+	const maxLineBytes = 75
+
+	limit := maxLineBytes
+
+	for len(line) > limit {
+		cut := 0
+
+		for idx := range line {
+			if idx > limit {
+				break
+			}
+
+			cut = idx
+		}
+
+		if cut == 0 {
+			break
+		}
+
+		sb.WriteString(line[:cut])
+		sb.WriteString("\r\n ")
+
+		line = line[cut:]
+		limit = maxLineBytes - 1
+	}
+
+	sb.WriteString(line)
+	sb.WriteString("\r\n")
+}
@@ -0,0 +1,182 @@
+package timeassist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func utICSEvents(ics string, kind string) (events []string) {
+	for _, block := range strings.Split(ics, "BEGIN:"+kind+"\r\n")[1:] {
+		events = append(events, block[:strings.Index(block, "END:"+kind)])
+	}
+
+	return
+}
+
+func TestExportCalendar(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	timeNow := time.Date(2026, 1, 1, 0, 0, 0, 0, tz8)
+
+	alarms := []*Alarm{
+		{ID: "A1", AType: RecycleTimeTypeWeek, Text: "周会, 带电脑", Value: "1090000", TimeZone: 8, EarlyShowMinute: 15},
+		{ID: "A2", AType: RecycleTimeTypeYear, Text: "中秋", Value: "L0815200000", TimeZone: 8},
+		{ID: "A3", AType: RecycleTimeTypeRRule, Text: "standup", Location: "Europe/Berlin",
+			Value: "DTSTART:20260106T090000 RRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=10 EXDATE:20260113"},
+		{ID: "A4", AType: RecycleTimeTypeCron, Text: "drink", Value: "0 10,15 * * *", TimeZone: 8},
+		{ID: "A5", AType: TimeTypeOnce, Text: "once", Value: "20260203080000", TimeZone: 8},
+		{ID: "A6", AType: RecycleTimeTypeDay, Text: "paused", Value: "080000", TimeZone: 8, Paused: true},
+	}
+
+	tasks := []*Task{
+		{ID: "T1", TType: RecycleTimeTypeWeek, Value: 2, Text: "打扫", TimeZone: 8},
+		{ID: "T2", TType: RecycleTimeTypeMonth, Value: 1, LunarFlag: true, Text: "lunar", TimeZone: 8},
+		{ID: "T3", TType: TimeTypeOnce, Text: "一次性", TimeZone: 8},
+		{ID: "T4", TType: RecycleTimeTypeDay, Value: 1, Text: "paused", TimeZone: 8, Paused: true},
+	}
+
+	var sb strings.Builder
+
+	assert.Nil(t, ExportCalendar(&sb, alarms, tasks, timeNow, 10*24*time.Hour, nil))
+
+	ics := sb.String()
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "TZID:UTC+0800\r\nBEGIN:STANDARD")
+	assert.Contains(t, ics, "TZID:Europe/Berlin\r\nBEGIN:STANDARD\r\nDTSTART:20251231T170000\r\n"+
+		"TZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD\r\nEND:VTIMEZONE")
+	utAssertICSTimeZones(t, ics)
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+
+	// 暂停的不输出
+	assert.NotContains(t, ics, "UID:A6")
+	assert.NotContains(t, ics, "UID:T4")
+
+	events := utICSEvents(ics, "VEVENT")
+
+	var a1, a2, a3, a4, a5 []string
+
+	for _, event := range events {
+		switch {
+		case strings.Contains(event, "UID:A1@"):
+			a1 = append(a1, event)
+		case strings.Contains(event, "UID:A2-"):
+			a2 = append(a2, event)
+		case strings.Contains(event, "UID:A3@"):
+			a3 = append(a3, event)
+		case strings.Contains(event, "UID:A4-"):
+			a4 = append(a4, event)
+		case strings.Contains(event, "UID:A5@"):
+			a5 = append(a5, event)
+		}
+	}
+
+	assert.Equal(t, 1, len(a1))
+	assert.Contains(t, a1[0], "DTSTART;TZID=UTC+0800:20260105T090000\r\n")
+	assert.Contains(t, a1[0], "RRULE:FREQ=WEEKLY;BYDAY=MO\r\n")
+	assert.Contains(t, a1[0], `SUMMARY:周会\, 带电脑`)
+	assert.Contains(t, a1[0], "TRIGGER:-PT15M\r\n")
+
+	// 阴历不在窗口内, 不输出
+	assert.Equal(t, 0, len(a2))
+
+	assert.Equal(t, 1, len(a3))
+	assert.Contains(t, a3[0], "DTSTART;TZID=Europe/Berlin:20260106T090000\r\n")
+	assert.Contains(t, a3[0], "RRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=10\r\n")
+	assert.Contains(t, a3[0], "EXDATE;TZID=Europe/Berlin:20260113T090000\r\n")
+
+	assert.Equal(t, 20, len(a4))
+	assert.Contains(t, a4[0], "DTSTART;TZID=UTC+0800:20260101T100000\r\n")
+
+	assert.Equal(t, 1, len(a5))
+	assert.Contains(t, a5[0], "DTSTART;TZID=UTC+0800:20260203T080000\r\n")
+
+	todos := utICSEvents(ics, "VTODO")
+
+	var t1, t2, t3 int
+
+	for _, todo := range todos {
+		switch {
+		case strings.Contains(todo, "UID:T1@"):
+			t1++
+
+			assert.Contains(t, todo, "RRULE:FREQ=WEEKLY;INTERVAL=2\r\n")
+			assert.Contains(t, todo, "DUE;TZID=UTC+0800:")
+		case strings.Contains(todo, "UID:T2-"):
+			t2++
+		case strings.Contains(todo, "UID:T3@"):
+			t3++
+
+			assert.NotContains(t, todo, "DTSTART")
+		}
+	}
+
+	assert.Equal(t, 1, t1)
+	assert.Equal(t, 1, t2)
+	assert.Equal(t, 1, t3)
+
+	// 阴历中秋在窗口内时展开
+	sb.Reset()
+
+	assert.Nil(t, ExportCalendar(&sb, alarms[1:2], nil, timeNow, 366*24*time.Hour, nil))
+	assert.Equal(t, 1, len(utICSEvents(sb.String(), "VEVENT")))
+}
+
+// utAssertICSTimeZones 引用的每个 TZID 都有 VTIMEZONE
+func utAssertICSTimeZones(t *testing.T, ics string) {
+	for _, line := range strings.Split(ics, "\r\n") {
+		idx := strings.Index(line, ";TZID=")
+		if idx < 0 {
+			continue
+		}
+
+		tzID := line[idx+len(";TZID="):]
+		tzID = tzID[:strings.Index(tzID, ":")]
+
+		assert.Contains(t, ics, "BEGIN:VTIMEZONE\r\nTZID:"+tzID+"\r\n", line)
+	}
+}
+
+func TestExportCalendarDaylight(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	timeNow := time.Date(2026, 3, 1, 0, 0, 0, 0, berlin)
+
+	alarms := []*Alarm{
+		{ID: "A1", AType: RecycleTimeTypeDay, Text: "daily", Value: "090000", Location: "Europe/Berlin"},
+	}
+
+	var sb strings.Builder
+
+	assert.Nil(t, ExportCalendar(&sb, alarms, nil, timeNow, 60*24*time.Hour, nil))
+
+	ics := sb.String()
+
+	utAssertICSTimeZones(t, ics)
+
+	// 2026-03-29 02:00 CET 进入夏令时
+	assert.Contains(t, ics, "BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\n"+
+		"BEGIN:STANDARD\r\nDTSTART:20260301T000000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\nEND:STANDARD\r\n"+
+		"BEGIN:DAYLIGHT\r\nDTSTART:20260329T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\nEND:DAYLIGHT\r\n"+
+		"END:VTIMEZONE")
+	assert.Contains(t, ics, "DTSTART;TZID=Europe/Berlin:20260301T090000\r\n")
+}
+
+func TestWriteICSLine(t *testing.T) {
+	var sb strings.Builder
+
+	writeICSLine(&sb, "SUMMARY:"+strings.Repeat("中", 40))
+
+	lines := strings.Split(strings.TrimSuffix(sb.String(), "\r\n"), "\r\n")
+	assert.Equal(t, 2, len(lines))
+	assert.LessOrEqual(t, len(lines[0]), 75)
+	assert.True(t, strings.HasPrefix(lines[1], " "))
+	assert.Equal(t, "SUMMARY:"+strings.Repeat("中", 40), lines[0]+lines[1][1:])
+}
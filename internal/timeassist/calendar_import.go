@@ -0,0 +1,576 @@
+package timeassist
+
+import (
+	"bufio"
+	"crypto/sha1" // nolint: gosec
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+type CalendarImportStatus string
+
+const (
+	CalendarImportAdded     CalendarImportStatus = "added"
+	CalendarImportUpdated   CalendarImportStatus = "updated"
+	CalendarImportUnchanged CalendarImportStatus = "unchanged"
+	CalendarImportSkipped   CalendarImportStatus = "skipped" // 已经过期, 导入没有意义
+	CalendarImportFailed    CalendarImportStatus = "failed"
+)
+
+const (
+	calendarAlarmIDPre = AlarmIDPre + "ics"
+
+	// calendarAllDayHour 全天事件的提醒时间
+	calendarAllDayHour = 9
+)
+
+type CalendarImportResult struct {
+	UID     string               `json:"uid"`
+	AlarmID string               `json:"alarm_id,omitempty"`
+	Summary string               `json:"summary,omitempty"`
+	Status  CalendarImportStatus `json:"status"`
+	Message string               `json:"message,omitempty"`
+}
+
+// CalendarAlarmID 同一个 UID 总是导入到同一个 Alarm, 重复导入不会产生重复的提醒
+func CalendarAlarmID(uid string) string {
+	sum := sha1.Sum([]byte(uid)) // nolint: gosec
+
+	return calendarAlarmIDPre + hex.EncodeToString(sum[:8])
+}
+
+// ImportCalendar 把 VEVENT(含 RRULE/EXDATE/TZID/VALARM)导入为 Alarm, 每个 VEVENT 一个结果.
+// 没有 TZID 的浮动时间按默认时区(+8)解释
+func ImportCalendar(r io.Reader, alarmManager AlarmManager, timeNow time.Time) (results []*CalendarImportResult, err error) {
+	cal, err := parseICS(r)
+	if err != nil {
+		return
+	}
+
+	for _, event := range cal.children("VEVENT") {
+		result := &CalendarImportResult{
+			UID:     event.value("UID"),
+			Summary: unescapeICSText(event.value("SUMMARY")),
+		}
+
+		results = append(results, result)
+
+		alarm, e := cal.eventToAlarm(event)
+		if e != nil {
+			result.Status = CalendarImportFailed
+			result.Message = e.Error()
+
+			continue
+		}
+
+		result.AlarmID = alarm.ID
+
+		importCalendarAlarm(alarm, alarmManager, timeNow, result)
+	}
+
+	return
+}
+
+func importCalendarAlarm(alarm *Alarm, alarmManager AlarmManager, timeNow time.Time, result *CalendarImportResult) {
+	// 导入的提醒没有 ValidTime, 不需要节假日数据
+	_, _, rd, _, _, err := alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	if err != nil {
+		result.Status = CalendarImportFailed
+		result.Message = err.Error()
+
+		return
+	}
+
+	old, err := alarmManager.Get(alarm.ID)
+	if err != nil {
+		result.Status = CalendarImportFailed
+		result.Message = err.Error()
+
+		return
+	}
+
+	switch {
+	case old != nil && sameCalendarAlarm(old, alarm):
+		result.Status = CalendarImportUnchanged
+
+		return
+	case old == nil && rd == nil:
+		result.Status = CalendarImportSkipped
+		result.Message = "no upcoming occurrence"
+
+		return
+	case old != nil:
+		// 通知路由和升级策略是导入后单独设置的, 保留
+		alarm.Notify = old.Notify
+		alarm.Escalation = old.Escalation
+
+		result.Status = CalendarImportUpdated
+		err = alarmManager.Update(alarm)
+	default:
+		result.Status = CalendarImportAdded
+		err = alarmManager.Add(alarm)
+	}
+
+	if err != nil {
+		result.Status = CalendarImportFailed
+		result.Message = err.Error()
+	}
+}
+
+func sameCalendarAlarm(a, b *Alarm) bool {
+	return a.UID == b.UID && a.AType == b.AType && a.Text == b.Text && a.Value == b.Value &&
+		a.TimeZone == b.TimeZone && a.Location == b.Location && a.EarlyShowMinute == b.EarlyShowMinute
+}
+
+//
+//
+//
+
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+type icsComponent struct {
+	name       string
+	properties []*icsProperty
+	components []*icsComponent
+}
+
+func (c *icsComponent) property(name string) *icsProperty {
+	for _, p := range c.properties {
+		if p.name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func (c *icsComponent) value(name string) string {
+	if p := c.property(name); p != nil {
+		return p.value
+	}
+
+	return ""
+}
+
+func (c *icsComponent) count(name string) (n int) {
+	for _, p := range c.properties {
+		if p.name == name {
+			n++
+		}
+	}
+
+	return
+}
+
+func (c *icsComponent) children(name string) (components []*icsComponent) {
+	for _, component := range c.components {
+		if component.name == name {
+			components = append(components, component)
+		}
+	}
+
+	return
+}
+
+func parseICS(r io.Reader) (cal *icsComponent, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// 折行
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	var stack []*icsComponent
+
+	for _, line := range lines {
+		p, e := parseICSProperty(line)
+		if e != nil {
+			continue
+		}
+
+		switch p.name {
+		case "BEGIN":
+			stack = append(stack, &icsComponent{
+				name: strings.ToUpper(p.value),
+			})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].name != strings.ToUpper(p.value) {
+				return nil, commerr.ErrBadFormat
+			}
+
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if len(stack) == 0 {
+				if c.name == "VCALENDAR" {
+					return c, nil
+				}
+
+				continue
+			}
+
+			stack[len(stack)-1].components = append(stack[len(stack)-1].components, c)
+		default:
+			if len(stack) > 0 {
+				stack[len(stack)-1].properties = append(stack[len(stack)-1].properties, p)
+			}
+		}
+	}
+
+	return nil, commerr.ErrBadFormat
+}
+
+func parseICSProperty(line string) (p *icsProperty, err error) {
+	p = &icsProperty{
+		params: make(map[string]string),
+	}
+
+	var inQuote bool
+
+	colon := -1
+
+	for idx, c := range line {
+		if c == '"' {
+			inQuote = !inQuote
+		} else if c == ':' && !inQuote {
+			colon = idx
+
+			break
+		}
+	}
+
+	if colon < 0 {
+		return nil, commerr.ErrBadFormat
+	}
+
+	p.value = line[colon+1:]
+
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		p.params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return
+}
+
+func unescapeICSText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// icsZone 按 TZID 取时区: IANA 名称直接使用; 文件中的 VTIMEZONE 只支持无夏令时的整点偏移
+func (c *icsComponent) icsZone(p *icsProperty) (location string, timeZone int, err error) {
+	if strings.HasSuffix(p.value, "Z") {
+		location = "UTC"
+
+		return
+	}
+
+	tzID := p.params["TZID"]
+	if tzID == "" {
+		timeZone = defaultTimeZone
+
+		return
+	}
+
+	if _, e := time.LoadLocation(tzID); e == nil {
+		location = tzID
+
+		return
+	}
+
+	for _, vTimeZone := range c.children("VTIMEZONE") {
+		if vTimeZone.value("TZID") != tzID {
+			continue
+		}
+
+		standards := vTimeZone.children("STANDARD")
+		if len(standards) != 1 || len(vTimeZone.children("DAYLIGHT")) > 0 {
+			break
+		}
+
+		offset, e := parseICSOffset(standards[0].value("TZOFFSETTO"))
+		if e != nil || offset%3600 != 0 {
+			break
+		}
+
+		timeZone = offset / 3600
+
+		return
+	}
+
+	err = fmt.Errorf("unsupported TZID %s", tzID)
+
+	return
+}
+
+func parseICSOffset(s string) (offset int, err error) {
+	if len(s) != 5 && len(s) != 7 {
+		return 0, commerr.ErrBadFormat
+	}
+
+	hour, err := strconv.Atoi(s[1:3])
+	if err != nil {
+		return
+	}
+
+	minute, err := strconv.Atoi(s[3:5])
+	if err != nil {
+		return
+	}
+
+	offset = hour*3600 + minute*60
+	if s[0] == '-' {
+		offset = -offset
+	}
+
+	return
+}
+
+// icsLocalTime 转为 loc 中的墙上时间, 全天事件使用 calendarAllDayHour
+func icsLocalTime(p *icsProperty, loc *time.Location) (t time.Time, err error) {
+	value := strings.TrimSuffix(p.value, "Z")
+
+	if len(value) == 8 {
+		t, err = time.ParseInLocation("20060102", value, loc)
+		if err == nil {
+			t = t.Add(calendarAllDayHour * time.Hour)
+		}
+
+		return
+	}
+
+	if strings.HasSuffix(p.value, "Z") {
+		t, err = time.Parse("20060102T150405", value)
+		t = t.In(loc)
+
+		return
+	}
+
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+
+	return
+}
+
+// nolint: gocyclo
+func (c *icsComponent) eventToAlarm(event *icsComponent) (alarm *Alarm, err error) {
+	uid := event.value("UID")
+	if uid == "" {
+		return nil, fmt.Errorf("missing UID")
+	}
+
+	if strings.EqualFold(event.value("STATUS"), "CANCELLED") {
+		return nil, fmt.Errorf("event cancelled")
+	}
+
+	for _, name := range []string{"RECURRENCE-ID", "RDATE", "EXRULE"} {
+		if event.property(name) != nil {
+			return nil, fmt.Errorf("%s not supported", name)
+		}
+	}
+
+	if event.count("RRULE") > 1 {
+		return nil, fmt.Errorf("multiple RRULE not supported")
+	}
+
+	dtStart := event.property("DTSTART")
+	if dtStart == nil {
+		return nil, fmt.Errorf("missing DTSTART")
+	}
+
+	alarm = &Alarm{
+		ID:   CalendarAlarmID(uid),
+		UID:  uid,
+		Text: unescapeICSText(event.value("SUMMARY")),
+	}
+
+	if alarm.Text == "" {
+		alarm.Text = unescapeICSText(event.value("DESCRIPTION"))
+	}
+
+	if alarm.Text == "" {
+		return nil, fmt.Errorf("missing SUMMARY")
+	}
+
+	alarm.Location, alarm.TimeZone, err = c.icsZone(dtStart)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := TimeLocation(alarm.Location, alarm.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	startAt, err := icsLocalTime(dtStart, loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART %s", dtStart.value)
+	}
+
+	if rule := event.value("RRULE"); rule != "" {
+		lines := []string{
+			"DTSTART:" + formatICSTime(startAt),
+			"RRULE:" + rule,
+		}
+
+		for _, p := range event.properties {
+			if p.name != "EXDATE" {
+				continue
+			}
+
+			for _, v := range strings.Split(p.value, ",") {
+				// 只有日期时排除当天
+				if len(v) == 8 {
+					lines = append(lines, "EXDATE:"+v)
+
+					continue
+				}
+
+				exAt, e := icsLocalTime(&icsProperty{params: p.params, value: v}, loc)
+				if e != nil {
+					return nil, fmt.Errorf("invalid EXDATE %s", v)
+				}
+
+				lines = append(lines, "EXDATE:"+formatICSTime(exAt))
+			}
+		}
+
+		alarm.AType = RecycleTimeTypeRRule
+		alarm.Value = strings.Join(lines, "\n")
+
+		if _, e := ParseRRule(alarm.Value); e != nil {
+			return nil, fmt.Errorf("unsupported RRULE %s", rule)
+		}
+	} else {
+		alarm.AType = TimeTypeOnce
+		alarm.Value = "S" + startAt.Format("20060102150405")
+	}
+
+	for _, vAlarm := range event.children("VALARM") {
+		minutes, e := parseICSTrigger(vAlarm.property("TRIGGER"))
+		if e != nil {
+			return nil, e
+		}
+
+		if minutes > alarm.EarlyShowMinute {
+			alarm.EarlyShowMinute = minutes
+		}
+	}
+
+	return
+}
+
+// parseICSTrigger 只支持相对开始时间提前的 TRIGGER, 返回提前的分钟数
+func parseICSTrigger(p *icsProperty) (minutes int, err error) {
+	if p == nil {
+		return
+	}
+
+	if p.params["VALUE"] == "DATE-TIME" || p.params["RELATED"] == "END" {
+		return 0, fmt.Errorf("unsupported TRIGGER %s", p.value)
+	}
+
+	d, err := parseICSDuration(p.value)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported TRIGGER %s", p.value)
+	}
+
+	if d < 0 {
+		minutes = int(-d / time.Minute)
+	}
+
+	return
+}
+
+// parseICSDuration 如 -PT15M, P1D, -P1DT2H, P1W
+func parseICSDuration(s string) (d time.Duration, err error) {
+	sign := time.Duration(1)
+
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") {
+		return 0, commerr.ErrBadFormat
+	}
+
+	s = s[1:]
+
+	units := map[byte]time.Duration{
+		'W': 7 * 24 * time.Hour,
+		'D': 24 * time.Hour,
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+	}
+
+	var num string
+
+	var inTime, hasUnit bool
+
+	for idx := 0; idx < len(s); idx++ {
+		c := s[idx]
+
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			num += string(c)
+		default:
+			unit, ok := units[c]
+			if !ok || num == "" || (c == 'M' && !inTime) {
+				return 0, commerr.ErrBadFormat
+			}
+
+			n, _ := strconv.Atoi(num)
+			d += time.Duration(n) * unit
+			num = ""
+			hasUnit = true
+		}
+	}
+
+	if num != "" || !hasUnit {
+		return 0, commerr.ErrBadFormat
+	}
+
+	d *= sign
+
+	return
+}
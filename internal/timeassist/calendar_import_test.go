@@ -0,0 +1,184 @@
+package timeassist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const utCalendarICS = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//test//EN
+BEGIN:VTIMEZONE
+TZID:China Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T000000
+TZOFFSETFROM:+0800
+TZOFFSETTO:+0800
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:weekly-1@example.com
+DTSTART;TZID=America/New_York:20260106T090000
+RRULE:FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20270630T035959Z
+EXDATE;TZID=America/New_York:20260113T090000,20260115T090000
+SUMMARY:Standup\, daily
+BEGIN:VALARM
+ACTION:DISPLAY
+TRIGGER:-PT15M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:once-1@example.com
+DTSTART;TZID=China Standard Time:20260301T083000
+SUMMARY:体检
+END:VEVENT
+BEGIN:VEVENT
+UID:birthday@example.com
+DTSTART;VALUE=DATE:20000520
+RRULE:FREQ=YEARLY
+SUMMARY:生日
+BEGIN:VALARM
+TRIGGER:-P1D
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:past@example.com
+DTSTART:20200101T090000Z
+SUMMARY:past
+END:VEVENT
+BEGIN:VEVENT
+UID:rdate@example.com
+DTSTART:20260101T090000Z
+RDATE:20260105T090000Z
+SUMMARY:rdate
+END:VEVENT
+BEGIN:VEVENT
+DTSTART:20260101T090000Z
+SUMMARY:no uid
+END:VEVENT
+BEGIN:VEVENT
+UID:hourly@example.com
+DTSTART:20260101T090000Z
+RRULE:FREQ=DAILY;BYHOUR=9,10
+SUMMARY:by hour
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestImportCalendar(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 1, 1, 0, 0, 0, 0, tz8), nil)
+
+	results, err := ImportCalendar(strings.NewReader(utCalendarICS), env.alarmManager, env.clock.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 7, len(results))
+
+	statuses := make(map[string]CalendarImportStatus)
+	for _, result := range results {
+		statuses[result.UID] = result.Status
+	}
+
+	assert.Equal(t, map[string]CalendarImportStatus{
+		"weekly-1@example.com": CalendarImportAdded,
+		"once-1@example.com":   CalendarImportAdded,
+		"birthday@example.com": CalendarImportAdded,
+		"past@example.com":     CalendarImportSkipped,
+		"rdate@example.com":    CalendarImportFailed,
+		"":                     CalendarImportFailed,
+		"hourly@example.com":   CalendarImportFailed,
+	}, statuses)
+
+	weekly, err := env.alarmManager.Get(CalendarAlarmID("weekly-1@example.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, RecycleTimeTypeRRule, weekly.AType)
+	assert.Equal(t, "America/New_York", weekly.Location)
+	assert.Equal(t, "Standup, daily", weekly.Text)
+	assert.Equal(t, 15, weekly.EarlyShowMinute)
+
+	newYork := utMustLoadLocation(t, "America/New_York")
+
+	// 01-13 01-15 被排除
+	at, ok := env.timerAt(t, weekly.ID)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 6, 8, 45, 0, 0, newYork).Unix(), at.Unix())
+
+	av, err := ParseAlarmValue(weekly.Value, weekly.AType)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 1, 20, 9, 0, 0, 0, newYork), av.RRule.Next(time.Date(2026, 1, 9, 0, 0, 0, 0, newYork)))
+	assert.Equal(t, time.Date(2027, 6, 29, 9, 0, 0, 0, newYork), av.RRule.Prev(time.Date(2028, 1, 1, 0, 0, 0, 0, newYork)))
+
+	once, err := env.alarmManager.Get(CalendarAlarmID("once-1@example.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, TimeTypeOnce, once.AType)
+	assert.Equal(t, 8, once.TimeZone)
+	assert.Equal(t, "S20260301083000", once.Value)
+
+	birthday, err := env.alarmManager.Get(CalendarAlarmID("birthday@example.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, 24*60, birthday.EarlyShowMinute)
+
+	_, timeAt, _, _, _, err := birthday.GenRecycleDataEx(env.clock.Now(), env.clock.Now(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2026, 5, 20, 9, 0, 0, 0, tz8).Unix(), timeAt.Unix())
+
+	// 重复导入
+	results, err = ImportCalendar(strings.NewReader(utCalendarICS), env.alarmManager, env.clock.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, CalendarImportUnchanged, results[0].Status)
+	assert.Equal(t, CalendarImportUnchanged, results[1].Status)
+
+	alarms, err := env.alarmManager.List()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(alarms))
+
+	results, err = ImportCalendar(strings.NewReader(strings.Replace(utCalendarICS, "体检", "复查", 1)), env.alarmManager, env.clock.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, CalendarImportUpdated, results[1].Status)
+
+	_, err = ImportCalendar(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n"), env.alarmManager, env.clock.Now())
+	assert.NotNil(t, err)
+}
+
+func TestImportExportedCalendar(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 1, 1, 0, 0, 0, 0, tz8), nil)
+
+	var sb strings.Builder
+
+	assert.Nil(t, ExportCalendar(&sb, []*Alarm{
+		{ID: "A1", AType: RecycleTimeTypeWeek, Text: "周会", Value: "1090000", TimeZone: 8, EarlyShowMinute: 15},
+	}, nil, env.clock.Now(), time.Hour, nil))
+
+	results, err := ImportCalendar(strings.NewReader(sb.String()), env.alarmManager, env.clock.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, CalendarImportAdded, results[0].Status)
+
+	at, ok := env.timerAt(t, results[0].AlarmID)
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 5, 8, 45, 0, 0, tz8).Unix(), at.Unix())
+}
+
+func TestParseICSDuration(t *testing.T) {
+	for s, d := range map[string]time.Duration{
+		"-PT15M":    -15 * time.Minute,
+		"PT0S":      0,
+		"-P1D":      -24 * time.Hour,
+		"-P1DT2H":   -26 * time.Hour,
+		"P1W":       7 * 24 * time.Hour,
+		"+PT1H30M":  90 * time.Minute,
+		"-PT1H0M0S": -time.Hour,
+	} {
+		v, err := parseICSDuration(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, d, v, s)
+	}
+
+	for _, s := range []string{"", "15M", "P1M", "PT", "PT15"} {
+		_, err := parseICSDuration(s)
+		assert.NotNil(t, err, s)
+	}
+}
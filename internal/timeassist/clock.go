@@ -0,0 +1,150 @@
+package timeassist
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type ClockTimer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Clock 时间来源, 便于测试时模拟时间流逝
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) ClockTimer
+}
+
+func NewRealClock() Clock {
+	return &realClock{}
+}
+
+func fixClock(clock Clock) Clock {
+	if clock == nil {
+		return NewRealClock()
+	}
+
+	return clock
+}
+
+type realClock struct{}
+
+func (c *realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *realClock) NewTimer(d time.Duration) ClockTimer {
+	return &realClockTimer{
+		timer: time.NewTimer(d),
+	}
+}
+
+type realClockTimer struct {
+	timer *time.Timer
+}
+
+func (t *realClockTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realClockTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+//
+//
+//
+
+// FakeClock 手动推进的时钟, 只有 Advance/Set 时才会触发到期的 timer
+type FakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeClockTimer
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) ClockTimer {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	t := &fakeClockTimer{
+		clock: c,
+		at:    c.now.Add(d),
+		ch:    make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		t.ch <- c.now
+
+		return t
+	}
+
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+func (c *FakeClock) Set(now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.now = now
+
+	sort.Slice(c.timers, func(i, j int) bool {
+		return c.timers[i].at.Before(c.timers[j].at)
+	})
+
+	idx := 0
+	for ; idx < len(c.timers) && !c.timers[idx].at.After(now); idx++ {
+		c.timers[idx].ch <- now
+	}
+
+	c.timers = c.timers[idx:]
+}
+
+func (c *FakeClock) stopTimer(t *fakeClockTimer) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for idx, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:idx], c.timers[idx+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeClockTimer struct {
+	clock *FakeClock
+	at    time.Time
+	ch    chan time.Time
+}
+
+func (t *fakeClockTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeClockTimer) Stop() bool {
+	return t.clock.stopTimer(t)
+}
@@ -0,0 +1,130 @@
+package timeassist
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgostarter/libeasygo/stg/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+type utShowEvent struct {
+	at        time.Time
+	alarmFlag bool
+}
+
+// utRunTimerUntil 逐个推进 fake clock 到下一个定时点, 模拟 timer 的触发
+func utRunTimerUntil(t *testing.T, clock *FakeClock, timer TaskTimer, end time.Time) {
+	impl, ok := timer.(*taskTimerImpl)
+	assert.True(t, ok)
+
+	for {
+		items, err := timer.List()
+		assert.Nil(t, err)
+
+		if len(items) == 0 {
+			return
+		}
+
+		next := items[0].At
+		for _, item := range items {
+			if item.At.Before(next) {
+				next = item.At
+			}
+		}
+
+		if next.After(end) {
+			return
+		}
+
+		clock.Set(next.Add(time.Second))
+
+		impl.check()
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t1 := clock.NewTimer(time.Minute)
+	t2 := clock.NewTimer(time.Hour)
+
+	clock.Advance(time.Minute)
+
+	select {
+	case <-t1.C():
+	default:
+		assert.Fail(t, "t1 not fired")
+	}
+
+	assert.True(t, t2.Stop())
+
+	clock.Advance(time.Hour)
+
+	select {
+	case <-t2.C():
+		assert.Fail(t, "t2 fired after stop")
+	default:
+	}
+}
+
+func TestSimulateLunarYearAlarm(t *testing.T) {
+	dir := t.TempDir()
+
+	tz8 := time.FixedZone("z8", 8*3600)
+	clock := NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, tz8))
+
+	metaStorage, err := kv.NewMemoryFileStorageEx(filepath.Join(dir, "task_meta"), false)
+	assert.Nil(t, err)
+
+	var events []utShowEvent
+
+	showList := NewShowList(filepath.Join(dir, "task_list"), func(task *ShowInfo, visible bool) {
+		if !visible {
+			return
+		}
+
+		events = append(events, utShowEvent{
+			at:        clock.Now(),
+			alarmFlag: task.AlarmFlag,
+		})
+	}, nil, clock)
+
+	journal := NewIntentJournal(filepath.Join(dir, "task_journal"), metaStorage, showList)
+	timer := NewTaskTimer(filepath.Join(dir, "task_timer"), journal, nil, clock)
+
+	alarmManager := NewAlarmManager(metaStorage, NewBizTimer(timer), showList, nil, nil, clock)
+
+	err = alarmManager.Add(&Alarm{
+		AType:    RecycleTimeTypeYear,
+		Text:     "中秋",
+		Value:    "L0815200000",
+		TimeZone: 8,
+	})
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+
+	utRunTimerUntil(t, clock, timer, time.Date(2029, 1, 1, 0, 0, 0, 0, tz8))
+
+	var expected []utShowEvent
+
+	for year := 2026; year <= 2028; year++ {
+		lunarYear, _, _ := LunarYMD(time.Date(year, 6, 1, 0, 0, 0, 0, tz8))
+		alarmAt := LunarToDateTime(lunarYear, 8, 15, 20, 0, 0)
+
+		expected = append(expected, utShowEvent{
+			at: alarmAt.Add(-time.Hour * 24 * 7).Add(time.Second),
+		}, utShowEvent{
+			at:        alarmAt.Add(time.Second),
+			alarmFlag: true,
+		})
+	}
+
+	assert.Equal(t, len(expected), len(events))
+
+	for idx := 0; idx < len(expected) && idx < len(events); idx++ {
+		assert.True(t, expected[idx].at.Equal(events[idx].at), "%d: %v != %v", idx, expected[idx].at, events[idx].at)
+		assert.Equal(t, expected[idx].alarmFlag, events[idx].alarmFlag, "%d", idx)
+	}
+}
@@ -0,0 +1,285 @@
+package timeassist
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+// cronSearchYears 查找下次时间的最大年数, 超过认为表达式不会再触发(如 2 月 30 日)
+const cronSearchYears = 5
+
+type cronField struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronFieldSecond = cronField{min: 0, max: 59}
+	cronFieldMinute = cronField{min: 0, max: 59}
+	cronFieldHour   = cronField{min: 0, max: 23}
+	cronFieldDay    = cronField{min: 1, max: 31}
+	cronFieldMonth  = cronField{min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	cronFieldWeek = cronField{min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// CronSchedule
+// 5 段: 分 时 日 月 周, 秒固定为 0
+// 6 段: 秒 分 时 日 月 周
+// 每段支持 * ? a a-b a,b */n a-b/n a/n, 月支持 JAN-DEC, 周支持 SUN-SAT, 周的 7 等同于 0
+// 支持 @yearly @annually @monthly @weekly @daily @midnight @hourly
+// 日和周都不是 * 时, 满足其一即可(同标准 cron)
+type CronSchedule struct {
+	Expr string
+
+	second uint64
+	minute uint64
+	hour   uint64
+	day    uint64
+	month  uint64
+	week   uint64
+
+	dayAny  bool
+	weekAny bool
+}
+
+func ParseCron(expr string) (schedule *CronSchedule, err error) {
+	expr = strings.TrimSpace(expr)
+
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		if s, ok := cronDescriptors[strings.ToLower(fields[0])]; ok {
+			fields = strings.Fields(s)
+		}
+	}
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		err = commerr.ErrBadFormat
+
+		return
+	}
+
+	schedule = &CronSchedule{
+		Expr:    expr,
+		dayAny:  fields[3] == "*" || fields[3] == "?",
+		weekAny: fields[5] == "*" || fields[5] == "?",
+	}
+
+	for idx, p := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&schedule.second, cronFieldSecond},
+		{&schedule.minute, cronFieldMinute},
+		{&schedule.hour, cronFieldHour},
+		{&schedule.day, cronFieldDay},
+		{&schedule.month, cronFieldMonth},
+		{&schedule.week, cronFieldWeek},
+	} {
+		*p.bits, err = p.field.parse(fields[idx])
+		if err != nil {
+			schedule = nil
+
+			return
+		}
+	}
+
+	if schedule.week&(1<<7) != 0 {
+		schedule.week |= 1
+	}
+
+	return
+}
+
+func (f cronField) parse(s string) (bits uint64, err error) {
+	for _, part := range strings.Split(s, ",") {
+		var partBits uint64
+
+		partBits, err = f.parsePart(part)
+		if err != nil {
+			return
+		}
+
+		bits |= partBits
+	}
+
+	return
+}
+
+func (f cronField) parsePart(s string) (bits uint64, err error) {
+	step := 1
+
+	rangeS := s
+	if idx := strings.Index(s, "/"); idx >= 0 {
+		rangeS = s[:idx]
+
+		step, err = strconv.Atoi(s[idx+1:])
+		if err != nil || step <= 0 {
+			err = commerr.ErrBadFormat
+
+			return
+		}
+	}
+
+	start, end := f.min, f.max
+
+	switch {
+	case rangeS == "*" || rangeS == "?":
+	case strings.Contains(rangeS, "-"):
+		idx := strings.Index(rangeS, "-")
+
+		if start, err = f.value(rangeS[:idx]); err != nil {
+			return
+		}
+
+		if end, err = f.value(rangeS[idx+1:]); err != nil {
+			return
+		}
+	default:
+		if start, err = f.value(rangeS); err != nil {
+			return
+		}
+
+		if !strings.Contains(s, "/") {
+			end = start
+		}
+	}
+
+	if start > end {
+		err = commerr.ErrBadFormat
+
+		return
+	}
+
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+
+	return
+}
+
+func (f cronField) value(s string) (v int, err error) {
+	if n, ok := f.names[strings.ToUpper(s)]; ok {
+		v = n
+
+		return
+	}
+
+	v, err = strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		err = commerr.ErrBadFormat
+	}
+
+	return
+}
+
+func (schedule *CronSchedule) String() string {
+	return schedule.Expr
+}
+
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	dayOK := schedule.day&(1<<uint(t.Day())) != 0
+	weekOK := schedule.week&(1<<uint(t.Weekday())) != 0
+
+	if schedule.dayAny || schedule.weekAny {
+		return dayOK && weekOK
+	}
+
+	return dayOK || weekOK
+}
+
+// Next 返回不早于 t 的第一个匹配时间(按 t 的时区), 找不到时返回零值
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	if t.Nanosecond() > 0 {
+		t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	}
+
+	yearLimit := t.Year() + cronSearchYears
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for schedule.month&(1<<uint(t.Month())) == 0 {
+		t = MonthStart(MonthAdd(t, 1))
+
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !schedule.dayMatches(t) {
+		t = DayStart(DayAdd(t, 1))
+
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for schedule.hour&(1<<uint(t.Hour())) == 0 {
+		t = HourStart(HourAdd(t, 1))
+
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for schedule.minute&(1<<uint(t.Minute())) == 0 {
+		t = MinuteStart(MinuteAdd(t, 1))
+
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for schedule.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t
+}
+
+// cronShowDuration 按两次触发的间隔取提前显示时长, 与其他周期类型保持一致
+func cronShowDuration(interval time.Duration) time.Duration {
+	switch {
+	case interval >= 365*24*time.Hour:
+		return time.Hour * 24 * 7
+	case interval >= 28*24*time.Hour:
+		return time.Hour * 24 * 2
+	case interval >= 7*24*time.Hour:
+		return time.Hour * 24
+	case interval >= 24*time.Hour:
+		return time.Hour
+	case interval >= time.Hour:
+		return time.Minute * 5
+	}
+
+	return time.Second * 5
+}
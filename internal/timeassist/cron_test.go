@@ -0,0 +1,119 @@
+package timeassist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"0 9,14 * * 1-5",
+		"*/15 9-17 * * MON-FRI",
+		"30 0 9 1 JAN,JUL ?",
+		"0 0 * * 7",
+		"@daily",
+	} {
+		_, err := ParseCron(expr)
+		assert.Nil(t, err, expr)
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-3 * * * *",
+		"@every",
+	} {
+		_, err := ParseCron(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tz := time.FixedZone("UT", 8*3600)
+
+	schedule, err := ParseCron("0 9,14 * * 1-5")
+	assert.Nil(t, err)
+
+	// 2023-02-10 周五
+	assert.Equal(t, time.Date(2023, 2, 10, 14, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 10, 9, 0, 1, 0, tz)))
+	assert.Equal(t, time.Date(2023, 2, 13, 9, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 10, 14, 0, 1, 0, tz)))
+	assert.Equal(t, time.Date(2023, 2, 10, 9, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 10, 9, 0, 0, 0, tz)))
+
+	schedule, err = ParseCron("*/15 9-17 * * *")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 2, 10, 17, 45, 0, 0, tz), schedule.Next(time.Date(2023, 2, 10, 17, 31, 0, 0, tz)))
+	assert.Equal(t, time.Date(2023, 2, 11, 9, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 10, 17, 46, 0, 0, tz)))
+
+	// 日和周都指定时满足其一即可
+	schedule, err = ParseCron("0 0 8 13 * 5")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 2, 13, 8, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 11, 0, 0, 0, 0, tz)))
+	assert.Equal(t, time.Date(2023, 2, 17, 8, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 13, 9, 0, 0, 0, tz)))
+
+	schedule, err = ParseCron("0 0 29 2 *")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, tz), schedule.Next(time.Date(2023, 2, 10, 0, 0, 0, 0, tz)))
+
+	schedule, err = ParseCron("0 0 30 2 *")
+	assert.Nil(t, err)
+	assert.True(t, schedule.Next(time.Date(2023, 2, 10, 0, 0, 0, 0, tz)).IsZero())
+}
+
+func TestAlarmCron(t *testing.T) {
+	tz := time.FixedZone("UT", 8*3600)
+
+	alarm := &Alarm{
+		ID:       "A1",
+		AType:    RecycleTimeTypeCron,
+		Text:     "meeting",
+		Value:    "0 9,14 * * 1-5",
+		TimeZone: 8,
+	}
+
+	timeNow := time.Date(2023, 2, 10, 8, 0, 0, 0, tz)
+
+	av, timeAt, rd, show, alarmFlag, err := alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.False(t, show)
+	assert.False(t, alarmFlag)
+	assert.Equal(t, time.Date(2023, 2, 10, 9, 0, 0, 0, tz).Unix(), timeAt.Unix())
+	assert.Equal(t, time.Date(2023, 2, 10, 8, 55, 0, 0, tz).Unix(), rd.StartUTC)
+
+	// 周五 14 点之后下一次是周一, 间隔超过一天
+	_, _, rd, _, _, err = alarm.GenRecycleDataEx(time.Date(2023, 2, 10, 10, 0, 0, 0, tz), timeNow, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 2, 10, 13, 0, 0, 0, tz).Unix(), rd.StartUTC)
+
+	_, s := av.StringNoNowTime(alarm.AType)
+	assert.Equal(t, "定时[0 9,14 * * 1-5]", s)
+
+	alarm.EarlyShowMinute = 30
+
+	_, _, rd, _, _, err = alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 2, 10, 8, 30, 0, 0, tz).Unix(), rd.StartUTC)
+
+	// 2023-04-23 周日调休上班, 2023-04-29 ~ 2023-05-03 劳动节
+	alarm.Value = "0 9 * * *"
+	alarm.ValidTime = &ValidTime{
+		OnlyWorkDays: true,
+	}
+
+	timeNow = time.Date(2023, 4, 28, 10, 0, 0, 0, tz)
+
+	_, timeAt, _, _, _, err = alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2023, 5, 4, 9, 0, 0, 0, tz).Unix(), timeAt.Unix())
+
+	alarm.Value = "0 9 * *"
+	_, err = alarm.Validate()
+	assert.NotNil(t, err)
+}
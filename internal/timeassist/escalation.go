@@ -0,0 +1,89 @@
+package timeassist
+
+import (
+	"strings"
+	"time"
+)
+
+// Escalation 过期后没有完成时, 每 IntervalMinutes 分钟重新通知一次, 最多 MaxTimes 次.
+// SwitchAfter 大于 0 时, 已经通知 SwitchAfter 次(包含第一次)后改用 SwitchRoute
+type Escalation struct {
+	IntervalMinutes int          `yaml:"IntervalMinutes" json:"interval_minutes"`
+	MaxTimes        int          `yaml:"MaxTimes" json:"max_times"`
+	SwitchAfter     int          `yaml:"SwitchAfter,omitempty" json:"switch_after,omitempty"`
+	SwitchRoute     *NotifyRoute `yaml:"SwitchRoute,omitempty" json:"switch_route,omitempty"`
+}
+
+func (e *Escalation) enabled() bool {
+	return e != nil && e.IntervalMinutes > 0 && e.MaxTimes > 0
+}
+
+// route escalationCount 为已经重新通知的次数
+func (e *Escalation) route(route *NotifyRoute, escalationCount int) *NotifyRoute {
+	if e == nil || e.SwitchAfter <= 0 || e.SwitchRoute == nil || escalationCount+1 <= e.SwitchAfter {
+		return route
+	}
+
+	return e.SwitchRoute
+}
+
+// escalationTimer 第一次重新通知的定时, 没有开启时返回 nil
+func (e *Escalation) escalationTimer(id string, timeNow time.Time) *D {
+	if !e.enabled() {
+		return nil
+	}
+
+	at := timeNow.Add(time.Duration(e.IntervalMinutes) * time.Minute)
+
+	return &D{
+		Data: &ShowItem{
+			ID:       EscalateTimerID(id),
+			StartUTC: at.Unix(),
+			EndUTC:   at.Unix(),
+		},
+		At: at,
+	}
+}
+
+// escalate 重新显示并通知; 已经完成(不在 ShowList 中)或不再是过期状态时结束
+func escalate(showList ShowList, id string, escalation *Escalation, intent *Intent, timeNow time.Time) (at time.Time, data *ShowItem, err error) {
+	if !escalation.enabled() {
+		return
+	}
+
+	showInfo, err := showList.Get(id)
+	if err != nil || showInfo == nil || !showInfo.AlarmFlag || showInfo.EscalationCount >= escalation.MaxTimes {
+		return
+	}
+
+	showInfo.EscalationCount++
+
+	intent.TargetID = id
+	intent.AddShow(showInfo)
+
+	intent.AddEvent(EventEscalated, id, &EventEscalation{Count: showInfo.EscalationCount})
+
+	if showInfo.EscalationCount < escalation.MaxTimes {
+		d := escalation.escalationTimer(id, timeNow)
+
+		at = d.At
+		data = d.Data
+	}
+
+	return
+}
+
+const escalateIDSuffix = "#escalate"
+
+// EscalateTimerID 重新通知使用独立的定时, 不影响周期定时
+func EscalateTimerID(id string) string {
+	return id + escalateIDSuffix
+}
+
+func ParseEscalateTimerID(id string) (targetID string, ok bool) {
+	if !strings.HasSuffix(id, escalateIDSuffix) {
+		return
+	}
+
+	return strings.TrimSuffix(id, escalateIDSuffix), true
+}
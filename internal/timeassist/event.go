@@ -0,0 +1,160 @@
+package timeassist
+
+import (
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventShowAdded      EventType = "show-added"
+	EventShowRemoved    EventType = "show-removed"
+	EventAlarmExpired   EventType = "alarm-expired"
+	EventTaskRolledOver EventType = "task-rolled-over"
+	EventTimerScheduled EventType = "timer-scheduled"
+	EventTimerRemoved   EventType = "timer-removed"
+	EventEscalated      EventType = "escalated"
+)
+
+const (
+	DefaultEventBufferSize = 1024
+
+	eventSubscriberBufferSize = 256
+)
+
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     EventType   `json:"type"`
+	At       time.Time   `json:"at"`
+	TargetID string      `json:"target_id"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// EventPeriod task-rolled-over 的新周期
+type EventPeriod struct {
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+}
+
+// EventTimer timer-scheduled 的触发时间
+type EventTimer struct {
+	At time.Time `json:"at"`
+}
+
+// EventEscalation escalated 为第几次重新通知
+type EventEscalation struct {
+	Count int `json:"count"`
+}
+
+type EventBus interface {
+	Publish(eventType EventType, targetID string, data interface{})
+	// Subscribe 返回缓存中 ID 大于 lastEventID 的事件和之后的新事件;
+	// 订阅者处理不过来时 ch 被关闭, 需要带上最后的 ID 重新订阅
+	Subscribe(lastEventID uint64) (backlog []*Event, ch <-chan *Event, cancel func())
+}
+
+// NewEventBus 只在内存中保留最近 bufferSize 个事件.
+// ID 从启动时间(微秒)开始递增, 重启后客户端带着旧的 ID 也不会漏掉新的事件
+func NewEventBus(bufferSize int, clock Clock) EventBus {
+	if bufferSize <= 0 {
+		bufferSize = DefaultEventBufferSize
+	}
+
+	clock = fixClock(clock)
+
+	return &eventBusImpl{
+		clock:       clock,
+		ring:        make([]*Event, bufferSize),
+		seq:         uint64(clock.Now().UnixNano() / int64(time.Microsecond)),
+		subscribers: make(map[chan *Event]struct{}),
+	}
+}
+
+type eventBusImpl struct {
+	clock Clock
+
+	lock        sync.Mutex
+	ring        []*Event
+	head        int
+	count       int
+	seq         uint64
+	subscribers map[chan *Event]struct{}
+}
+
+func (impl *eventBusImpl) Publish(eventType EventType, targetID string, data interface{}) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	impl.seq++
+
+	event := &Event{
+		ID:       impl.seq,
+		Type:     eventType,
+		At:       impl.clock.Now(),
+		TargetID: targetID,
+		Data:     data,
+	}
+
+	impl.ring[(impl.head+impl.count)%len(impl.ring)] = event
+
+	if impl.count < len(impl.ring) {
+		impl.count++
+	} else {
+		impl.head = (impl.head + 1) % len(impl.ring)
+	}
+
+	for ch := range impl.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(impl.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (impl *eventBusImpl) Subscribe(lastEventID uint64) (backlog []*Event, ch <-chan *Event, cancel func()) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	for idx := 0; idx < impl.count; idx++ {
+		event := impl.ring[(impl.head+idx)%len(impl.ring)]
+		if event.ID > lastEventID {
+			backlog = append(backlog, event)
+		}
+	}
+
+	subscriber := make(chan *Event, eventSubscriberBufferSize)
+	impl.subscribers[subscriber] = struct{}{}
+
+	ch = subscriber
+	cancel = func() {
+		impl.lock.Lock()
+		defer impl.lock.Unlock()
+
+		if _, ok := impl.subscribers[subscriber]; ok {
+			delete(impl.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+
+	return
+}
+
+// fixEventBus 为空时不发布事件
+func fixEventBus(bus EventBus) EventBus {
+	if bus == nil {
+		return &nopEventBus{}
+	}
+
+	return bus
+}
+
+type nopEventBus struct{}
+
+func (*nopEventBus) Publish(_ EventType, _ string, _ interface{}) {}
+
+func (*nopEventBus) Subscribe(_ uint64) (backlog []*Event, ch <-chan *Event, cancel func()) {
+	return nil, nil, func() {}
+}
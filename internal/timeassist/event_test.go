@@ -0,0 +1,146 @@
+package timeassist
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBusResume(t *testing.T) {
+	bus := NewEventBus(3, NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	backlog, ch, cancel := bus.Subscribe(0)
+	assert.Equal(t, 0, len(backlog))
+
+	for idx := 0; idx < 5; idx++ {
+		bus.Publish(EventTimerRemoved, "Aa", nil)
+	}
+
+	var ids []uint64
+
+	for idx := 0; idx < 5; idx++ {
+		ids = append(ids, (<-ch).ID)
+	}
+
+	cancel()
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	for idx := 1; idx < len(ids); idx++ {
+		assert.Equal(t, ids[idx-1]+1, ids[idx])
+	}
+
+	// 只保留最近 3 个
+	backlog, _, cancel = bus.Subscribe(ids[1])
+	assert.Equal(t, 3, len(backlog))
+	assert.Equal(t, ids[2], backlog[0].ID)
+	cancel()
+
+	backlog, _, cancel = bus.Subscribe(ids[3])
+	assert.Equal(t, 1, len(backlog))
+	assert.Equal(t, ids[4], backlog[0].ID)
+	cancel()
+
+	// 处理不过来的订阅者被断开
+	_, ch, cancel = bus.Subscribe(ids[4])
+	defer cancel()
+
+	for idx := 0; idx <= eventSubscriberBufferSize; idx++ {
+		bus.Publish(EventTimerRemoved, "Aa", nil)
+	}
+
+	var n int
+	for range ch {
+		n++
+	}
+
+	assert.Equal(t, eventSubscriberBufferSize, n)
+}
+
+func TestSchedulerEvents(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 1, 1, 0, 0, 0, 0, tz8), nil)
+
+	_, ch, cancel := env.bus.Subscribe(0)
+	defer cancel()
+
+	alarm := &Alarm{
+		AType:    TimeTypeOnce,
+		Text:     "once",
+		Value:    "S20260101090000",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	task := &Task{
+		TType:    RecycleTimeTypeDay,
+		Value:    1,
+		Text:     "daily",
+		TimeZone: 8,
+		Auto:     true,
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 1, 2, 12, 0, 0, 0, tz8))
+
+	events := make(map[EventType][]*Event)
+
+	for len(ch) > 0 {
+		event := <-ch
+		events[event.Type] = append(events[event.Type], event)
+	}
+
+	assert.Equal(t, 1, len(events[EventAlarmExpired]))
+	assert.Equal(t, alarm.ID, events[EventAlarmExpired][0].TargetID)
+
+	assert.NotEqual(t, 0, len(events[EventTaskRolledOver]))
+	assert.Equal(t, task.ID, events[EventTaskRolledOver][0].TargetID)
+	assert.Equal(t, time.Date(2026, 1, 2, 0, 0, 0, 0, tz8).Unix(),
+		events[EventTaskRolledOver][0].Data.(*EventPeriod).StartAt.Unix())
+
+	var alarmTimerRemoved bool
+
+	for _, event := range events[EventTimerRemoved] {
+		alarmTimerRemoved = alarmTimerRemoved || event.TargetID == alarm.ID
+	}
+
+	assert.True(t, alarmTimerRemoved)
+	assert.NotEqual(t, 0, len(events[EventTimerScheduled]))
+	assert.NotEqual(t, 0, len(events[EventShowAdded]))
+}
+
+func TestIntentEventsAfterApply(t *testing.T) {
+	bus := NewEventBus(0, nil)
+
+	// 没有 journal 时 ShowList/meta 的变化无法执行
+	timer := NewTaskTimer(filepath.Join(t.TempDir(), "task_timer"), nil, bus, nil)
+
+	_, ch, cancel := bus.Subscribe(0)
+	defer cancel()
+
+	failed := &Intent{ID: "A1"}
+	failed.RemoveShow()
+	failed.AddEvent(EventAlarmExpired, "A1", nil)
+
+	assert.NotNil(t, timer.ApplyIntent(failed))
+
+	applied := &Intent{ID: "A2"}
+	applied.AddEvent(EventAlarmExpired, "A2", nil)
+
+	assert.Nil(t, timer.ApplyIntent(applied))
+
+	var expiredIDs []string
+
+	for len(ch) > 0 {
+		if event := <-ch; event.Type == EventAlarmExpired {
+			expiredIDs = append(expiredIDs, event.TargetID)
+		}
+	}
+
+	assert.Equal(t, []string{"A2"}, expiredIDs)
+}
@@ -0,0 +1,177 @@
+package timeassist
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/6tail/lunar-go/HolidayUtil"
+	"github.com/sgostarter/i/commerr"
+	"gopkg.in/yaml.v3"
+)
+
+const holidayDateLayout = "2006-01-02"
+
+// HolidayDay 法定节假日或调休上班的某一天
+type HolidayDay struct {
+	Date string `yaml:"Date" json:"date"` // 2006-01-02
+	Name string `yaml:"Name" json:"name"`
+	Work bool   `yaml:"Work" json:"work"` // true: 调休上班
+}
+
+type HolidayCalendar interface {
+	// IsHoliday 是否法定节假日(不含普通周末)
+	IsHoliday(t time.Time) bool
+	// IsWorkDay 是否工作日, 调休上班的周末也算工作日
+	IsWorkDay(t time.Time) bool
+	Get(t time.Time) (day HolidayDay, ok bool)
+}
+
+// NewHolidayCalendar 有数据的年份以 days 为准, 其他年份使用 lunar-go 内置数据
+func NewHolidayCalendar(days []HolidayDay) (HolidayCalendar, error) {
+	impl := &holidayCalendarImpl{
+		years: make(map[int]map[string]HolidayDay),
+	}
+
+	if err := impl.init(days); err != nil {
+		return nil, err
+	}
+
+	return impl, nil
+}
+
+// LoadHolidayCalendar 加载 root 目录下的 yaml/json 文件, 一般每年一个文件, 如 2025.yaml
+func LoadHolidayCalendar(root string) (HolidayCalendar, error) {
+	var days []HolidayDay
+
+	err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		fileDays, err := loadHolidayFile(path)
+		if err != nil {
+			return err
+		}
+
+		days = append(days, fileDays...)
+
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return NewHolidayCalendar(days)
+}
+
+func loadHolidayFile(file string) (days []HolidayDay, err error) {
+	var unmarshal func([]byte, any) error
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		unmarshal = yaml.Unmarshal
+	case ".json":
+		unmarshal = json.Unmarshal
+	default:
+		return
+	}
+
+	d, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	err = unmarshal(d, &days)
+
+	return
+}
+
+type holidayCalendarImpl struct {
+	years map[int]map[string]HolidayDay
+}
+
+func (impl *holidayCalendarImpl) init(days []HolidayDay) error {
+	for _, day := range days {
+		t, err := time.Parse(holidayDateLayout, day.Date)
+		if err != nil {
+			return commerr.ErrBadFormat
+		}
+
+		if _, ok := impl.years[t.Year()]; !ok {
+			impl.years[t.Year()] = make(map[string]HolidayDay)
+		}
+
+		impl.years[t.Year()][day.Date] = day
+	}
+
+	return nil
+}
+
+func (impl *holidayCalendarImpl) Get(t time.Time) (day HolidayDay, ok bool) {
+	date := t.Format(holidayDateLayout)
+
+	if days, exists := impl.years[t.Year()]; exists {
+		day, ok = days[date]
+
+		return
+	}
+
+	holiday := HolidayUtil.GetHoliday(date)
+	if holiday == nil {
+		return
+	}
+
+	day = HolidayDay{
+		Date: holiday.GetDay(),
+		Name: holiday.GetName(),
+		Work: holiday.IsWork(),
+	}
+	ok = true
+
+	return
+}
+
+func (impl *holidayCalendarImpl) IsHoliday(t time.Time) bool {
+	day, ok := impl.Get(t)
+
+	return ok && !day.Work
+}
+
+func (impl *holidayCalendarImpl) IsWorkDay(t time.Time) bool {
+	if day, ok := impl.Get(t); ok {
+		return day.Work
+	}
+
+	switch t.Weekday() {
+	case time.Sunday, time.Saturday:
+		return false
+	}
+
+	return true
+}
+
+//
+//
+//
+
+// builtinHolidayCalendar 只使用 lunar-go 内置数据, 不会修改
+var builtinHolidayCalendar HolidayCalendar = &holidayCalendarImpl{
+	years: make(map[int]map[string]HolidayDay),
+}
+
+// fixHolidayCalendar 为空时使用内置数据
+func fixHolidayCalendar(calendar HolidayCalendar) HolidayCalendar {
+	if calendar == nil {
+		return builtinHolidayCalendar
+	}
+
+	return calendar
+}
@@ -0,0 +1,199 @@
+package timeassist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolidayCalendarBuiltin(t *testing.T) {
+	calendar, err := NewHolidayCalendar(nil)
+	assert.Nil(t, err)
+
+	tz := time.FixedZone("UT", 8*3600)
+
+	assert.True(t, calendar.IsHoliday(time.Date(2024, 10, 1, 9, 0, 0, 0, tz)))
+	assert.False(t, calendar.IsWorkDay(time.Date(2024, 10, 7, 9, 0, 0, 0, tz)))
+	assert.True(t, calendar.IsWorkDay(time.Date(2024, 10, 8, 9, 0, 0, 0, tz)))
+	// 周日调休上班
+	assert.True(t, calendar.IsWorkDay(time.Date(2024, 9, 29, 9, 0, 0, 0, tz)))
+	assert.False(t, calendar.IsHoliday(time.Date(2024, 9, 29, 9, 0, 0, 0, tz)))
+	assert.False(t, calendar.IsWorkDay(time.Date(2024, 9, 28, 9, 0, 0, 0, tz)))
+}
+
+func TestHolidayCalendarLoad(t *testing.T) {
+	root := t.TempDir()
+
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "2030.yaml"), []byte(`
+- {Date: "2030-10-01", Name: 国庆节}
+- {Date: "2030-10-12", Name: 国庆节调休, Work: true}
+`), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "2031.json"), []byte(`[{"date": "2031-01-01", "name": "元旦"}]`), 0600))
+
+	calendar, err := LoadHolidayCalendar(root)
+	assert.Nil(t, err)
+
+	tz := time.FixedZone("UT", 8*3600)
+
+	assert.True(t, calendar.IsHoliday(time.Date(2030, 10, 1, 0, 0, 0, 0, tz)))
+	assert.True(t, calendar.IsWorkDay(time.Date(2030, 10, 12, 0, 0, 0, 0, tz)))
+	assert.True(t, calendar.IsWorkDay(time.Date(2030, 10, 2, 0, 0, 0, 0, tz)))
+	assert.True(t, calendar.IsHoliday(time.Date(2031, 1, 1, 0, 0, 0, 0, tz)))
+
+	// 没有文件的年份使用内置数据
+	assert.True(t, calendar.IsHoliday(time.Date(2024, 10, 1, 0, 0, 0, 0, tz)))
+
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "bad.yaml"), []byte(`- {Date: "10/01"}`), 0600))
+
+	_, err = LoadHolidayCalendar(root)
+	assert.NotNil(t, err)
+
+	calendar, err = LoadHolidayCalendar(filepath.Join(root, "not-exists"))
+	assert.Nil(t, err)
+	assert.NotNil(t, calendar)
+}
+
+func TestValidTimeWorkDays(t *testing.T) {
+	tz := time.FixedZone("UT", 8*3600)
+
+	vt := &ValidTime{
+		OnlyWorkDays: true,
+	}
+
+	ok, nextT := vt.FindAfterTime(time.Date(2024, 10, 1, 9, 0, 0, 0, tz), nil)
+	assert.False(t, ok)
+	assert.Equal(t, time.Date(2024, 10, 8, 0, 0, 0, 0, tz), nextT)
+
+	ok, _ = vt.FindAfterTime(time.Date(2024, 10, 12, 9, 0, 0, 0, tz), nil)
+	assert.True(t, ok)
+
+	vt.Reset()
+	vt.SkipHolidays = true
+
+	ok, nextT = vt.FindAfterTime(time.Date(2024, 10, 5, 9, 0, 0, 0, tz), nil)
+	assert.False(t, ok)
+	assert.Equal(t, time.Date(2024, 10, 8, 0, 0, 0, 0, tz), nextT)
+
+	// 普通周末不是法定节假日
+	ok, _ = vt.FindAfterTime(time.Date(2024, 10, 19, 9, 0, 0, 0, tz), nil)
+	assert.True(t, ok)
+}
+
+// 节假日数据用完后周末不会再有调休上班, 查找要能结束
+func TestValidTimeNeverValid(t *testing.T) {
+	tz := time.FixedZone("UT", 8*3600)
+
+	vt := &ValidTime{
+		ValidDaysInWeek: &ValidRanges{ValidRanges: []ValidRange{{Start: 0, End: 1}, {Start: 6, End: 7}}},
+		OnlyWorkDays:    true,
+	}
+
+	timeFrom := time.Date(2031, 1, 1, 9, 0, 0, 0, tz)
+
+	ok, _ := vt.FindAfterTime(timeFrom, nil)
+	assert.False(t, ok)
+	assert.ErrorIs(t, vt.Valid(timeFrom, nil), commerr.ErrInvalidArgument)
+
+	// 2024-09-29 周日调休上班
+	assert.Nil(t, vt.Valid(time.Date(2024, 9, 1, 9, 0, 0, 0, tz), nil))
+
+	alarm := &Alarm{
+		ID:        "A1",
+		AType:     RecycleTimeTypeDay,
+		Text:      "weekend work",
+		Value:     "090000",
+		TimeZone:  8,
+		ValidTime: vt,
+	}
+
+	_, _, _, _, _, err := alarm.GenRecycleDataEx(timeFrom, timeFrom, nil)
+	assert.NotNil(t, err)
+}
+
+func TestAlarmOnlyWorkDays(t *testing.T) {
+	tz := time.FixedZone("UT", 8*3600)
+
+	alarm := &Alarm{
+		ID:       "A1",
+		AType:    RecycleTimeTypeDay,
+		Text:     "work",
+		Value:    "090000",
+		TimeZone: 8,
+		ValidTime: &ValidTime{
+			OnlyWorkDays: true,
+		},
+	}
+
+	_, timeAt, _, _, _, err := alarm.GenRecycleDataEx(time.Date(2024, 9, 30, 10, 0, 0, 0, tz), time.Date(2024, 9, 30, 10, 0, 0, 0, tz), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 10, 8, 9, 0, 0, 0, tz).Unix(), timeAt.Unix())
+
+	_, timeAt, _, _, _, err = alarm.GenRecycleDataEx(time.Date(2024, 10, 8, 10, 0, 0, 0, tz), time.Date(2024, 10, 8, 10, 0, 0, 0, tz), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 10, 9, 9, 0, 0, 0, tz).Unix(), timeAt.Unix())
+
+	// 周六调休上班
+	_, timeAt, _, _, _, err = alarm.GenRecycleDataEx(time.Date(2024, 10, 11, 10, 0, 0, 0, tz), time.Date(2024, 10, 11, 10, 0, 0, 0, tz), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 10, 12, 9, 0, 0, 0, tz).Unix(), timeAt.Unix())
+
+	task := &Task{
+		TType:    RecycleTimeTypeHour,
+		Value:    1,
+		TimeZone: 8,
+		ValidTime: &ValidTime{
+			OnlyWorkDays: true,
+		},
+	}
+
+	rd, nowIsValid := task.GenRecycleDataEx(time.Date(2024, 10, 6, 10, 0, 0, 0, tz), nil)
+	assert.False(t, nowIsValid)
+	assert.Equal(t, time.Date(2024, 10, 8, 0, 0, 0, 0, tz).Unix(), rd.StartUTC)
+}
+
+func TestHolidayCalendarInject(t *testing.T) {
+	root := t.TempDir()
+
+	assert.Nil(t, os.WriteFile(filepath.Join(root, "2031.yaml"), []byte(`
+- {Date: "2031-01-05", Name: 调休, Work: true}
+`), 0600))
+
+	calendar, err := LoadHolidayCalendar(root)
+	assert.Nil(t, err)
+
+	tz := time.FixedZone("UT", 8*3600)
+	timeFrom := time.Date(2031, 1, 1, 9, 0, 0, 0, tz)
+
+	vt := &ValidTime{
+		ValidDaysInWeek: &ValidRanges{ValidRanges: []ValidRange{{Start: 0, End: 1}, {Start: 6, End: 7}}},
+		OnlyWorkDays:    true,
+	}
+
+	ok, nextT := vt.FindAfterTime(timeFrom, calendar)
+	assert.False(t, ok)
+	assert.Equal(t, time.Date(2031, 1, 5, 0, 0, 0, 0, tz), nextT)
+	assert.Nil(t, vt.Valid(timeFrom, calendar))
+
+	alarm := &Alarm{
+		AType:     RecycleTimeTypeDay,
+		Text:      "weekend work",
+		Value:     "090000",
+		TimeZone:  8,
+		ValidTime: vt,
+	}
+
+	occurrences, err := PreviewAlarmLocale(alarm, timeFrom, 1, locale.Default, calendar)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 1)
+	assert.Equal(t, time.Date(2031, 1, 5, 9, 0, 0, 0, tz).Unix(), occurrences[0].FireAt.At.Unix())
+
+	env := utNewEnv(t, timeFrom, nil)
+	alarmManager := NewAlarmManager(env.metaStorage, NewBizTimer(env.timer), env.showList, calendar, nil, env.clock)
+
+	assert.Nil(t, alarmManager.Add(alarm))
+}
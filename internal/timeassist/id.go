@@ -1,9 +1,11 @@
 package timeassist
 
 import (
+	"sort"
 	"strings"
 
 	uuid "github.com/satori/go.uuid"
+	"github.com/sgostarter/libeasygo/stg/kv"
 )
 
 const (
@@ -24,6 +26,41 @@ func ParsePreOnID(id string) string {
 	return pre
 }
 
+// listMetaIDs meta 存储中 Alarm 和 Task 共用, 按 ID 前缀区分
+func listMetaIDs(storage kv.StorageCollect, idPre string) (ids []string, err error) {
+	ds, err := storage.GetMap(func(_ string) interface{} {
+		return &struct{}{}
+	})
+	if err != nil {
+		return
+	}
+
+	for id := range ds {
+		if ParsePreOnID(id) == idPre {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return
+}
+
+const snoozeIDSuffix = "#snooze"
+
+// SnoozeTimerID 稍后提醒使用独立的定时, 不影响 alarm 本身的周期定时
+func SnoozeTimerID(alarmID string) string {
+	return alarmID + snoozeIDSuffix
+}
+
+func ParseSnoozeTimerID(id string) (alarmID string, ok bool) {
+	if !strings.HasSuffix(id, snoozeIDSuffix) {
+		return
+	}
+
+	return strings.TrimSuffix(id, snoozeIDSuffix), true
+}
+
 func FixTaskID(id string) string {
 	return fixID(id, TaskIDPre)
 }
@@ -0,0 +1,235 @@
+package timeassist
+
+import (
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/libeasygo/stg/kv"
+)
+
+type IntentStage int
+
+const (
+	IntentStageBegin IntentStage = iota
+	IntentStageShowApplied
+	IntentStageMetaApplied
+	IntentStageTimerApplied
+)
+
+// Intent 一次定时回调产生的所有状态变化
+// 回调只记录变化, 由 timer 先落盘再依次执行, 程序中途退出时启动后重放
+type Intent struct {
+	ID       string      `yaml:"ID"`
+	TargetID string      `yaml:"TargetID,omitempty"` // ShowList/meta 变化作用的 ID, 为空时即 ID
+	Fired    *D          `yaml:"Fired,omitempty"`
+	Stage    IntentStage `yaml:"Stage"`
+
+	ShowAdd    *ShowInfo `yaml:"ShowAdd,omitempty"`
+	ShowRemove bool      `yaml:"ShowRemove,omitempty"`
+
+	AlarmMeta *Alarm `yaml:"AlarmMeta,omitempty"`
+	TaskMeta  *Task  `yaml:"TaskMeta,omitempty"`
+
+	TimerAt   time.Time `yaml:"TimerAt,omitempty"`
+	TimerData *ShowItem `yaml:"TimerData,omitempty"`
+	// Timers 其它 ID 的定时, 如过期后的重新通知
+	Timers []*D `yaml:"Timers,omitempty"`
+
+	// events 执行成功后才发布, 不落盘, 重放时不再发布
+	events []*Event
+
+	replayed bool
+}
+
+func (intent *Intent) targetID() string {
+	if intent.TargetID != "" {
+		return intent.TargetID
+	}
+
+	return intent.ID
+}
+
+func (intent *Intent) AddShow(showInfo *ShowInfo) {
+	intent.ShowAdd = showInfo
+	intent.ShowRemove = false
+}
+
+func (intent *Intent) RemoveShow() {
+	intent.ShowAdd = nil
+	intent.ShowRemove = true
+}
+
+func (intent *Intent) AddTimer(d *D) {
+	if d != nil {
+		intent.Timers = append(intent.Timers, d)
+	}
+}
+
+func (intent *Intent) AddEvent(eventType EventType, targetID string, data interface{}) {
+	intent.events = append(intent.events, &Event{
+		Type:     eventType,
+		TargetID: targetID,
+		Data:     data,
+	})
+}
+
+func (intent *Intent) publishEvents(bus EventBus) {
+	for _, event := range intent.events {
+		bus.Publish(event.Type, event.TargetID, event.Data)
+	}
+
+	intent.events = nil
+}
+
+func (intent *Intent) SetAlarm(alarm *Alarm) {
+	intent.AlarmMeta = alarm
+}
+
+func (intent *Intent) SetTask(task *Task) {
+	intent.TaskMeta = task
+}
+
+type IntentJournal interface {
+	Begin(intent *Intent) error
+	ApplyEffects(intent *Intent) error
+	MarkStage(intent *Intent, stage IntentStage) error
+	Finish(id string) error
+	Pending() ([]*Intent, error)
+}
+
+func NewIntentJournal(fileName string, metaStorage kv.Storage, showList ShowList) IntentJournal {
+	storage, err := kv.NewMemoryFileStorageEx(fileName, false)
+	if err != nil {
+		return nil
+	}
+
+	return &intentJournalImpl{
+		storage:     storage,
+		metaStorage: metaStorage,
+		showList:    showList,
+	}
+}
+
+type intentJournalImpl struct {
+	storage     kv.StorageTiny
+	metaStorage kv.Storage
+	showList    ShowList
+}
+
+func (impl *intentJournalImpl) Begin(intent *Intent) error {
+	if intent == nil || intent.ID == "" {
+		return commerr.ErrInvalidArgument
+	}
+
+	intent.Stage = IntentStageBegin
+
+	return impl.storage.Set(intent.ID, intent)
+}
+
+func (impl *intentJournalImpl) MarkStage(intent *Intent, stage IntentStage) error {
+	intent.Stage = stage
+
+	return impl.storage.Set(intent.ID, intent)
+}
+
+func (impl *intentJournalImpl) ApplyEffects(intent *Intent) (err error) {
+	if intent.Stage < IntentStageShowApplied {
+		if intent.ShowAdd != nil {
+			if !intent.replayed || !impl.showApplied(intent.ShowAdd) {
+				err = impl.showList.Add(intent.ShowAdd)
+			}
+		} else if intent.ShowRemove {
+			err = impl.showList.Remove(intent.targetID())
+		}
+
+		if err != nil {
+			return
+		}
+
+		if err = impl.MarkStage(intent, IntentStageShowApplied); err != nil {
+			return
+		}
+	}
+
+	if intent.Stage < IntentStageMetaApplied {
+		if intent.AlarmMeta != nil {
+			if err = impl.metaStorage.Set(intent.targetID(), intent.AlarmMeta); err != nil {
+				return
+			}
+		} else if intent.TaskMeta != nil {
+			if err = impl.metaStorage.Set(intent.targetID(), intent.TaskMeta); err != nil {
+				return
+			}
+		}
+
+		err = impl.MarkStage(intent, IntentStageMetaApplied)
+	}
+
+	return
+}
+
+// showApplied 重放时 ShowList 中已经是目标内容, 说明上次已经执行过, 避免重复通知
+func (impl *intentJournalImpl) showApplied(showInfo *ShowInfo) bool {
+	old, err := impl.showList.Get(showInfo.ID)
+	if err != nil || old == nil {
+		return false
+	}
+
+	return old.Value == showInfo.Value && old.SubTitle == showInfo.SubTitle &&
+		old.AlarmFlag == showInfo.AlarmFlag && old.AlarmAt.Equal(showInfo.AlarmAt) &&
+		old.EscalationCount == showInfo.EscalationCount
+}
+
+func (impl *intentJournalImpl) Finish(id string) error {
+	return impl.storage.Del(id)
+}
+
+func (impl *intentJournalImpl) Pending() (intents []*Intent, err error) {
+	ds, err := impl.storage.GetList(func(_ string) interface{} {
+		return &Intent{}
+	})
+	if err != nil {
+		return
+	}
+
+	for _, d := range ds {
+		intent, ok := d.(*Intent)
+		if !ok || intent.ID == "" {
+			continue
+		}
+
+		intent.replayed = true
+
+		intents = append(intents, intent)
+	}
+
+	return
+}
+
+type nopIntentJournal struct{}
+
+func (impl *nopIntentJournal) Begin(_ *Intent) error {
+	return nil
+}
+
+func (impl *nopIntentJournal) ApplyEffects(intent *Intent) error {
+	if intent.ShowAdd != nil || intent.ShowRemove || intent.AlarmMeta != nil || intent.TaskMeta != nil {
+		return commerr.ErrUnavailable
+	}
+
+	return nil
+}
+
+func (impl *nopIntentJournal) MarkStage(intent *Intent, stage IntentStage) error {
+	intent.Stage = stage
+
+	return nil
+}
+
+func (impl *nopIntentJournal) Finish(_ string) error {
+	return nil
+}
+
+func (impl *nopIntentJournal) Pending() ([]*Intent, error) {
+	return nil, nil
+}
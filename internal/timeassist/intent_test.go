@@ -0,0 +1,92 @@
+package timeassist
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgostarter/libeasygo/stg/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntentReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	metaStorage, err := kv.NewMemoryFileStorageEx(filepath.Join(dir, "task_meta"), false)
+	assert.Nil(t, err)
+
+	var notifyCount int
+
+	showList := NewShowList(filepath.Join(dir, "task_list"), func(_ *ShowInfo, visible bool) {
+		if visible {
+			notifyCount++
+		}
+	}, nil, nil)
+
+	journal := NewIntentJournal(filepath.Join(dir, "task_journal"), metaStorage, showList)
+
+	alarmAt := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	fnNewIntent := func(id string) *Intent {
+		intent := &Intent{
+			ID: id,
+			Fired: &D{
+				Data: &ShowItem{ID: id},
+				At:   alarmAt.Add(-time.Hour),
+			},
+			AlarmMeta: &Alarm{
+				ID:         id,
+				Text:       "t",
+				TimeLastAt: alarmAt.Unix(),
+			},
+			TimerAt: alarmAt,
+			TimerData: &ShowItem{
+				ID:     id,
+				EndUTC: alarmAt.Unix(),
+			},
+		}
+
+		intent.AddShow(&ShowInfo{
+			ID:      id,
+			Value:   "t",
+			AlarmAt: alarmAt,
+		})
+
+		return intent
+	}
+
+	// 崩溃在 ShowList 已经修改, 但是 stage 还没有落盘
+	intent1 := fnNewIntent("A1")
+	assert.Nil(t, journal.Begin(intent1))
+	assert.Nil(t, showList.Add(intent1.ShowAdd))
+	assert.Equal(t, 1, notifyCount)
+
+	// 崩溃在写入 intent 之后
+	assert.Nil(t, journal.Begin(fnNewIntent("A2")))
+
+	journal = NewIntentJournal(filepath.Join(dir, "task_journal"), metaStorage, showList)
+	timer := NewTaskTimer(filepath.Join(dir, "task_timer"), journal, nil, nil)
+
+	assert.Equal(t, 2, notifyCount)
+
+	intents, err := journal.Pending()
+	assert.Nil(t, err)
+	assert.Empty(t, intents)
+
+	for _, id := range []string{"A1", "A2"} {
+		alarm := &Alarm{}
+
+		ok, err := metaStorage.Get(id, alarm)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, alarmAt.Unix(), alarm.TimeLastAt)
+
+		showInfo, err := showList.Get(id)
+		assert.Nil(t, err)
+		assert.NotNil(t, showInfo)
+	}
+
+	items, err := timer.List()
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+}
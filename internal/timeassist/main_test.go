@@ -0,0 +1,24 @@
+package timeassist
+
+import (
+	"os"
+	"testing"
+
+	"github.com/s-min-sys/timeassistbe/internal/trace"
+)
+
+// TestMain trace 记录写到临时目录, 不留在源码目录中
+func TestMain(m *testing.M) {
+	root, err := os.MkdirTemp("", "timeassist-trace")
+	if err != nil {
+		panic(err)
+	}
+
+	trace.SetRoot(root)
+
+	code := m.Run()
+
+	_ = os.RemoveAll(root)
+
+	os.Exit(code)
+}
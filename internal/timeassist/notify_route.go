@@ -0,0 +1,55 @@
+package timeassist
+
+import (
+	"github.com/sgostarter/libeasygo/stg/kv"
+)
+
+// NotifyRoute Alarm/Task 的通知路由, 各字段为空时使用全局配置
+type NotifyRoute struct {
+	Backends  []string `yaml:"Backends,omitempty" json:"backends,omitempty"`   // 只发送到这些名字的后端
+	Receivers []string `yaml:"Receivers,omitempty" json:"receivers,omitempty"` // notifier-share 接收者类型: admin_users, admin_groups, users, groups
+	Group     string   `yaml:"Group,omitempty" json:"group,omitempty"`         // 接收群, 传给 webhook/command
+	BizCode   string   `yaml:"BizCode,omitempty" json:"biz_code,omitempty"`
+	Template  string   `yaml:"Template,omitempty" json:"template,omitempty"` // 全局模板名, 或 text/template 内容
+	Locale    string   `yaml:"Locale,omitempty" json:"locale,omitempty"`     // 通知文本的语言, 如 en-US; 后端配置了 Locale 时以后端为准
+}
+
+// GetNotifyRoute 按 ID 前缀从 meta 中取 Alarm/Task 的路由, 没有时返回 nil;
+// 重新通知达到 Escalation.SwitchAfter 次后使用 Escalation.SwitchRoute
+func GetNotifyRoute(storage kv.StorageTiny, show *ShowInfo) (route *NotifyRoute, err error) {
+	var escalation *Escalation
+
+	switch ParsePreOnID(show.ID) {
+	case AlarmIDPre:
+		var alarm Alarm
+
+		if _, err = storage.Get(show.ID, &alarm); err == nil {
+			route, escalation = alarm.Notify, alarm.Escalation
+		}
+	case TaskIDPre:
+		var task Task
+
+		if _, err = storage.Get(show.ID, &task); err == nil {
+			route, escalation = task.Notify, task.Escalation
+		}
+	}
+
+	if show.EscalationCount > 0 {
+		route = escalation.route(route, show.EscalationCount)
+	}
+
+	return
+}
+
+// IsUrgentNotify 只有 Alarm 可以设置为紧急
+func IsUrgentNotify(storage kv.StorageTiny, id string) bool {
+	if ParsePreOnID(id) != AlarmIDPre {
+		return false
+	}
+
+	var alarm Alarm
+
+	ok, err := storage.Get(id, &alarm)
+
+	return err == nil && ok && alarm.Urgent
+}
@@ -0,0 +1,62 @@
+package timeassist
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+const resumeIDSuffix = "#resume"
+
+// ResumeTimerID 暂停后自动恢复使用独立的定时
+func ResumeTimerID(id string) string {
+	return id + resumeIDSuffix
+}
+
+func ParseResumeTimerID(id string) (targetID string, ok bool) {
+	if !strings.HasSuffix(id, resumeIDSuffix) {
+		return
+	}
+
+	return strings.TrimSuffix(id, resumeIDSuffix), true
+}
+
+// checkResumeAt resumeAt 为零时不自动恢复, 否则必须晚于 timeNow
+func checkResumeAt(resumeAt, timeNow time.Time) (unix int64, err error) {
+	if resumeAt.IsZero() {
+		return
+	}
+
+	if !resumeAt.After(timeNow) {
+		err = commerr.ErrInvalidArgument
+
+		return
+	}
+
+	unix = resumeAt.Unix()
+
+	return
+}
+
+// resumeTimer 自动恢复的定时, resumeAt 为 0 时返回 nil
+func resumeTimer(id string, resumeAt int64) *D {
+	if resumeAt <= 0 {
+		return nil
+	}
+
+	return &D{
+		Data: &ShowItem{
+			ID:       ResumeTimerID(id),
+			StartUTC: resumeAt,
+			EndUTC:   resumeAt,
+		},
+		At: time.Unix(resumeAt, 0),
+	}
+}
+
+// pause 暂停时只保留 meta, 移除显示; 没有 TimerData 时 ApplyIntent 会移除周期定时
+func (intent *Intent) pause(resumeAt int64) {
+	intent.RemoveShow()
+	intent.AddTimer(resumeTimer(intent.targetID(), resumeAt))
+}
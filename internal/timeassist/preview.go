@@ -0,0 +1,164 @@
+package timeassist
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/6tail/lunar-go/calendar"
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+)
+
+const (
+	DefaultPreviewCount = 10
+	MaxPreviewCount     = 100
+)
+
+// PreviewTime 同一个时间的阳历和阴历写法, 按 Alarm/Task 的时区
+type PreviewTime struct {
+	At    time.Time `json:"at"`
+	Solar string    `json:"solar"` // 2026年03月02日08时30分00秒 周一
+	Lunar string    `json:"lunar"` // 丙午年正月十四 08时30分00秒
+}
+
+func NewPreviewTime(t time.Time) *PreviewTime {
+	return NewPreviewTimeLocale(t, locale.Default)
+}
+
+func NewPreviewTimeLocale(t time.Time, lc locale.Locale) *PreviewTime {
+	lunar := calendar.NewSolarFromYmd(t.Year(), int(t.Month()), t.Day()).GetLunar()
+
+	// 闰月为负数
+	lunarMonth := lunar.GetMonth()
+	if lunarMonth < 0 {
+		lunarMonth = -lunarMonth
+	}
+
+	return &PreviewTime{
+		At:    t,
+		Solar: t.Format(lc.T("preview.solar")) + " " + lc.T("preview.week."+strconv.Itoa(int(t.Weekday()))),
+		Lunar: lc.T("preview.lunar", lunar.GetYearInGanZhi(), lunar.GetMonthInChinese(), lunar.GetDayInChinese(),
+			lunar.GetYear(), lunarMonth, lunar.GetDay(), t.Format(lc.T("preview.time"))),
+	}
+}
+
+// AlarmOccurrence ShowAt 开始显示, FireAt 提醒并过期
+type AlarmOccurrence struct {
+	ShowAt *PreviewTime `json:"show_at"`
+	FireAt *PreviewTime `json:"fire_at"`
+}
+
+// TaskOccurrence 一个周期, StartAt 开始显示, EndAt 过期
+type TaskOccurrence struct {
+	StartAt *PreviewTime `json:"start_at"`
+	EndAt   *PreviewTime `json:"end_at"`
+}
+
+func fixPreviewCount(n int) int {
+	if n <= 0 {
+		return DefaultPreviewCount
+	}
+
+	if n > MaxPreviewCount {
+		return MaxPreviewCount
+	}
+
+	return n
+}
+
+const previewText = "preview"
+
+// PreviewAlarm 从 timeFrom 开始的 n 次提醒, 不读写存储; 没有 Text 时也可以预览
+func PreviewAlarm(alarm *Alarm, timeFrom time.Time, n int) (occurrences []*AlarmOccurrence, err error) {
+	return PreviewAlarmLocale(alarm, timeFrom, n, locale.Default, nil)
+}
+
+// PreviewAlarmLocale calendar 为空时使用内置的节假日数据
+func PreviewAlarmLocale(alarm *Alarm, timeFrom time.Time, n int, lc locale.Locale, calendar HolidayCalendar) (occurrences []*AlarmOccurrence, err error) {
+	if alarm.Text == "" {
+		a := *alarm
+		a.Text = previewText
+		alarm = &a
+	}
+
+	loc, err := TimeLocation(alarm.Location, alarm.TimeZone)
+	if err != nil {
+		return
+	}
+
+	n = fixPreviewCount(n)
+	timeNow := timeFrom
+
+	for len(occurrences) < n {
+		_, timeAt, rd, _, _, e := alarm.GenRecycleDataEx(timeNow, timeNow, calendar)
+		if e != nil {
+			err = e
+
+			return
+		}
+
+		if rd == nil || timeAt.Before(timeNow) {
+			break
+		}
+
+		occurrences = append(occurrences, &AlarmOccurrence{
+			ShowAt: NewPreviewTimeLocale(time.Unix(rd.StartUTC, 0).In(loc), lc),
+			FireAt: NewPreviewTimeLocale(timeAt.In(loc), lc),
+		})
+
+		timeNow = timeAt.Add(time.Second)
+	}
+
+	return
+}
+
+// PreviewTask 从 timeFrom 所在的周期开始的 n 个周期, 单次任务没有周期
+func PreviewTask(task *Task, timeFrom time.Time, n int) (occurrences []*TaskOccurrence, err error) {
+	return PreviewTaskLocale(task, timeFrom, n, locale.Default, nil)
+}
+
+// PreviewTaskLocale calendar 为空时使用内置的节假日数据
+func PreviewTaskLocale(task *Task, timeFrom time.Time, n int, lc locale.Locale, calendar HolidayCalendar) (occurrences []*TaskOccurrence, err error) {
+	if task.ID == "" || task.Text == "" {
+		t := *task
+		t.ID = FixTaskID(t.ID)
+
+		if t.Text == "" {
+			t.Text = previewText
+		}
+
+		task = &t
+	}
+
+	if err = task.ValidEx(timeFrom, calendar); err != nil {
+		return
+	}
+
+	if task.TType == TimeTypeOnce {
+		return
+	}
+
+	loc, err := TimeLocation(task.Location, task.TimeZone)
+	if err != nil {
+		return
+	}
+
+	n = fixPreviewCount(n)
+
+	rd, _ := task.GenRecycleDataEx(timeFrom, calendar)
+
+	for len(occurrences) < n {
+		occurrences = append(occurrences, &TaskOccurrence{
+			StartAt: NewPreviewTimeLocale(time.Unix(rd.StartUTC, 0).In(loc), lc),
+			EndAt:   NewPreviewTimeLocale(time.Unix(rd.EndUTC, 0).In(loc), lc),
+		})
+
+		next, _ := task.GenRecycleDataEx(time.Unix(rd.EndUTC, 0), calendar)
+		if next.EndUTC <= rd.EndUTC {
+			break
+		}
+
+		rd = next
+	}
+
+	return
+}
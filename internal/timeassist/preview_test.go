@@ -0,0 +1,84 @@
+package timeassist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewAlarm(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, tz8)
+
+	// 阴历每年十月廿五
+	occurrences, err := PreviewAlarm(&Alarm{AType: RecycleTimeTypeYear, Value: "L1025090000", TimeZone: 8}, from, 3)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 3)
+	assert.Equal(t, "2026年12月03日09时00分00秒 周四", occurrences[0].FireAt.Solar)
+	assert.Equal(t, "丙午年十月廿五 09时00分00秒", occurrences[0].FireAt.Lunar)
+	assert.Equal(t, "2026年11月26日09时00分00秒 周四", occurrences[0].ShowAt.Solar)
+	assert.Equal(t, "丁未年十月廿五 09时00分00秒", occurrences[1].FireAt.Lunar)
+	assert.True(t, occurrences[1].FireAt.At.Equal(time.Date(2027, 11, 22, 9, 0, 0, 0, tz8)))
+
+	// 每月最后一天
+	occurrences, err = PreviewAlarm(&Alarm{AType: RecycleTimeTypeMonth, Value: "-1090000", TimeZone: 8}, from, 3)
+	assert.Nil(t, err)
+
+	var fireAts []string
+
+	for _, occurrence := range occurrences {
+		fireAts = append(fireAts, occurrence.FireAt.At.Format("2006-01-02"))
+	}
+
+	assert.Equal(t, []string{"2026-03-31", "2026-04-30", "2026-05-31"}, fireAts)
+
+	// 单次提醒只有一次, 过去的没有
+	occurrences, err = PreviewAlarm(&Alarm{AType: TimeTypeOnce, Value: "20260310090000", TimeZone: 8}, from, 3)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 1)
+
+	occurrences, err = PreviewAlarm(&Alarm{AType: TimeTypeOnce, Value: "20260210090000", TimeZone: 8}, from, 3)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 0)
+
+	_, err = PreviewAlarm(&Alarm{AType: RecycleTimeTypeMonth, Value: "x", TimeZone: 8}, from, 3)
+	assert.NotNil(t, err)
+}
+
+func TestPreviewTask(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, tz8)
+
+	occurrences, err := PreviewTask(&Task{TType: RecycleTimeTypeDay, Value: 2, TimeZone: 8}, from, 0)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, DefaultPreviewCount)
+	assert.True(t, occurrences[0].StartAt.At.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, tz8)))
+	assert.True(t, occurrences[0].EndAt.At.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, tz8)))
+	assert.True(t, occurrences[1].StartAt.At.Equal(occurrences[0].EndAt.At))
+	assert.Equal(t, "2026年03月04日00时00分00秒 周三", occurrences[1].StartAt.Solar)
+
+	occurrences, err = PreviewTask(&Task{TType: TimeTypeOnce}, from, 3)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 0)
+
+	_, err = PreviewTask(&Task{TType: RecycleTimeTypeDay, TimeZone: 8}, from, 3)
+	assert.NotNil(t, err)
+}
+
+func TestPreviewLocale(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	from := time.Date(2026, 3, 2, 8, 0, 0, 0, tz8)
+
+	occurrences, err := PreviewAlarmLocale(&Alarm{AType: RecycleTimeTypeYear, Value: "L1025090000", TimeZone: 8}, from, 1, locale.EnUS, nil)
+	assert.Nil(t, err)
+	assert.Len(t, occurrences, 1)
+	assert.Equal(t, "2026-12-03 09:00:00 Thu", occurrences[0].FireAt.Solar)
+	assert.Equal(t, "lunar 2026-10-25 09:00:00", occurrences[0].FireAt.Lunar)
+
+	taskOccurrences, err := PreviewTaskLocale(&Task{TType: RecycleTimeTypeDay, Value: 2, TimeZone: 8}, from, 1, locale.EnUS, nil)
+	assert.Nil(t, err)
+	assert.Len(t, taskOccurrences, 1)
+	assert.Equal(t, "2026-03-04 00:00:00 Wed", taskOccurrences[0].EndAt.Solar)
+}
@@ -0,0 +1,686 @@
+package timeassist
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+/* RRule RFC 5545 重复规则, Alarm.Value 按行(或空白)分隔:
+DTSTART:20260106T090000
+RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;UNTIL=20270630T235959
+EXDATE:20261225T090000,20270101
+
+DTSTART/UNTIL/EXDATE 不带 Z 时按 Alarm 的时区解释, EXDATE 只有日期时排除当天
+支持 FREQ(YEARLY MONTHLY WEEKLY DAILY HOURLY MINUTELY) INTERVAL COUNT UNTIL BYDAY BYMONTHDAY BYMONTH BYSETPOS WKST
+*/
+
+// rruleSearchYears 查找下次时间的最大年数
+const rruleSearchYears = 10
+
+type rruleFreq int
+
+const (
+	rruleFreqYearly rruleFreq = iota
+	rruleFreqMonthly
+	rruleFreqWeekly
+	rruleFreqDaily
+	rruleFreqHourly
+	rruleFreqMinutely
+)
+
+var (
+	rruleFreqs = map[string]rruleFreq{
+		"YEARLY":   rruleFreqYearly,
+		"MONTHLY":  rruleFreqMonthly,
+		"WEEKLY":   rruleFreqWeekly,
+		"DAILY":    rruleFreqDaily,
+		"HOURLY":   rruleFreqHourly,
+		"MINUTELY": rruleFreqMinutely,
+	}
+
+	rruleWeekdays = map[string]time.Weekday{
+		"SU": time.Sunday,
+		"MO": time.Monday,
+		"TU": time.Tuesday,
+		"WE": time.Wednesday,
+		"TH": time.Thursday,
+		"FR": time.Friday,
+		"SA": time.Saturday,
+	}
+)
+
+// rruleTime 墙上时间, 具体时区在展开时确定
+type rruleTime struct {
+	year, month, day     int
+	hour, minute, second int
+
+	utc      bool
+	dateOnly bool
+}
+
+func parseRRuleTime(s string) (rt rruleTime, err error) {
+	if strings.HasSuffix(s, "Z") {
+		rt.utc = true
+		s = strings.TrimSuffix(s, "Z")
+	}
+
+	layout := "20060102T150405"
+	if len(s) == 8 {
+		layout = "20060102"
+		rt.dateOnly = true
+	}
+
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		err = commerr.ErrBadFormat
+
+		return
+	}
+
+	rt.year, rt.month, rt.day = t.Year(), int(t.Month()), t.Day()
+	rt.hour, rt.minute, rt.second = t.Hour(), t.Minute(), t.Second()
+
+	return
+}
+
+func (rt rruleTime) in(loc *time.Location) time.Time {
+	if rt.utc {
+		return time.Date(rt.year, time.Month(rt.month), rt.day, rt.hour, rt.minute, rt.second, 0, time.UTC).In(loc)
+	}
+
+	return localDate(rt.year, time.Month(rt.month), rt.day, rt.hour, rt.minute, rt.second, 0, loc)
+}
+
+type rruleWeekday struct {
+	n       int // 0: 每个, >0: 第 n 个, <0: 倒数第 n 个
+	weekday time.Weekday
+}
+
+type RRule struct {
+	Expr string
+
+	rule string
+
+	freq       rruleFreq
+	interval   int
+	count      int
+	until      *rruleTime
+	byDay      []rruleWeekday
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+	wkst       time.Weekday
+
+	dtStart rruleTime
+	exDates []rruleTime
+}
+
+func ParseRRule(value string) (r *RRule, err error) {
+	r = &RRule{
+		Expr:     strings.TrimSpace(value),
+		interval: 1,
+		wkst:     time.Monday,
+	}
+
+	var hasStart, hasRule bool
+
+	for _, line := range strings.Fields(value) {
+		name, v := "RRULE", line
+
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			name, v = strings.ToUpper(line[:idx]), line[idx+1:]
+
+			// 忽略 TZID/VALUE 等参数
+			if pIdx := strings.Index(name, ";"); pIdx >= 0 {
+				name = name[:pIdx]
+			}
+		}
+
+		switch name {
+		case "DTSTART":
+			if r.dtStart, err = parseRRuleTime(v); err != nil {
+				return nil, err
+			}
+
+			hasStart = true
+		case "RRULE":
+			if err = r.parseRule(v); err != nil {
+				return nil, err
+			}
+
+			hasRule = true
+		case "EXDATE":
+			for _, s := range strings.Split(v, ",") {
+				var rt rruleTime
+
+				if rt, err = parseRRuleTime(s); err != nil {
+					return nil, err
+				}
+
+				r.exDates = append(r.exDates, rt)
+			}
+		default:
+			return nil, commerr.ErrBadFormat
+		}
+	}
+
+	if !hasStart || !hasRule {
+		return nil, commerr.ErrBadFormat
+	}
+
+	// 一次都不会触发的规则, 如 BYMONTH=2;BYMONTHDAY=30
+	if r.Next(r.dtStart.in(time.UTC)).IsZero() {
+		return nil, commerr.ErrBadFormat
+	}
+
+	return
+}
+
+// nolint: gocyclo
+func (r *RRule) parseRule(rule string) (err error) {
+	r.rule = rule
+
+	var hasFreq bool
+
+	fnInts := func(s string, min, max int) (vs []int, err error) {
+		for _, item := range strings.Split(s, ",") {
+			v, e := strconv.Atoi(item)
+			if e != nil || v == 0 || v < min || v > max {
+				return nil, commerr.ErrBadFormat
+			}
+
+			vs = append(vs, v)
+		}
+
+		return
+	}
+
+	for _, part := range strings.Split(rule, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return commerr.ErrBadFormat
+		}
+
+		key, v := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		switch key {
+		case "FREQ":
+			if r.freq, hasFreq = rruleFreqs[v]; !hasFreq {
+				return commerr.ErrBadFormat
+			}
+		case "INTERVAL":
+			if r.interval, err = strconv.Atoi(v); err != nil || r.interval <= 0 {
+				return commerr.ErrBadFormat
+			}
+		case "COUNT":
+			if r.count, err = strconv.Atoi(v); err != nil || r.count <= 0 {
+				return commerr.ErrBadFormat
+			}
+		case "UNTIL":
+			var until rruleTime
+
+			if until, err = parseRRuleTime(v); err != nil {
+				return
+			}
+
+			r.until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(v, ",") {
+				if len(item) < 2 {
+					return commerr.ErrBadFormat
+				}
+
+				weekday, ok := rruleWeekdays[item[len(item)-2:]]
+				if !ok {
+					return commerr.ErrBadFormat
+				}
+
+				wd := rruleWeekday{
+					weekday: weekday,
+				}
+
+				if nS := item[:len(item)-2]; nS != "" {
+					if wd.n, err = strconv.Atoi(nS); err != nil || wd.n == 0 || wd.n < -53 || wd.n > 53 {
+						return commerr.ErrBadFormat
+					}
+				}
+
+				r.byDay = append(r.byDay, wd)
+			}
+		case "BYMONTHDAY":
+			if r.byMonthDay, err = fnInts(v, -31, 31); err != nil {
+				return
+			}
+		case "BYMONTH":
+			if r.byMonth, err = fnInts(v, 1, 12); err != nil {
+				return
+			}
+		case "BYSETPOS":
+			if r.bySetPos, err = fnInts(v, -366, 366); err != nil {
+				return
+			}
+		case "WKST":
+			var ok bool
+
+			if r.wkst, ok = rruleWeekdays[v]; !ok {
+				return commerr.ErrBadFormat
+			}
+		default:
+			return commerr.ErrUnimplemented
+		}
+	}
+
+	if !hasFreq || (r.count > 0 && r.until != nil) {
+		return commerr.ErrBadFormat
+	}
+
+	// RFC 5545 不允许 WEEKLY 使用 BYMONTHDAY
+	if r.freq == rruleFreqWeekly && len(r.byMonthDay) > 0 {
+		return commerr.ErrBadFormat
+	}
+
+	// 带序号的 BYDAY 只在 MONTHLY/YEARLY 中有意义
+	if r.freq != rruleFreqMonthly && r.freq != rruleFreqYearly {
+		for _, wd := range r.byDay {
+			if wd.n != 0 {
+				return commerr.ErrBadFormat
+			}
+		}
+	}
+
+	return
+}
+
+func (r *RRule) String() string {
+	return r.rule
+}
+
+// Next 返回不早于 t 的第一次触发时间(按 t 的时区), 已结束时返回零值
+func (r *RRule) Next(t time.Time) (next time.Time) {
+	r.iterate(t.Location(), t, func(at time.Time) bool {
+		if at.Before(t) {
+			return true
+		}
+
+		next = at
+
+		return false
+	})
+
+	return
+}
+
+// Prev 返回早于 t 的最后一次触发时间(按 t 的时区), 没有时返回零值
+func (r *RRule) Prev(t time.Time) (prev time.Time) {
+	r.iterate(t.Location(), time.Time{}, func(at time.Time) bool {
+		if !at.Before(t) {
+			return false
+		}
+
+		prev = at
+
+		return true
+	})
+
+	return
+}
+
+func rruleNaive(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// iterate 按时间顺序展开, from 非零时跳过 from 之前的周期(COUNT 需要从头计数, 不跳过)
+func (r *RRule) iterate(loc *time.Location, from time.Time, fn func(at time.Time) bool) {
+	start := rruleNaive(r.dtStart.in(loc))
+
+	var until time.Time
+
+	if r.until != nil {
+		until = r.until.in(loc)
+
+		if r.until.dateOnly {
+			until = DayEnd(until)
+		}
+	}
+
+	k := 0
+	if r.count == 0 && !from.IsZero() {
+		k = r.skipPeriods(start, rruleNaive(from.In(loc)))
+	}
+
+	yearLimit := start.Year() + rruleSearchYears
+	if !from.IsZero() && from.Year() > start.Year() {
+		yearLimit = from.Year() + rruleSearchYears
+	}
+
+	var emitted int
+
+	for ; ; k++ {
+		periodStart, candidates := r.period(start, k)
+		if periodStart.Year() > yearLimit {
+			return
+		}
+
+		for _, c := range candidates {
+			if c.Before(start) {
+				continue
+			}
+
+			at := localDate(c.Year(), c.Month(), c.Day(), c.Hour(), c.Minute(), c.Second(), 0, loc)
+
+			if !until.IsZero() && at.After(until) {
+				return
+			}
+
+			emitted++
+
+			if r.count > 0 && emitted > r.count {
+				return
+			}
+
+			if r.excluded(at, loc) {
+				continue
+			}
+
+			if !fn(at) {
+				return
+			}
+		}
+	}
+}
+
+func (r *RRule) excluded(at time.Time, loc *time.Location) bool {
+	for _, ex := range r.exDates {
+		if ex.dateOnly {
+			if at.Year() == ex.year && int(at.Month()) == ex.month && at.Day() == ex.day {
+				return true
+			}
+
+			continue
+		}
+
+		if ex.in(loc).Equal(at) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// skipPeriods 估算 from 之前可以跳过的周期数
+func (r *RRule) skipPeriods(start, from time.Time) (k int) {
+	if !from.After(start) {
+		return
+	}
+
+	d := from.Sub(start)
+
+	switch r.freq {
+	case rruleFreqMinutely:
+		k = int(d / time.Minute)
+	case rruleFreqHourly:
+		k = int(d / time.Hour)
+	case rruleFreqDaily:
+		k = int(d / (24 * time.Hour))
+	case rruleFreqWeekly:
+		k = int(d / (7 * 24 * time.Hour))
+	case rruleFreqMonthly:
+		k = (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+	case rruleFreqYearly:
+		k = from.Year() - start.Year()
+	}
+
+	k = k/r.interval - 1
+	if k < 0 {
+		k = 0
+	}
+
+	return
+}
+
+// period 第 k 个周期的开始时间和其中的候选时间(已排序, 已应用 BYSETPOS)
+func (r *RRule) period(start time.Time, k int) (periodStart time.Time, candidates []time.Time) {
+	n := k * r.interval
+
+	fnAt := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+	}
+
+	switch r.freq {
+	case rruleFreqMinutely, rruleFreqHourly, rruleFreqDaily:
+		unit := 24 * time.Hour
+		if r.freq == rruleFreqMinutely {
+			unit = time.Minute
+		} else if r.freq == rruleFreqHourly {
+			unit = time.Hour
+		}
+
+		periodStart = start.Add(time.Duration(n) * unit)
+
+		if r.matchMonth(periodStart) && r.matchMonthDay(periodStart) && r.matchWeekday(periodStart) {
+			candidates = append(candidates, periodStart)
+		}
+	case rruleFreqWeekly:
+		offset := (int(start.Weekday()) - int(r.wkst) + 7) % 7
+		periodStart = fnAt(start.Year(), start.Month(), start.Day()-offset+n*7)
+
+		weekdays := []time.Weekday{start.Weekday()}
+		if len(r.byDay) > 0 {
+			weekdays = weekdays[:0]
+
+			for _, wd := range r.byDay {
+				weekdays = append(weekdays, wd.weekday)
+			}
+		}
+
+		for _, weekday := range weekdays {
+			c := periodStart.AddDate(0, 0, (int(weekday)-int(r.wkst)+7)%7)
+			if r.matchMonth(c) {
+				candidates = append(candidates, c)
+			}
+		}
+	case rruleFreqMonthly:
+		periodStart = fnAt(start.Year(), start.Month()+time.Month(n), 1)
+
+		if r.matchMonth(periodStart) {
+			for _, day := range r.monthDays(periodStart.Year(), periodStart.Month(), start.Day()) {
+				candidates = append(candidates, fnAt(periodStart.Year(), periodStart.Month(), day))
+			}
+		}
+	case rruleFreqYearly:
+		periodStart = fnAt(start.Year()+n, time.January, 1)
+		candidates = r.yearDays(periodStart.Year(), start, fnAt)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+
+	candidates = r.applySetPos(candidates)
+
+	return
+}
+
+func (r *RRule) yearDays(year int, start time.Time, fnAt func(int, time.Month, int) time.Time) (candidates []time.Time) {
+	switch {
+	case len(r.byMonth) > 0:
+		for _, month := range r.byMonth {
+			for _, day := range r.monthDays(year, time.Month(month), start.Day()) {
+				candidates = append(candidates, fnAt(year, time.Month(month), day))
+			}
+		}
+	case len(r.byMonthDay) > 0:
+		for month := time.January; month <= time.December; month++ {
+			for _, day := range r.monthDays(year, month, start.Day()) {
+				candidates = append(candidates, fnAt(year, month, day))
+			}
+		}
+	case len(r.byDay) > 0:
+		// 序号相对于整年
+		days := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+
+		for _, wd := range r.byDay {
+			var matched []time.Time
+
+			for idx := 0; idx < days; idx++ {
+				c := fnAt(year, time.January, 1+idx)
+				if c.Weekday() == wd.weekday {
+					matched = append(matched, c)
+				}
+			}
+
+			candidates = append(candidates, pickNth(matched, wd.n)...)
+		}
+	default:
+		if start.Day() <= GetDaysOfMonth(year, int(start.Month())) {
+			candidates = append(candidates, fnAt(year, start.Month(), start.Day()))
+		}
+	}
+
+	return
+}
+
+// monthDays 某月中符合 BYMONTHDAY/BYDAY 的日期, 都没有设置时取 defaultDay
+func (r *RRule) monthDays(year int, month time.Month, defaultDay int) (days []int) {
+	daysOfMonth := GetDaysOfMonth(year, int(month))
+
+	if len(r.byMonthDay) == 0 && len(r.byDay) == 0 {
+		if defaultDay <= daysOfMonth {
+			days = append(days, defaultDay)
+		}
+
+		return
+	}
+
+	var byMonthDay, byDay map[int]bool
+
+	if len(r.byMonthDay) > 0 {
+		byMonthDay = make(map[int]bool)
+
+		for _, day := range r.byMonthDay {
+			if day < 0 {
+				day = daysOfMonth + 1 + day
+			}
+
+			if day >= 1 && day <= daysOfMonth {
+				byMonthDay[day] = true
+			}
+		}
+	}
+
+	if len(r.byDay) > 0 {
+		byDay = make(map[int]bool)
+
+		for _, wd := range r.byDay {
+			var matched []time.Time
+
+			for day := 1; day <= daysOfMonth; day++ {
+				c := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+				if c.Weekday() == wd.weekday {
+					matched = append(matched, c)
+				}
+			}
+
+			for _, c := range pickNth(matched, wd.n) {
+				byDay[c.Day()] = true
+			}
+		}
+	}
+
+	for day := 1; day <= daysOfMonth; day++ {
+		if byMonthDay != nil && !byMonthDay[day] {
+			continue
+		}
+
+		if byDay != nil && !byDay[day] {
+			continue
+		}
+
+		days = append(days, day)
+	}
+
+	return
+}
+
+func pickNth(ts []time.Time, n int) []time.Time {
+	switch {
+	case n == 0:
+		return ts
+	case n > 0 && n <= len(ts):
+		return ts[n-1 : n]
+	case n < 0 && -n <= len(ts):
+		return ts[len(ts)+n : len(ts)+n+1]
+	}
+
+	return nil
+}
+
+func (r *RRule) applySetPos(candidates []time.Time) []time.Time {
+	if len(r.bySetPos) == 0 {
+		return candidates
+	}
+
+	var picked []time.Time
+
+	for _, pos := range r.bySetPos {
+		picked = append(picked, pickNth(candidates, pos)...)
+	}
+
+	sort.Slice(picked, func(i, j int) bool {
+		return picked[i].Before(picked[j])
+	})
+
+	return picked
+}
+
+func (r *RRule) matchMonth(t time.Time) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+
+	for _, month := range r.byMonth {
+		if int(t.Month()) == month {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *RRule) matchMonthDay(t time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+
+	daysOfMonth := GetDaysOfMonth(t.Year(), int(t.Month()))
+
+	for _, day := range r.byMonthDay {
+		if day < 0 {
+			day = daysOfMonth + 1 + day
+		}
+
+		if t.Day() == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *RRule) matchWeekday(t time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+
+	for _, wd := range r.byDay {
+		if t.Weekday() == wd.weekday {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,181 @@
+package timeassist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func utRRuleAll(t *testing.T, value string, from time.Time, n int) (ats []time.Time) {
+	r, err := ParseRRule(value)
+	assert.Nil(t, err)
+
+	at := from
+	for idx := 0; idx < n; idx++ {
+		at = r.Next(at)
+		if at.IsZero() {
+			break
+		}
+
+		ats = append(ats, at)
+		at = at.Add(time.Second)
+	}
+
+	return
+}
+
+func TestParseRRule(t *testing.T) {
+	for _, value := range []string{
+		"DTSTART:20260106T090000\nRRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;UNTIL=20270630T235959",
+		"DTSTART:20260106T090000 RRULE:FREQ=MONTHLY;BYDAY=-1FR",
+		"DTSTART;TZID=Asia/Shanghai:20260106T090000 FREQ=DAILY;COUNT=10 EXDATE:20260108,20260109T090000",
+	} {
+		_, err := ParseRRule(value)
+		assert.Nil(t, err, value)
+	}
+
+	for _, value := range []string{
+		"",
+		"RRULE:FREQ=DAILY",
+		"DTSTART:20260106T090000",
+		"DTSTART:20260106T090000 RRULE:INTERVAL=2",
+		"DTSTART:20260106T090000 RRULE:FREQ=DAILY;COUNT=2;UNTIL=20270101",
+		"DTSTART:20260106T090000 RRULE:FREQ=WEEKLY;BYDAY=2MO",
+		"DTSTART:20260106T090000 RRULE:FREQ=WEEKLY;BYMONTHDAY=1",
+		"DTSTART:20260106T090000 RRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		"DTSTART:20260106T090000 RRULE:FREQ=DAILY;COUNT=1 EXDATE:20260106",
+		"DTSTART:20260106T090000 RRULE:FREQ=DAILY;BYHOUR=9",
+		"DTSTART:2026-01-06 RRULE:FREQ=DAILY",
+	} {
+		_, err := ParseRRule(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	tz := time.FixedZone("UT", 8*3600)
+
+	fnDate := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, tz)
+	}
+
+	// 2026-01-06 周二
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 6, 9), fnDate(2026, 1, 8, 9),
+		fnDate(2026, 1, 20, 9), fnDate(2026, 1, 22, 9),
+	}, utRRuleAll(t, "DTSTART:20260106T090000 RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", fnDate(2026, 1, 1, 0), 4))
+
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 30, 18), fnDate(2026, 2, 27, 18), fnDate(2026, 3, 27, 18),
+	}, utRRuleAll(t, "DTSTART:20260101T180000 RRULE:FREQ=MONTHLY;BYDAY=-1FR", fnDate(2026, 1, 1, 0), 3))
+
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 5, 11, 8), fnDate(2027, 5, 10, 8),
+	}, utRRuleAll(t, "DTSTART:20260101T080000 RRULE:FREQ=YEARLY;BYMONTH=5;BYDAY=2MO", fnDate(2026, 1, 1, 0), 2))
+
+	// 每月最后一个工作日
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 30, 17), fnDate(2026, 2, 27, 17), fnDate(2026, 5, 29, 17),
+	}, utRRuleAll(t, "DTSTART:20260101T170000 RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;BYMONTH=1,2,5", fnDate(2026, 1, 1, 0), 3))
+
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 31, 9), fnDate(2026, 3, 31, 9), fnDate(2026, 5, 31, 9),
+	}, utRRuleAll(t, "DTSTART:20260131T090000 RRULE:FREQ=MONTHLY", fnDate(2026, 1, 1, 0), 3))
+
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 31, 9), fnDate(2026, 2, 28, 9),
+	}, utRRuleAll(t, "DTSTART:20260131T090000 RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", fnDate(2026, 1, 1, 0), 2))
+
+	// COUNT 包含被 EXDATE 排除的
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 6, 9), fnDate(2026, 1, 8, 9),
+	}, utRRuleAll(t, "DTSTART:20260106T090000 RRULE:FREQ=DAILY;COUNT=3 EXDATE:20260107", fnDate(2026, 1, 1, 0), 10))
+
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 12, 24, 9), fnDate(2026, 12, 26, 9),
+	}, utRRuleAll(t, "DTSTART:20261224T090000 RRULE:FREQ=DAILY;UNTIL=20261226 EXDATE:20261225T090000", fnDate(2026, 1, 1, 0), 10))
+
+	// UNTIL 为 UTC
+	assert.Equal(t, []time.Time{
+		fnDate(2026, 1, 6, 9), fnDate(2026, 1, 7, 9),
+	}, utRRuleAll(t, "DTSTART:20260106T090000 RRULE:FREQ=DAILY;UNTIL=20260107T010000Z", fnDate(2026, 1, 1, 0), 10))
+
+	// 从很久以后开始找
+	assert.Equal(t, []time.Time{
+		fnDate(2030, 3, 12, 9),
+	}, utRRuleAll(t, "DTSTART:20260106T090000 RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU", fnDate(2030, 3, 1, 0), 1))
+
+	r, err := ParseRRule("DTSTART:20260106T090000 RRULE:FREQ=DAILY;COUNT=3")
+	assert.Nil(t, err)
+	assert.True(t, r.Next(fnDate(2026, 1, 9, 0)).IsZero())
+	assert.Equal(t, fnDate(2026, 1, 8, 9), r.Prev(fnDate(2026, 1, 9, 0)))
+}
+
+func TestAlarmRRuleFinished(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+
+	var events []utShowEvent
+
+	var env *utEnv
+
+	env = utNewEnv(t, time.Date(2026, 1, 1, 0, 0, 0, 0, tz8), func(task *ShowInfo, visible bool) {
+		if !visible {
+			return
+		}
+
+		events = append(events, utShowEvent{
+			at:        env.clock.Now(),
+			alarmFlag: task.AlarmFlag,
+		})
+	})
+
+	alarm := &Alarm{
+		AType:    RecycleTimeTypeRRule,
+		Text:     "meeting",
+		Value:    "DTSTART:20260106T090000\nRRULE:FREQ=WEEKLY;BYDAY=TU,TH;COUNT=4\nEXDATE:20260113T090000",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.alarmManager.Add(alarm))
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2027, 1, 1, 0, 0, 0, 0, tz8))
+
+	// 01-06 01-08 01-15, 01-13 被排除
+	var shows []time.Time
+
+	for _, event := range events {
+		if !event.alarmFlag {
+			shows = append(shows, event.at)
+		}
+	}
+
+	assert.Equal(t, 3, len(shows))
+
+	_, ok := env.timerAt(t, alarm.ID)
+	assert.False(t, ok)
+
+	alarm, err := env.alarmManager.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.True(t, alarm.Finished)
+
+	// 修改后重新开始
+	alarm.Value = "DTSTART:20260106T090000\nRRULE:FREQ=WEEKLY;BYDAY=TU,TH;UNTIL=20270630"
+	assert.Nil(t, env.alarmManager.Update(alarm))
+
+	alarm, err = env.alarmManager.Get(alarm.ID)
+	assert.Nil(t, err)
+	assert.False(t, alarm.Finished)
+
+	_, ok = env.timerAt(t, alarm.ID)
+	assert.True(t, ok)
+
+	// 一次都不会触发的规则添加时就拒绝
+	assert.ErrorIs(t, env.alarmManager.Add(&Alarm{
+		AType:    RecycleTimeTypeRRule,
+		Text:     "never",
+		Value:    "DTSTART:20260106T090000\nRRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+		TimeZone: 8,
+	}), commerr.ErrBadFormat)
+}
@@ -1,8 +1,10 @@
 package timeassist
 
 import (
+	"sync"
 	"time"
 
+	"github.com/s-min-sys/timeassistbe/internal/locale"
 	"github.com/s-min-sys/timeassistbe/internal/utils"
 	uuid "github.com/satori/go.uuid"
 	"github.com/sgostarter/i/commerr"
@@ -27,6 +29,8 @@ type ShowInfo struct {
 		alarm: future/outdate
 	*/
 	SubTitle string `json:"sub_title"`
+	// SubTitles 各语言的 SubTitle, 返回给前端时按请求的语言选择 @see Localize
+	SubTitles map[locale.Locale]string `json:"sub_titles,omitempty"`
 
 	//
 	// alarm
@@ -35,6 +39,13 @@ type ShowInfo struct {
 	AlarmFlag bool      `json:"alarm_flag,omitempty"`
 	AlarmAt   time.Time `json:"alarm_at,omitempty"`
 
+	//
+	// recycle task 当前周期
+	//
+
+	StartUTC int64 `json:"start_utc,omitempty"`
+	EndUTC   int64 `json:"end_utc,omitempty"`
+
 	//
 	//
 	//
@@ -44,53 +55,204 @@ type ShowInfo struct {
 	//
 	//
 	NotifyID string `json:"notify_id,omitempty"`
+	// EscalationCount 过期后已经重新通知的次数
+	EscalationCount int `json:"escalation_count,omitempty"`
 
 	//
 	LeftTimeS string `json:"left_time_s"`
 }
 
-func (showInfo *ShowInfo) AutoFill() {
+func (showInfo *ShowInfo) AutoFill(timeNow time.Time) {
 	switch ParsePreOnID(showInfo.ID) {
 	case TaskIDPre:
 		showInfo.VOTaskType = VOTaskTypeTask
 		showInfo.LeftTimeS = ""
 	case AlarmIDPre:
 		showInfo.VOTaskType = VOTaskTypeAlarm
-		showInfo.LeftTimeS = utils.LeftTimeString(showInfo.AlarmAt)
+		showInfo.LeftTimeS = utils.LeftTimeStringEx(showInfo.AlarmAt, timeNow)
 	default:
 		showInfo.VOTaskType = VOTaskTypeUnknown
 	}
 }
 
+// Localize 返回按 lc 选择 SubTitle 并重新计算 LeftTimeS 的副本
+func (showInfo *ShowInfo) Localize(lc locale.Locale, timeNow time.Time) *ShowInfo {
+	localized := *showInfo
+	localized.SubTitles = nil
+
+	if subTitle, ok := showInfo.SubTitles[lc]; ok {
+		localized.SubTitle = subTitle
+	}
+
+	if localized.VOTaskType == VOTaskTypeAlarm {
+		localized.LeftTimeS = utils.LeftTimeStringLocale(localized.AlarmAt, timeNow, lc)
+	}
+
+	return &localized
+}
+
+// newSubTitles 按支持的语言生成 SubTitles, 默认语言的同时作为 SubTitle
+func newSubTitles(fn func(lc locale.Locale) string) (subTitle string, subTitles map[locale.Locale]string) {
+	subTitles = make(map[locale.Locale]string, len(locale.Supported))
+
+	for _, lc := range locale.Supported {
+		subTitles[lc] = fn(lc)
+	}
+
+	subTitle = subTitles[locale.Default]
+
+	return
+}
+
 type ShowInfoListChangeObserver func(task *ShowInfo, visible bool)
 
+type ShowListEventType string
+
+const (
+	ShowListEventSnapshot   ShowListEventType = "snapshot"
+	ShowListEventAdd        ShowListEventType = "add"
+	ShowListEventRemove     ShowListEventType = "remove"
+	ShowListEventFlagChange ShowListEventType = "flag_change" // 已存在的 item AlarmFlag 变化
+	ShowListEventUpdate     ShowListEventType = "update"      // 已存在的 item 重新加入
+)
+
+type ShowListEvent struct {
+	Type  ShowListEventType `json:"type"`
+	Show  *ShowInfo         `json:"show,omitempty"`
+	Old   *ShowInfo         `json:"old,omitempty"` // update/flag_change 时为更新前的
+	Shows []*ShowInfo       `json:"shows,omitempty"`
+}
+
+type ShowListEventObserver func(event *ShowListEvent)
+
 type ShowList interface {
-	SetOb(ob ShowInfoListChangeObserver) error
+	// AddOb 兼容旧的观察者, 更新时先通知旧的不可见, 再通知新的可见
+	AddOb(ob ShowInfoListChangeObserver) (obID uint64)
+	AddEventOb(ob ShowListEventObserver) (obID uint64)
+	RemoveOb(obID uint64)
 	Add(taskInfo *ShowInfo) error // 如果存在，也不要返回错误
 	Get(taskID string) (taskInfo *ShowInfo, err error)
 	Remove(taskID string) error // 如果不存在，也不要返回错误
 	GetList() ([]*ShowInfo, error)
 }
 
-func NewShowList(fileName string, ob ShowInfoListChangeObserver) ShowList {
+func NewShowList(fileName string, ob ShowInfoListChangeObserver, eventBus EventBus, clock Clock) ShowList {
 	storage, err := kv.NewMemoryFileStorageEx(fileName, false)
 	if err != nil {
 		return nil
 	}
 
-	return &showListImpl{
-		storage:        storage,
-		changeObserver: ob,
+	impl := &showListImpl{
+		storage:  storage,
+		eventBus: fixEventBus(eventBus),
+		clock:    fixClock(clock),
 	}
+
+	if ob != nil {
+		impl.AddOb(ob)
+	}
+
+	return impl
+}
+
+type showListOb struct {
+	id uint64
+	ob ShowListEventObserver
 }
 
 type showListImpl struct {
-	storage        kv.StorageTiny
-	changeObserver ShowInfoListChangeObserver
+	storage  kv.StorageTiny
+	eventBus EventBus
+	clock    Clock
+
+	obLock sync.RWMutex
+	obSeq  uint64
+	obs    []showListOb
+}
+
+func (impl *showListImpl) AddOb(ob ShowInfoListChangeObserver) (obID uint64) {
+	if ob == nil {
+		return
+	}
+
+	return impl.AddEventOb(func(event *ShowListEvent) {
+		switch event.Type {
+		case ShowListEventAdd:
+			ob(event.Show, true)
+		case ShowListEventRemove:
+			ob(event.Show, false)
+		case ShowListEventFlagChange, ShowListEventUpdate:
+			ob(&ShowInfo{
+				ID:    event.Old.ID,
+				Value: event.Old.Value,
+			}, false)
+
+			ob(event.Show, true)
+		}
+	})
 }
 
-func (impl *showListImpl) SetOb(_ ShowInfoListChangeObserver) error {
-	return commerr.ErrUnavailable
+func (impl *showListImpl) AddEventOb(ob ShowListEventObserver) (obID uint64) {
+	if ob == nil {
+		return
+	}
+
+	impl.obLock.Lock()
+	defer impl.obLock.Unlock()
+
+	impl.obSeq++
+	obID = impl.obSeq
+
+	impl.obs = append(impl.obs, showListOb{
+		id: obID,
+		ob: ob,
+	})
+
+	return
+}
+
+func (impl *showListImpl) RemoveOb(obID uint64) {
+	impl.obLock.Lock()
+	defer impl.obLock.Unlock()
+
+	for idx, ob := range impl.obs {
+		if ob.id == obID {
+			impl.obs = append(impl.obs[:idx:idx], impl.obs[idx+1:]...)
+
+			break
+		}
+	}
+}
+
+// notify 每个观察者拿到各自的副本, 避免互相修改
+func (impl *showListImpl) notify(eventType ShowListEventType, showInfo, oldShowInfo *ShowInfo) {
+	tmpShowInfo := *showInfo
+
+	if eventType == ShowListEventRemove {
+		impl.eventBus.Publish(EventShowRemoved, showInfo.ID, &tmpShowInfo)
+	} else {
+		impl.eventBus.Publish(EventShowAdded, showInfo.ID, &tmpShowInfo)
+	}
+
+	impl.obLock.RLock()
+	obs := impl.obs
+	impl.obLock.RUnlock()
+
+	for _, ob := range obs {
+		event := &ShowListEvent{
+			Type: eventType,
+		}
+
+		tmpShowInfo := *showInfo
+		event.Show = &tmpShowInfo
+
+		if oldShowInfo != nil {
+			tmpOldShowInfo := *oldShowInfo
+			event.Old = &tmpOldShowInfo
+		}
+
+		ob.ob(event)
+	}
 }
 
 func (impl *showListImpl) Add(taskInfo *ShowInfo) (err error) {
@@ -100,7 +262,7 @@ func (impl *showListImpl) Add(taskInfo *ShowInfo) (err error) {
 		return
 	}
 
-	taskInfo.AutoFill()
+	taskInfo.AutoFill(impl.clock.Now())
 
 	var taskInfoOld ShowInfo
 
@@ -111,18 +273,32 @@ func (impl *showListImpl) Add(taskInfo *ShowInfo) (err error) {
 
 	var forceUpdateNotifyID bool
 
+	var oldShowInfo *ShowInfo
+
+	eventType := ShowListEventAdd
+
 	if ok {
-		if taskInfo.VOTaskType == VOTaskTypeAlarm && taskInfo.AlarmFlag && !taskInfoOld.AlarmFlag {
+		// 过期, 再次通知, 新的周期或提醒时间都需要重新通知, 否则 outbox 会按 NotifyID 去重
+		if taskInfo.AlarmFlag && !taskInfoOld.AlarmFlag {
+			forceUpdateNotifyID = true
+		}
+
+		if taskInfo.EscalationCount > taskInfoOld.EscalationCount {
+			forceUpdateNotifyID = true
+		}
+
+		if taskInfo.StartUTC != taskInfoOld.StartUTC || taskInfo.EndUTC != taskInfoOld.EndUTC ||
+			!taskInfo.AlarmAt.Equal(taskInfoOld.AlarmAt) {
 			forceUpdateNotifyID = true
 		}
 
 		taskInfo.NotifyID = taskInfoOld.NotifyID
 
-		if impl.changeObserver != nil {
-			impl.changeObserver(&ShowInfo{
-				ID:    taskInfoOld.ID,
-				Value: taskInfoOld.Value,
-			}, false)
+		oldShowInfo = &taskInfoOld
+
+		eventType = ShowListEventUpdate
+		if taskInfo.AlarmFlag != taskInfoOld.AlarmFlag {
+			eventType = ShowListEventFlagChange
 		}
 	}
 
@@ -135,11 +311,7 @@ func (impl *showListImpl) Add(taskInfo *ShowInfo) (err error) {
 		return
 	}
 
-	tmpTaskInfo := *taskInfo
-
-	if impl.changeObserver != nil {
-		impl.changeObserver(&tmpTaskInfo, true)
-	}
+	impl.notify(eventType, taskInfo, oldShowInfo)
 
 	return
 }
@@ -176,12 +348,10 @@ func (impl *showListImpl) Remove(taskID string) (err error) {
 		return
 	}
 
-	if impl.changeObserver != nil {
-		impl.changeObserver(&ShowInfo{
-			ID:    taskID,
-			Value: taskInfo.Value,
-		}, false)
-	}
+	impl.notify(ShowListEventRemove, &ShowInfo{
+		ID:    taskID,
+		Value: taskInfo.Value,
+	}, nil)
 
 	return
 }
@@ -194,13 +364,15 @@ func (impl *showListImpl) GetList() (tasks []*ShowInfo, err error) {
 		return
 	}
 
+	timeNow := impl.clock.Now()
+
 	for _, d := range ds {
 		taskInfo, ok := d.(*ShowInfo)
 		if !ok {
 			continue
 		}
 
-		taskInfo.AutoFill()
+		taskInfo.AutoFill(timeNow)
 
 		tasks = append(tasks, taskInfo)
 	}
@@ -0,0 +1,60 @@
+package timeassist
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type utVisibleEvent struct {
+	id      string
+	visible bool
+}
+
+func TestShowListObservers(t *testing.T) {
+	var visibleEvents []utVisibleEvent
+
+	showList := NewShowList(filepath.Join(t.TempDir(), "task_list"), func(task *ShowInfo, visible bool) {
+		visibleEvents = append(visibleEvents, utVisibleEvent{id: task.ID, visible: visible})
+	}, nil, NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	var events []*ShowListEvent
+
+	obID := showList.AddEventOb(func(event *ShowListEvent) {
+		events = append(events, event)
+	})
+
+	assert.Nil(t, showList.Add(&ShowInfo{ID: "Aa", Value: "v1"}))
+	assert.Nil(t, showList.Add(&ShowInfo{ID: "Aa", Value: "v1", AlarmFlag: true}))
+	assert.Nil(t, showList.Add(&ShowInfo{ID: "Aa", Value: "v2", AlarmFlag: true}))
+	assert.Nil(t, showList.Remove("Aa"))
+	assert.Nil(t, showList.Remove("Aa"))
+
+	var eventTypes []ShowListEventType
+	for _, event := range events {
+		eventTypes = append(eventTypes, event.Type)
+	}
+
+	assert.Equal(t, []ShowListEventType{ShowListEventAdd, ShowListEventFlagChange, ShowListEventUpdate, ShowListEventRemove}, eventTypes)
+	assert.False(t, events[1].Old.AlarmFlag)
+	assert.True(t, events[1].Show.AlarmFlag)
+	assert.NotEqual(t, events[0].Show.NotifyID, events[1].Show.NotifyID)
+	assert.Equal(t, "v1", events[2].Old.Value)
+	assert.Equal(t, "v2", events[2].Show.Value)
+
+	// 旧的观察者: 更新时先不可见再可见
+	assert.Equal(t, []utVisibleEvent{
+		{"Aa", true},
+		{"Aa", false}, {"Aa", true},
+		{"Aa", false}, {"Aa", true},
+		{"Aa", false},
+	}, visibleEvents)
+
+	showList.RemoveOb(obID)
+
+	assert.Nil(t, showList.Add(&ShowInfo{ID: "Ab", Value: "v"}))
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, 7, len(visibleEvents))
+}
@@ -1,9 +1,10 @@
 package timeassist
 
 import (
-	"fmt"
 	"os"
 	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
 )
 
 type Task struct {
@@ -21,13 +22,25 @@ type Task struct {
 	Auto  bool `yaml:"Auto,omitempty" json:"auto,omitempty"`
 
 	TimeZone  int        `yaml:"TimeZone,omitempty" json:"time_zone,omitempty"`
+	Location  string     `yaml:"Location,omitempty" json:"location,omitempty"` // IANA 时区名, 如 Europe/Berlin, 设置后忽略 TimeZone
 	ValidTime *ValidTime `yaml:"ValidRanges,omitempty" json:"valid_time,omitempty"`
+
+	Notify     *NotifyRoute `yaml:"Notify,omitempty" json:"notify,omitempty"`
+	Escalation *Escalation  `yaml:"Escalation,omitempty" json:"escalation,omitempty"` // 过期后没有完成时重复通知
+
+	Paused   bool  `yaml:"Paused,omitempty" json:"paused,omitempty"`      // 暂停时保留定义, 没有定时和显示
+	ResumeAt int64 `yaml:"ResumeAt,omitempty" json:"resume_at,omitempty"` // 自动恢复的时间(unix 秒), 0 需要手动恢复
 }
 
 func (ct *Task) Valid() (err error) {
+	return ct.ValidEx(time.Now(), nil)
+}
+
+// ValidEx calendar 为空时使用内置的节假日数据
+func (ct *Task) ValidEx(timeNow time.Time, calendar HolidayCalendar) (err error) {
 	err = os.ErrInvalid
 
-	if ct.ID == "" || ct.TType <= TimeTypeBegin || ct.TType >= TimeTypeEnd || ct.Text == "" {
+	if ct.ID == "" || ct.TType <= TimeTypeBegin || ct.TType >= TimeTypeEnd || ct.TType == RecycleTimeTypeCron || ct.TType == RecycleTimeTypeRRule || ct.Text == "" {
 		return
 	}
 
@@ -37,60 +50,77 @@ func (ct *Task) Valid() (err error) {
 		}
 	}
 
-	err = nil
+	if ct.ValidTime != nil {
+		if err = ct.ValidTime.Valid(timeNow, calendar); err != nil {
+			return
+		}
+	}
+
+	_, err = TimeLocation(ct.Location, ct.TimeZone)
 
 	return
 }
 
 func (ct *Task) Desc() string {
+	return ct.DescLocale(locale.Default)
+}
+
+func (ct *Task) DescLocale(lc locale.Locale) string {
 	var desc string
 
 	lunar := ""
 	if ct.LunarFlag {
-		lunar = "阴历"
+		lunar = lc.T("task.lunar")
 	}
 
 	auto := ""
 	if ct.Auto {
-		auto = "自动过期刷新"
+		auto = lc.T("task.auto")
 	}
 
 	switch ct.TType {
 	case TimeTypeOnce:
-		desc = "单次"
+		desc = lc.T("task.once")
 	case RecycleTimeTypeYear:
-		desc = fmt.Sprintf("%d %s年一次 %s", ct.Value, lunar, auto)
+		desc = lc.T("task.year", ct.Value, lunar, auto)
 	case RecycleTimeTypeMonth:
-		desc = fmt.Sprintf("%d %s月一次 %s", ct.Value, lunar, auto)
+		desc = lc.T("task.month", ct.Value, lunar, auto)
 	case RecycleTimeTypeWeek:
-		desc = fmt.Sprintf("%d 周一次 %s", ct.Value, auto)
+		desc = lc.T("task.week", ct.Value, auto)
 	case RecycleTimeTypeDay:
-		desc = fmt.Sprintf("%d 天一次 %s", ct.Value, auto)
+		desc = lc.T("task.day", ct.Value, auto)
 	case RecycleTimeTypeHour:
-		desc = fmt.Sprintf("%d 小时一次 %s", ct.Value, auto)
+		desc = lc.T("task.hour", ct.Value, auto)
 	case RecycleTimeTypeMinute:
-		desc = fmt.Sprintf("%d 分钟一次 %s", ct.Value, auto)
+		desc = lc.T("task.minute", ct.Value, auto)
 	default:
-		desc = fmt.Sprintf("unknown type: %d", ct.TType)
+		desc = lc.T("task.unknown", ct.TType)
 	}
 
 	return desc
 }
 
 func (ct *Task) GenRecycleData() (rd *ShowItem, nowIsValid bool) {
-	return ct.GenRecycleDataEx(time.Now())
+	return ct.GenRecycleDataEx(time.Now(), nil)
 }
-func (ct *Task) GenRecycleDataEx(timeNow time.Time) (rd *ShowItem, nowIsValid bool) {
-	rd, nowIsValid = ct.genRecycleDataEx(timeNow)
-	if rd.EndUTC > time.Now().Unix() {
+
+// GenRecycleDataEx calendar 为空时使用内置的节假日数据
+func (ct *Task) GenRecycleDataEx(timeNow time.Time, calendar HolidayCalendar) (rd *ShowItem, nowIsValid bool) {
+	return ct.GenRecycleDataFrom(timeNow, timeNow, calendar)
+}
+
+// GenRecycleDataFrom 从 timeFrom 所在的周期开始计算, 直到周期结束时间晚于 timeNow
+func (ct *Task) GenRecycleDataFrom(timeFrom, timeNow time.Time, calendar HolidayCalendar) (rd *ShowItem, nowIsValid bool) {
+	rd, nowIsValid = ct.genRecycleDataEx(timeFrom, calendar)
+	if rd.EndUTC > timeNow.Unix() {
 		return
 	}
 
 	for {
-		timeNow = time.Unix(rd.EndUTC, 0)
+		timeFrom = time.Unix(rd.EndUTC, 0)
 
-		rd, _ = ct.genRecycleDataEx(timeNow)
-		if rd.EndUTC > time.Now().Unix() {
+		rd, _ = ct.genRecycleDataEx(timeFrom, calendar)
+		if rd.EndUTC > timeNow.Unix() {
 			break
 		}
 	}
@@ -98,18 +128,20 @@ func (ct *Task) GenRecycleDataEx(timeNow time.Time) (rd *ShowItem, nowIsValid bo
 	return
 }
 
-func (ct *Task) genRecycleDataEx(timeNow time.Time) (rd *ShowItem, nowIsValid bool) {
+func (ct *Task) genRecycleDataEx(timeNow time.Time, calendar HolidayCalendar) (rd *ShowItem, nowIsValid bool) {
 	rd = &ShowItem{
 		ID: ct.ID,
 	}
 
-	if ct.TimeZone < -11 || ct.TimeZone > 12 {
-		ct.TimeZone = 8
+	// 时区在 Valid 中已经检查过
+	location, err := TimeLocation(ct.Location, ct.TimeZone)
+	if err != nil {
+		location, _ = TimeLocation("", defaultTimeZone)
 	}
 
 	nowIsValid = true
 
-	timeNow = timeNow.In(time.FixedZone("X", ct.TimeZone*3600))
+	timeNow = timeNow.In(location)
 
 	fnFillRD := func(startProc func(t time.Time) time.Time, addProc func(t time.Time, minutes int) time.Time) {
 		t := timeNow
@@ -117,7 +149,7 @@ func (ct *Task) genRecycleDataEx(timeNow time.Time) (rd *ShowItem, nowIsValid bo
 		if ct.ValidTime != nil {
 			var curIsValid bool
 
-			curIsValid, t = ct.ValidTime.FindAfterTime(t)
+			curIsValid, t = ct.ValidTime.FindAfterTime(t, calendar)
 			if !curIsValid && nowIsValid {
 				nowIsValid = false
 			}
@@ -127,7 +159,7 @@ func (ct *Task) genRecycleDataEx(timeNow time.Time) (rd *ShowItem, nowIsValid bo
 
 		et := addProc(st, ct.Value)
 		if ct.ValidTime != nil {
-			_, et = ct.ValidTime.FindAfterTime(et)
+			_, et = ct.ValidTime.FindAfterTime(et, calendar)
 		}
 
 		rd.StartUTC = st.Unix()
@@ -0,0 +1,336 @@
+package timeassist
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/sgostarter/libeasygo/pathutils"
+)
+
+// maxStatPeriods 统计时最多展开的周期数, 避免分钟级任务展开过多
+const maxStatPeriods = 100000
+
+// TaskCompletion 一次完成记录, 单次任务没有周期
+type TaskCompletion struct {
+	TaskID   string    `json:"task_id"`
+	StartUTC int64     `json:"start_utc,omitempty"`
+	EndUTC   int64     `json:"end_utc,omitempty"`
+	DoneAt   time.Time `json:"done_at"`
+	OnTime   bool      `json:"on_time"` // 在周期结束前完成, 过期后完成的为 false
+}
+
+// NewTaskCompletion 按当前显示的周期生成完成记录
+func NewTaskCompletion(showInfo *ShowInfo, doneAt time.Time) *TaskCompletion {
+	completion := &TaskCompletion{
+		TaskID:   showInfo.ID,
+		StartUTC: showInfo.StartUTC,
+		EndUTC:   showInfo.EndUTC,
+		DoneAt:   doneAt,
+		OnTime:   !showInfo.AlarmFlag,
+	}
+
+	if completion.EndUTC > 0 && doneAt.Unix() >= completion.EndUTC {
+		completion.OnTime = false
+	}
+
+	return completion
+}
+
+type TaskCompletionRate struct {
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	Periods   int       `json:"periods"`   // 开始时间在范围内的周期数
+	Completed int       `json:"completed"` // 其中完成的周期数
+	OnTime    int       `json:"on_time"`
+	Rate      float64   `json:"rate"`
+}
+
+// TaskStreak 连续完成的周期数, 过期后完成的也算; 当前周期还没有结束时不打断
+type TaskStreak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+}
+
+// TaskHistory 每个任务一个只追加的完成记录文件, 每行一条 JSON
+type TaskHistory interface {
+	Record(completion *TaskCompletion) error
+	// List startAt/endAt 为零时不限制, 按完成时间过滤
+	List(taskID string, startAt, endAt time.Time) ([]*TaskCompletion, error)
+	Rate(task *Task, startAt, endAt time.Time) (*TaskCompletionRate, error)
+	Streak(task *Task) (*TaskStreak, error)
+}
+
+func NewTaskHistory(root string, calendar HolidayCalendar, clock Clock) TaskHistory {
+	return &taskHistoryImpl{
+		root:     root,
+		calendar: fixHolidayCalendar(calendar),
+		clock:    fixClock(clock),
+	}
+}
+
+type taskHistoryImpl struct {
+	root     string
+	calendar HolidayCalendar
+	clock    Clock
+
+	lock sync.Mutex
+}
+
+func (impl *taskHistoryImpl) fileName(taskID string) (string, error) {
+	if ParsePreOnID(taskID) != TaskIDPre || filepath.Base(taskID) != taskID {
+		return "", commerr.ErrInvalidArgument
+	}
+
+	return filepath.Join(impl.root, taskID), nil
+}
+
+func (impl *taskHistoryImpl) Record(completion *TaskCompletion) (err error) {
+	if completion == nil {
+		return commerr.ErrInvalidArgument
+	}
+
+	fileName, err := impl.fileName(completion.TaskID)
+	if err != nil {
+		return
+	}
+
+	d, err := json.Marshal(completion)
+	if err != nil {
+		return
+	}
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	if err = pathutils.MustDirExists(impl.root); err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(append(d, '\n'))
+
+	if e := f.Close(); err == nil {
+		err = e
+	}
+
+	return
+}
+
+func (impl *taskHistoryImpl) load(taskID string) (completions []*TaskCompletion, err error) {
+	fileName, err := impl.fileName(taskID)
+	if err != nil {
+		return
+	}
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+
+		return
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		completion := &TaskCompletion{}
+
+		if err = json.Unmarshal(scanner.Bytes(), completion); err != nil {
+			err = fmt.Errorf("task history %s: %w", taskID, err)
+
+			return
+		}
+
+		completions = append(completions, completion)
+	}
+
+	err = scanner.Err()
+
+	return
+}
+
+func (impl *taskHistoryImpl) List(taskID string, startAt, endAt time.Time) (completions []*TaskCompletion, err error) {
+	all, err := impl.load(taskID)
+	if err != nil {
+		return
+	}
+
+	completions = make([]*TaskCompletion, 0, len(all))
+
+	for _, completion := range all {
+		if !startAt.IsZero() && completion.DoneAt.Before(startAt) {
+			continue
+		}
+
+		if !endAt.IsZero() && !completion.DoneAt.Before(endAt) {
+			continue
+		}
+
+		completions = append(completions, completion)
+	}
+
+	return
+}
+
+// periods 开始时间在 [startAt, endAt) 内的周期
+func (impl *taskHistoryImpl) periods(task *Task, startAt, endAt time.Time) (periods []*ShowItem, err error) {
+	if task.TType == TimeTypeOnce {
+		err = fmt.Errorf("%w: once task has no period", commerr.ErrInvalidArgument)
+
+		return
+	}
+
+	rd, _ := task.genRecycleDataEx(startAt, impl.calendar)
+
+	for rd.StartUTC < endAt.Unix() {
+		if rd.StartUTC >= startAt.Unix() {
+			periods = append(periods, rd)
+		}
+
+		if len(periods) > maxStatPeriods {
+			err = fmt.Errorf("%w: too many periods", commerr.ErrOutOfRange)
+
+			return
+		}
+
+		next, _ := task.genRecycleDataEx(time.Unix(rd.EndUTC, 0), impl.calendar)
+		if next.EndUTC <= rd.EndUTC {
+			break
+		}
+
+		rd = next
+	}
+
+	return
+}
+
+// completedPeriods 周期开始时间 => 是否按时完成
+func (impl *taskHistoryImpl) completedPeriods(taskID string) (completed map[int64]bool, firstStartUTC int64, err error) {
+	completions, err := impl.load(taskID)
+	if err != nil {
+		return
+	}
+
+	completed = make(map[int64]bool)
+
+	for _, completion := range completions {
+		if completion.StartUTC == 0 {
+			continue
+		}
+
+		completed[completion.StartUTC] = completed[completion.StartUTC] || completion.OnTime
+
+		if firstStartUTC == 0 || completion.StartUTC < firstStartUTC {
+			firstStartUTC = completion.StartUTC
+		}
+	}
+
+	return
+}
+
+func (impl *taskHistoryImpl) Rate(task *Task, startAt, endAt time.Time) (rate *TaskCompletionRate, err error) {
+	if task == nil || !startAt.Before(endAt) {
+		return nil, commerr.ErrInvalidArgument
+	}
+
+	periods, err := impl.periods(task, startAt, endAt)
+	if err != nil {
+		return
+	}
+
+	completed, _, err := impl.completedPeriods(task.ID)
+	if err != nil {
+		return
+	}
+
+	rate = &TaskCompletionRate{
+		StartAt: startAt,
+		EndAt:   endAt,
+		Periods: len(periods),
+	}
+
+	for _, period := range periods {
+		onTime, ok := completed[period.StartUTC]
+		if !ok {
+			continue
+		}
+
+		rate.Completed++
+
+		if onTime {
+			rate.OnTime++
+		}
+	}
+
+	if rate.Periods > 0 {
+		rate.Rate = float64(rate.Completed) / float64(rate.Periods)
+	}
+
+	return
+}
+
+func (impl *taskHistoryImpl) Streak(task *Task) (streak *TaskStreak, err error) {
+	if task == nil {
+		return nil, commerr.ErrInvalidArgument
+	}
+
+	completed, firstStartUTC, err := impl.completedPeriods(task.ID)
+	if err != nil {
+		return
+	}
+
+	streak = &TaskStreak{}
+
+	if firstStartUTC == 0 {
+		return
+	}
+
+	timeNow := impl.clock.Now()
+
+	periods, err := impl.periods(task, time.Unix(firstStartUTC, 0), timeNow.Add(time.Second))
+	if err != nil {
+		return
+	}
+
+	var run int
+
+	for _, period := range periods {
+		if _, ok := completed[period.StartUTC]; ok {
+			run++
+		} else if period.EndUTC > timeNow.Unix() {
+			// 当前周期还可以完成
+			continue
+		} else {
+			run = 0
+		}
+
+		if run > streak.Longest {
+			streak.Longest = run
+		}
+	}
+
+	streak.Current = run
+
+	return
+}
@@ -0,0 +1,83 @@
+package timeassist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgostarter/i/commerr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskHistory(t *testing.T) {
+	tz8 := time.FixedZone("z8", 8*3600)
+	env := utNewEnv(t, time.Date(2026, 3, 2, 8, 0, 0, 0, tz8), nil)
+	history := NewTaskHistory(t.TempDir(), nil, env.clock)
+
+	task := &Task{
+		TType:    RecycleTimeTypeDay,
+		Value:    1,
+		Text:     "2小时：躺一会",
+		TimeZone: 8,
+	}
+
+	assert.Nil(t, env.taskManager.Add(task))
+
+	done := func() {
+		showInfo, err := env.showList.Get(task.ID)
+		assert.Nil(t, err)
+		assert.NotNil(t, showInfo)
+
+		assert.Nil(t, history.Record(NewTaskCompletion(showInfo, env.clock.Now())))
+		env.taskManager.TaskDone(task.ID)
+		assert.Nil(t, env.showList.Remove(task.ID))
+	}
+
+	// 3/2, 3/3 按时完成, 3/4 过期后在 3/5 完成, 3/5 没有显示, 3/6 按时完成
+	done()
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 3, 9, 0, 0, 0, tz8))
+	done()
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 5, 9, 0, 0, 0, tz8))
+	env.clock.Set(time.Date(2026, 3, 5, 9, 0, 0, 0, tz8))
+	done()
+
+	completions, err := history.List(task.ID, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Len(t, completions, 3)
+	assert.True(t, completions[0].OnTime)
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, tz8).Unix(), completions[0].StartUTC)
+	assert.Equal(t, time.Date(2026, 3, 3, 0, 0, 0, 0, tz8).Unix(), completions[0].EndUTC)
+	assert.False(t, completions[2].OnTime)
+	assert.Equal(t, time.Date(2026, 3, 4, 0, 0, 0, 0, tz8).Unix(), completions[2].StartUTC)
+
+	utRunTimerUntil(t, env.clock, env.timer, time.Date(2026, 3, 6, 9, 0, 0, 0, tz8))
+	env.clock.Set(time.Date(2026, 3, 6, 9, 0, 0, 0, tz8))
+
+	// 当前周期还没有完成, 不打断也不计入
+	streak, err := history.Streak(task)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, streak.Longest)
+	assert.Equal(t, 0, streak.Current)
+
+	done()
+
+	streak, err = history.Streak(task)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, streak.Longest)
+	assert.Equal(t, 1, streak.Current)
+
+	completions, err = history.List(task.ID, time.Date(2026, 3, 3, 0, 0, 0, 0, tz8), time.Date(2026, 3, 6, 0, 0, 0, 0, tz8))
+	assert.Nil(t, err)
+	assert.Len(t, completions, 2)
+
+	rate, err := history.Rate(task, time.Date(2026, 3, 2, 0, 0, 0, 0, tz8), time.Date(2026, 3, 7, 0, 0, 0, 0, tz8))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, rate.Periods)
+	assert.Equal(t, 4, rate.Completed)
+	assert.Equal(t, 3, rate.OnTime)
+	assert.InDelta(t, 0.8, rate.Rate, 0.001)
+
+	_, err = history.Rate(&Task{ID: task.ID, TType: TimeTypeOnce}, rate.StartAt, rate.EndAt)
+	assert.ErrorIs(t, err, commerr.ErrInvalidArgument)
+
+	assert.ErrorIs(t, history.Record(&TaskCompletion{TaskID: "T../x"}), commerr.ErrInvalidArgument)
+}
@@ -3,21 +3,29 @@ package timeassist
 import (
 	"time"
 
+	"github.com/s-min-sys/timeassistbe/internal/locale"
+	"github.com/sgostarter/i/commerr"
 	"github.com/sgostarter/i/l"
 	"github.com/sgostarter/libeasygo/stg/kv"
 )
 
 // Callback 如果data存在， 则其ID必须为dRemoved的ID
-type Callback func(dRemoved *ShowItem) (at time.Time, data *ShowItem, err error)
+// ShowList 和 meta 的变化只能记录到 intent 中, 由 timer 落盘后执行
+type Callback func(dRemoved *ShowItem, intent *Intent) (at time.Time, data *ShowItem, err error)
 
 type TaskManager interface {
 	Add(task *Task) error
+	Update(task *Task) error
+	Get(taskID string) (*Task, error)
+	List() ([]*Task, error)
 	Remove(taskID string) error
 	Done(taskID string) error
 	TaskDone(taskID string)
+	Pause(taskID string, resumeAt time.Time) error
+	Resume(taskID string) error
 }
 
-func NewTaskManager(storage kv.Storage, timer BizTaskTimer, taskList ShowList, logger l.Wrapper) TaskManager {
+func NewTaskManager(storage kv.StorageTiny, timer BizTaskTimer, taskList ShowList, calendar HolidayCalendar, logger l.Wrapper, clock Clock) TaskManager {
 	if logger == nil {
 		logger = l.NewNopLoggerWrapper()
 	}
@@ -31,6 +39,8 @@ func NewTaskManager(storage kv.Storage, timer BizTaskTimer, taskList ShowList, l
 		storage:  storage,
 		timer:    timer,
 		showList: taskList,
+		calendar: fixHolidayCalendar(calendar),
+		clock:    fixClock(clock),
 	}
 
 	impl.init()
@@ -40,37 +50,62 @@ func NewTaskManager(storage kv.Storage, timer BizTaskTimer, taskList ShowList, l
 
 type taskManagerImpl struct {
 	logger   l.Wrapper
-	storage  kv.Storage
+	storage  kv.StorageTiny
 	timer    BizTaskTimer
 	showList ShowList
+	calendar HolidayCalendar
+	clock    Clock
 }
 
 func (impl *taskManagerImpl) init() {
 	impl.timer.SetCallback(TaskIDPre, impl.timerCb)
 }
 
-func (impl *taskManagerImpl) formatTaskSubTitle(task *Task, taskData *ShowItem) string {
-	var timeLayout string
+func (impl *taskManagerImpl) formatTaskSubTitle(task *Task, taskData *ShowItem, lc locale.Locale) string {
+	var layoutKey string
 
 	switch task.TType {
 	case RecycleTimeTypeMinute:
-		timeLayout = "15时04分"
+		layoutKey = "task.layout.minute"
 	case RecycleTimeTypeHour:
-		timeLayout = "02号15时"
+		layoutKey = "task.layout.hour"
 	case RecycleTimeTypeDay:
-		timeLayout = "01月02号"
+		layoutKey = "task.layout.day"
 	case RecycleTimeTypeWeek:
-		timeLayout = "01月02号"
+		layoutKey = "task.layout.week"
 	case RecycleTimeTypeMonth:
-		timeLayout = "2006年01月02号" // nolint: goconst
+		layoutKey = "task.layout.month"
 	case RecycleTimeTypeYear:
-		timeLayout = "2006年01月02号" // nolint: goconst
+		layoutKey = "task.layout.year"
 	case TimeTypeOnce:
 		return ""
 	}
 
-	return time.Unix(taskData.StartUTC, 0).Format(timeLayout) + "-" +
-		time.Unix(taskData.EndUTC, 0).Format(timeLayout)
+	timeLayout := lc.T(layoutKey)
+
+	// 按 task 的时区显示周期, 时区在 Valid 中已经检查过
+	location, err := TimeLocation(task.Location, task.TimeZone)
+	if err != nil {
+		location, _ = TimeLocation("", defaultTimeZone)
+	}
+
+	return time.Unix(taskData.StartUTC, 0).In(location).Format(timeLayout) + "-" +
+		time.Unix(taskData.EndUTC, 0).In(location).Format(timeLayout)
+}
+
+func (impl *taskManagerImpl) newTaskShowInfo(task *Task, taskData *ShowItem) *ShowInfo {
+	subTitle, subTitles := newSubTitles(func(lc locale.Locale) string {
+		return impl.formatTaskSubTitle(task, taskData, lc)
+	})
+
+	return &ShowInfo{
+		ID:        task.ID,
+		Value:     task.Text,
+		SubTitle:  subTitle,
+		SubTitles: subTitles,
+		StartUTC:  taskData.StartUTC,
+		EndUTC:    taskData.EndUTC,
+	}
 }
 
 func (impl *taskManagerImpl) TaskDone(taskID string) {
@@ -78,6 +113,8 @@ func (impl *taskManagerImpl) TaskDone(taskID string) {
 		return
 	}
 
+	_ = impl.timer.RemoveTimer(EscalateTimerID(taskID))
+
 	var task Task
 
 	ok, err := impl.storage.Get(taskID, &task)
@@ -89,13 +126,13 @@ func (impl *taskManagerImpl) TaskDone(taskID string) {
 		return
 	}
 
-	if task.TType == TimeTypeOnce {
+	if task.TType == TimeTypeOnce || task.Paused {
 		return
 	}
 
-	timeNow := time.Now()
+	timeNow := impl.clock.Now()
 
-	rd, _ := task.GenRecycleDataEx(timeNow)
+	rd, _ := task.GenRecycleDataEx(timeNow, impl.calendar)
 	if rd == nil {
 		return
 	}
@@ -107,7 +144,22 @@ func (impl *taskManagerImpl) TaskDone(taskID string) {
 	}
 }
 
-func (impl *taskManagerImpl) timerCb(dRemoved *ShowItem) (at time.Time, data *ShowItem, err error) {
+func (impl *taskManagerImpl) timerCb(dRemoved *ShowItem, intent *Intent) (at time.Time, data *ShowItem, err error) {
+	if taskID, ok := ParseEscalateTimerID(dRemoved.ID); ok {
+		task, e := impl.Get(taskID)
+		if e != nil || task == nil {
+			return
+		}
+
+		return escalate(impl.showList, taskID, task.Escalation, intent, impl.clock.Now())
+	}
+
+	if taskID, ok := ParseResumeTimerID(dRemoved.ID); ok {
+		impl.resumeTimerCb(taskID, intent)
+
+		return
+	}
+
 	showInfo, err := impl.showList.Get(dRemoved.ID)
 	if err != nil {
 		return
@@ -120,22 +172,22 @@ func (impl *taskManagerImpl) timerCb(dRemoved *ShowItem) (at time.Time, data *Sh
 		return
 	}
 
-	if !ok {
+	if !ok || task.Paused {
 		if showInfo != nil {
-			_ = impl.showList.Remove(dRemoved.ID)
+			intent.RemoveShow()
 		}
 
 		return
 	}
 
-	timeNow := time.Now()
+	timeNow := impl.clock.Now()
 
 	if timeNow.Before(time.Unix(dRemoved.StartUTC, 0)) {
 		at = time.Unix(dRemoved.StartUTC, 0)
 		data = dRemoved
 
 		if showInfo != nil {
-			_ = impl.showList.Remove(dRemoved.ID)
+			intent.RemoveShow()
 		}
 
 		return
@@ -145,11 +197,7 @@ func (impl *taskManagerImpl) timerCb(dRemoved *ShowItem) (at time.Time, data *Sh
 		at = time.Unix(dRemoved.EndUTC, 0)
 		data = dRemoved
 
-		_ = impl.showList.Add(&ShowInfo{
-			ID:       task.ID,
-			Value:    task.Text,
-			SubTitle: impl.formatTaskSubTitle(task, dRemoved),
-		})
+		intent.AddShow(impl.newTaskShowInfo(task, dRemoved))
 
 		return
 	}
@@ -157,29 +205,32 @@ func (impl *taskManagerImpl) timerCb(dRemoved *ShowItem) (at time.Time, data *Sh
 	if !task.Auto {
 		if showInfo != nil {
 			showInfo.AlarmFlag = true
+			showInfo.EscalationCount = 0
 
-			_ = impl.showList.Add(showInfo)
+			intent.AddShow(showInfo)
+			intent.AddTimer(task.Escalation.escalationTimer(task.ID, timeNow))
 
 			return
 		}
 	}
 
-	rd, nowIsValid := task.GenRecycleDataEx(time.Unix(dRemoved.EndUTC, 0))
+	rd, nowIsValid := task.GenRecycleDataFrom(time.Unix(dRemoved.EndUTC, 0), timeNow, impl.calendar)
+
+	intent.AddEvent(EventTaskRolledOver, task.ID, &EventPeriod{
+		StartAt: time.Unix(rd.StartUTC, 0),
+		EndAt:   time.Unix(rd.EndUTC, 0),
+	})
 	if nowIsValid {
-		_ = impl.showList.Add(&ShowInfo{
-			ID:       task.ID,
-			Value:    task.Text,
-			SubTitle: impl.formatTaskSubTitle(task, rd),
-		})
+		intent.AddShow(impl.newTaskShowInfo(task, rd))
 
 		at = time.Unix(rd.EndUTC, 0)
 		data = rd
 	} else {
 		if showInfo != nil {
-			_ = impl.showList.Remove(dRemoved.ID)
+			intent.RemoveShow()
 		}
 
-		if time.Now().Unix() < rd.StartUTC {
+		if timeNow.Unix() < rd.StartUTC {
 			at = time.Unix(rd.StartUTC, 0)
 		} else {
 			at = time.Unix(rd.EndUTC, 0)
@@ -202,62 +253,197 @@ func (impl *taskManagerImpl) Add(task *Task) (err error) {
 
 	task.ID = FixTaskID(task.ID)
 
-	err = task.Valid()
+	return impl.schedule(task)
+}
+
+func (impl *taskManagerImpl) Update(task *Task) (err error) {
+	if task == nil || ParsePreOnID(task.ID) != TaskIDPre {
+		return commerr.ErrInvalidArgument
+	}
+
+	old, err := impl.Get(task.ID)
 	if err != nil {
 		return
 	}
 
-	err = impl.storage.Set(task.ID, task)
+	if old == nil {
+		return commerr.ErrNotFound
+	}
+
+	// 暂停状态只通过 Pause/Resume 修改
+	task.Paused = old.Paused
+	task.ResumeAt = old.ResumeAt
+
+	// 升级属于修改前的那个周期
+	_ = impl.timer.RemoveTimer(EscalateTimerID(task.ID))
+
+	return impl.schedule(task)
+}
+
+// schedule 重新计算 task 的显示和定时, 与 meta 一起原子地生效
+func (impl *taskManagerImpl) schedule(task *Task) (err error) {
+	err = task.ValidEx(impl.clock.Now(), impl.calendar)
 	if err != nil {
 		return
 	}
 
+	intent := &Intent{
+		ID: task.ID,
+	}
+
+	intent.SetTask(task)
+
+	if task.Paused {
+		intent.pause(task.ResumeAt)
+	} else {
+		intent.TimerAt, intent.TimerData = impl.planSchedule(task, intent)
+	}
+
+	return impl.timer.ApplyIntent(intent)
+}
+
+// planSchedule 从现在开始计算显示并记录到 intent, 返回周期定时; 单次任务没有定时
+func (impl *taskManagerImpl) planSchedule(task *Task, intent *Intent) (at time.Time, data *ShowItem) {
 	if task.TType == TimeTypeOnce {
-		err = impl.showList.Add(&ShowInfo{
-			ID:       task.ID,
-			Value:    task.Text,
-			SubTitle: "单次任务",
+		subTitle, subTitles := newSubTitles(func(lc locale.Locale) string {
+			return lc.T("task.once_subtitle")
+		})
+
+		intent.AddShow(&ShowInfo{
+			ID:        task.ID,
+			Value:     task.Text,
+			SubTitle:  subTitle,
+			SubTitles: subTitles,
 		})
-		if err != nil {
-			_ = impl.storage.Del(task.ID)
-		}
 
 		return
 	}
 
-	rd, nowIsValid := task.GenRecycleData()
+	rd, nowIsValid := task.GenRecycleDataEx(impl.clock.Now(), impl.calendar)
 	if nowIsValid {
-		err = impl.showList.Add(&ShowInfo{
-			ID:       task.ID,
-			Value:    task.Text,
-			SubTitle: impl.formatTaskSubTitle(task, rd),
-		})
-		if err != nil {
-			_ = impl.storage.Del(task.ID)
+		intent.AddShow(impl.newTaskShowInfo(task, rd))
 
-			return
-		}
-
-		err = impl.timer.AddTimer(time.Unix(rd.EndUTC, 0), rd)
+		at = time.Unix(rd.EndUTC, 0)
 	} else {
-		err = impl.timer.AddTimer(time.Unix(rd.StartUTC, 0), rd)
+		intent.RemoveShow()
+
+		at = time.Unix(rd.StartUTC, 0)
+	}
+
+	data = rd
+
+	return
+}
+
+// Pause 保留定义, 移除显示和所有定时; resumeAt 不为零时到时自动恢复
+func (impl *taskManagerImpl) Pause(taskID string, resumeAt time.Time) (err error) {
+	task, err := impl.Get(taskID)
+	if err != nil {
+		return
 	}
 
+	if task == nil {
+		return commerr.ErrNotFound
+	}
+
+	task.ResumeAt, err = checkResumeAt(resumeAt, impl.clock.Now())
+	if err != nil {
+		return
+	}
+
+	task.Paused = true
+
+	_ = impl.timer.RemoveTimer(ResumeTimerID(taskID))
+	_ = impl.timer.RemoveTimer(EscalateTimerID(taskID))
+
+	return impl.schedule(task)
+}
+
+// Resume 从现在开始重新计算当前周期
+func (impl *taskManagerImpl) Resume(taskID string) (err error) {
+	task, err := impl.Get(taskID)
 	if err != nil {
-		_ = impl.storage.Del(task.ID)
-		_ = impl.showList.Remove(task.ID)
+		return
+	}
+
+	if task == nil {
+		return commerr.ErrNotFound
+	}
+
+	if !task.Paused {
+		return
+	}
+
+	task.Paused = false
+	task.ResumeAt = 0
+
+	_ = impl.timer.RemoveTimer(ResumeTimerID(taskID))
 
+	return impl.schedule(task)
+}
+
+// resumeTimerCb 自动恢复, 周期定时记录到 intent.Timers
+func (impl *taskManagerImpl) resumeTimerCb(taskID string, intent *Intent) {
+	task, err := impl.Get(taskID)
+	if err != nil || task == nil || !task.Paused {
+		return
+	}
+
+	task.Paused = false
+	task.ResumeAt = 0
+
+	intent.TargetID = taskID
+	intent.SetTask(task)
+
+	at, data := impl.planSchedule(task, intent)
+	if data != nil {
+		intent.AddTimer(&D{Data: data, At: at})
+	}
+}
+
+func (impl *taskManagerImpl) Get(taskID string) (task *Task, err error) {
+	if ParsePreOnID(taskID) != TaskIDPre {
+		return
+	}
+
+	task = &Task{}
+
+	ok, err := impl.storage.Get(taskID, task)
+	if err != nil || !ok {
+		task = nil
+	}
+
+	return
+}
+
+func (impl *taskManagerImpl) List() (tasks []*Task, err error) {
+	ids, err := listMetaIDs(impl.storage, TaskIDPre)
+	if err != nil {
 		return
 	}
 
+	for _, id := range ids {
+		task, e := impl.Get(id)
+		if e != nil || task == nil {
+			continue
+		}
+
+		tasks = append(tasks, task)
+	}
+
 	return
 }
 
 func (impl *taskManagerImpl) Done(taskID string) error {
+	_ = impl.timer.RemoveTimer(EscalateTimerID(taskID))
+
 	return impl.showList.Remove(taskID)
 }
 
 func (impl *taskManagerImpl) Remove(taskID string) error {
+	_ = impl.timer.RemoveTimer(ResumeTimerID(taskID))
+	_ = impl.timer.RemoveTimer(EscalateTimerID(taskID))
+	_ = impl.timer.RemoveTimer(taskID)
 	_ = impl.showList.Remove(taskID)
 	_ = impl.storage.Del(taskID)
 
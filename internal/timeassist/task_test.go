@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/s-min-sys/timeassistbe/internal/locale"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/yaml.v3"
 )
@@ -102,7 +103,7 @@ func TestRecycleTask1(t *testing.T) {
 
 	timeNow := time.Date(2023, 2, 11, 10, 20, 10, 0, time.Local)
 
-	rd, nowIsValid := ct.GenRecycleDataEx(timeNow)
+	rd, nowIsValid := ct.GenRecycleDataEx(timeNow, nil)
 
 	fnCheck1 := func(b, eB bool, startUTC, endUTC int64, sY, sMonth, sD, sH, sMinute, sS, eY, eMonth, eD, eH, eMinute, eS int) {
 		assert.EqualValues(t, eB, b)
@@ -143,7 +144,7 @@ func TestRecycleTask1(t *testing.T) {
 
 	timeNow = time.Date(2023, 2, 11, 13, 20, 10, 0, time.Local)
 
-	rd, nowIsValid = ct.GenRecycleDataEx(timeNow)
+	rd, nowIsValid = ct.GenRecycleDataEx(timeNow, nil)
 
 	fnCheck1(nowIsValid, true, rd.StartUTC, rd.EndUTC,
 		2023, 2, 11, 13, 20, 0,
@@ -165,7 +166,7 @@ func TestRecycleTask1(t *testing.T) {
 
 	timeNow = time.Date(2023, 2, 11, 13, 20, 10, 0, time.Local)
 
-	rd, nowIsValid = ct.GenRecycleDataEx(timeNow)
+	rd, nowIsValid = ct.GenRecycleDataEx(timeNow, nil)
 
 	fnCheck1(nowIsValid, true, rd.StartUTC, rd.EndUTC,
 		2023, 2, 11, 13, 20, 0,
@@ -187,7 +188,7 @@ func TestRecycleTask1(t *testing.T) {
 
 	timeNow = time.Date(2023, 2, 11, 13, 20, 10, 0, time.Local)
 
-	rd, nowIsValid = ct.GenRecycleDataEx(timeNow)
+	rd, nowIsValid = ct.GenRecycleDataEx(timeNow, nil)
 
 	fnCheck1(nowIsValid, false, rd.StartUTC, rd.EndUTC,
 		2023, 2, 17, 0, 0, 0,
@@ -234,7 +235,7 @@ func TestRecycleTask2(t *testing.T) {
 
 	timeNow := time.Date(2023, 2, 10, 10, 20, 10, 0, tz)
 
-	rd, nowIsValid := ct.GenRecycleDataEx(timeNow)
+	rd, nowIsValid := ct.GenRecycleDataEx(timeNow, nil)
 
 	fnCheck := utCheck1(t)
 
@@ -256,7 +257,7 @@ func TestRecycleTask2(t *testing.T) {
 		ss.WriteString(fmt.Sprintf("%d,%d,%d,%d,%d,%d,\n", t4p.Year(), t4p.Month(), t4p.Day(), t4p.Hour(), t4p.Minute(), t4p.Second()))
 		ss.WriteString("},\n")
 
-		rd, nowIsValid = ct.GenRecycleDataEx(time.Unix(rd.EndUTC, 0))
+		rd, nowIsValid = ct.GenRecycleDataEx(time.Unix(rd.EndUTC, 0), nil)
 	}
 }
 
@@ -271,3 +272,17 @@ func TestTime(t *testing.T) {
 	assert.Nil(t, err)
 	t.Log(string(d))
 }
+
+func TestFormatTaskSubTitleLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.Nil(t, err)
+
+	task := &Task{ID: "T1", TType: RecycleTimeTypeHour, Value: 1, Text: "t", Location: "Europe/Berlin"}
+	taskData := &ShowItem{
+		StartUTC: time.Date(2026, 3, 2, 10, 0, 0, 0, berlin).Unix(),
+		EndUTC:   time.Date(2026, 3, 2, 11, 0, 0, 0, berlin).Unix(),
+	}
+
+	// 按 task 的时区显示, 与服务器的时区无关
+	assert.Equal(t, "02号10时-02号11时", (&taskManagerImpl{}).formatTaskSubTitle(task, taskData, locale.ZhCN))
+}
@@ -1,11 +1,13 @@
 package timeassist
 
 import (
+	"container/heap"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/s-min-sys/timeassistbe/internal/trace"
+	"github.com/sgostarter/i/commerr"
 	"github.com/sgostarter/libeasygo/stg/kv"
 )
 
@@ -16,7 +18,10 @@ type D struct {
 
 type TaskTimer interface {
 	Start()
+	Stop()
 	AddTimer(at time.Time, data *ShowItem) error
+	RemoveTimer(id string) error
+	ApplyIntent(intent *Intent) error
 	SetCallback(cb Callback)
 	List() (items []D, err error)
 }
@@ -24,6 +29,8 @@ type TaskTimer interface {
 // BizTaskTimer 危险 确保 idPre 不重复 且 ShowItem 的 ID 符合规则
 type BizTaskTimer interface {
 	AddTimer(at time.Time, data *ShowItem) error
+	RemoveTimer(id string) error
+	ApplyIntent(intent *Intent) error
 	SetCallback(idPre string, cb Callback)
 }
 
@@ -49,6 +56,14 @@ func (impl *bizTimerImpl) AddTimer(at time.Time, data *ShowItem) error {
 	return impl.timer.AddTimer(at, data)
 }
 
+func (impl *bizTimerImpl) RemoveTimer(id string) error {
+	return impl.timer.RemoveTimer(id)
+}
+
+func (impl *bizTimerImpl) ApplyIntent(intent *Intent) error {
+	return impl.timer.ApplyIntent(intent)
+}
+
 func (impl *bizTimerImpl) SetCallback(idPre string, cb Callback) {
 	impl.idCheckers[idPre] = cb
 }
@@ -68,32 +83,65 @@ func (impl *bizTimerImpl) checkCallback(id string) Callback {
 	return nil
 }
 
-func (impl *bizTimerImpl) timerCB(dRemoved *ShowItem) (at time.Time, data *ShowItem, err error) {
+func (impl *bizTimerImpl) timerCB(dRemoved *ShowItem, intent *Intent) (at time.Time, data *ShowItem, err error) {
 	cb := impl.checkCallback(dRemoved.ID)
 	if cb == nil {
 		return
 	}
 
-	return cb(dRemoved)
+	return cb(dRemoved, intent)
 }
 
-func NewTaskTimer(fileName string) TaskTimer {
+// NewTaskTimer journal 为空时, 回调记录到 Intent 中的 ShowList/meta 变化无法执行
+func NewTaskTimer(fileName string, journal IntentJournal, eventBus EventBus, clock Clock) TaskTimer {
 	storage, err := kv.NewMemoryFileStorageEx(fileName, false)
 	if err != nil {
 		return nil
 	}
 
-	return &taskTimerImpl{
-		storage: storage,
+	impl := &taskTimerImpl{
+		storage:   storage,
+		journal:   journal,
+		eventBus:  fixEventBus(eventBus),
+		clock:     fixClock(clock),
+		itemsByID: make(map[string]*timerItem),
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+
+	if impl.journal == nil {
+		impl.journal = &nopIntentJournal{}
 	}
+
+	impl.load()
+	impl.replay()
+
+	return impl
 }
 
 type taskTimerImpl struct {
-	storage kv.StorageTiny
-	cb      Callback
+	storage  kv.StorageTiny
+	journal  IntentJournal
+	eventBus EventBus
+	clock    Clock
+	cb       Callback
+
+	// fireLock 保证回调与 ApplyIntent 串行执行
+	fireLock sync.Mutex
+
+	itemsLock sync.Mutex
+	items     timerHeap
+	itemsByID map[string]*timerItem
+
+	wakeCh chan struct{}
+
+	runLock  sync.Mutex
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
 }
 
-func (impl *taskTimerImpl) check() {
+func (impl *taskTimerImpl) load() {
 	ds, err := impl.storage.GetMap(func(_ string) interface{} {
 		return &D{}
 	})
@@ -101,67 +149,328 @@ func (impl *taskTimerImpl) check() {
 		return
 	}
 
-	timeNow := time.Now()
-
-	var at time.Time
-
-	var data *ShowItem
+	impl.itemsLock.Lock()
+	defer impl.itemsLock.Unlock()
 
 	for k, v := range ds {
 		d, ok := v.(*D)
-		if !ok {
+		if !ok || d.Data == nil {
+			_ = impl.storage.Del(k)
+
 			continue
 		}
 
-		if timeNow.Before(d.At) {
-			continue
+		impl.pushItemNoLock(d)
+	}
+}
+
+// replay 执行上次退出时未完成的 Intent
+func (impl *taskTimerImpl) replay() {
+	intents, err := impl.journal.Pending()
+	if err != nil {
+		return
+	}
+
+	for _, intent := range intents {
+		trace.Get().RecordMessage(intent.ID, fmt.Sprintf("replay intent at stage %d", intent.Stage))
+
+		_ = impl.applyIntent(intent, true)
+	}
+}
+
+func (impl *taskTimerImpl) wakeup() {
+	select {
+	case impl.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (impl *taskTimerImpl) pushItemNoLock(d *D) {
+	if item, ok := impl.itemsByID[d.Data.ID]; ok {
+		item.d = d
+		heap.Fix(&impl.items, item.index)
+
+		return
+	}
+
+	item := &timerItem{
+		d: d,
+	}
+
+	heap.Push(&impl.items, item)
+	impl.itemsByID[d.Data.ID] = item
+}
+
+func (impl *taskTimerImpl) removeItemNoLock(id string) bool {
+	item, ok := impl.itemsByID[id]
+	if !ok {
+		return false
+	}
+
+	heap.Remove(&impl.items, item.index)
+	delete(impl.itemsByID, id)
+
+	return true
+}
+
+// popDue 取出一个到期的 D; 没有到期的则返回最早的到期时间
+func (impl *taskTimerImpl) popDue(timeNow time.Time) (d *D, nextAt time.Time, hasNext bool) {
+	impl.itemsLock.Lock()
+	defer impl.itemsLock.Unlock()
+
+	if impl.items.Len() == 0 {
+		return
+	}
+
+	item := impl.items[0]
+	if timeNow.Before(item.d.At) {
+		nextAt = item.d.At
+		hasNext = true
+
+		return
+	}
+
+	heap.Pop(&impl.items)
+	delete(impl.itemsByID, item.d.Data.ID)
+
+	d = item.d
+
+	return
+}
+
+func (impl *taskTimerImpl) check() (nextAt time.Time, hasNext bool) {
+	for {
+		var d *D
+
+		d, nextAt, hasNext = impl.popDue(impl.clock.Now())
+		if d == nil {
+			return
 		}
 
-		at, data, err = impl.cb(d.Data)
+		impl.fire(d)
+	}
+}
+
+func (impl *taskTimerImpl) fire(d *D) {
+	impl.fireLock.Lock()
+	defer impl.fireLock.Unlock()
+
+	if impl.rescheduled(d.Data.ID) {
+		return
+	}
+
+	intent := &Intent{
+		ID:    d.Data.ID,
+		Fired: d,
+	}
+
+	if impl.cb != nil {
+		at, data, err := impl.cb(d.Data, intent)
 
 		if err == nil && data != nil && data.ID != "" {
 			if data.ID != d.Data.ID {
 				panic("mismatched id")
 			}
 
-			err = impl.storage.Set(data.ID, &D{
-				Data: data,
-				At:   at,
-			})
+			intent.TimerAt = at
+			intent.TimerData = data
+		}
+	}
+
+	if err := impl.journal.Begin(intent); err != nil {
+		trace.Get().RecordMessage(intent.ID, fmt.Sprintf("begin intent failed: %v", err))
+	}
 
-			if err != nil {
-				trace.Get().RecordMessage(data.ID, fmt.Sprintf("add timer %s  failed: %v", at.String(), err))
-			} else {
-				trace.Get().RecordTimeSchedule(data.ID, at)
-			}
-		} else {
-			_ = impl.storage.Del(k)
+	_ = impl.applyIntent(intent, false)
+}
+
+// ApplyIntent 在定时回调之外修改某个 ID 的 ShowList/meta/定时
+func (impl *taskTimerImpl) ApplyIntent(intent *Intent) error {
+	if intent == nil || intent.ID == "" {
+		return commerr.ErrInvalidArgument
+	}
+
+	if intent.TimerData != nil && intent.TimerData.ID != intent.ID {
+		return commerr.ErrInvalidArgument
+	}
+
+	impl.fireLock.Lock()
+	defer impl.fireLock.Unlock()
 
-			trace.Get().RecordRemoveTimeSchedule(d.Data.ID)
+	if err := impl.journal.Begin(intent); err != nil {
+		return err
+	}
+
+	return impl.applyIntent(intent, true)
+}
+
+func (impl *taskTimerImpl) applyIntent(intent *Intent, forceRemove bool) (err error) {
+	if err = impl.journal.ApplyEffects(intent); err != nil {
+		trace.Get().RecordMessage(intent.ID, fmt.Sprintf("apply intent failed: %v", err))
+
+		return
+	}
+
+	if intent.Stage < IntentStageTimerApplied {
+		if intent.TimerData != nil {
+			err = impl.AddTimer(intent.TimerAt, intent.TimerData)
+		} else if forceRemove {
+			_ = impl.RemoveTimer(intent.ID)
+		} else if impl.delIfNotRescheduled(intent.ID) {
+			trace.Get().RecordRemoveTimeSchedule(intent.ID)
+			impl.eventBus.Publish(EventTimerRemoved, intent.ID, nil)
+		}
+
+		if err != nil {
+			return
 		}
+
+		for _, d := range intent.Timers {
+			if err = impl.AddTimer(d.At, d.Data); err != nil {
+				return
+			}
+		}
+
+		_ = impl.journal.MarkStage(intent, IntentStageTimerApplied)
+	}
+
+	if err = impl.journal.Finish(intent.ID); err != nil {
+		return
+	}
+
+	intent.publishEvents(impl.eventBus)
+
+	return
+}
+
+// rescheduled 取出后在回调前又被重新设置了定时, 取出的数据已经过时
+func (impl *taskTimerImpl) rescheduled(id string) bool {
+	impl.itemsLock.Lock()
+	defer impl.itemsLock.Unlock()
+
+	_, ok := impl.itemsByID[id]
+
+	return ok
+}
+
+// delIfNotRescheduled 回调期间可能已经重新 AddTimer, 此时保留新的定时
+func (impl *taskTimerImpl) delIfNotRescheduled(id string) bool {
+	impl.itemsLock.Lock()
+	defer impl.itemsLock.Unlock()
+
+	if _, ok := impl.itemsByID[id]; ok {
+		return false
 	}
+
+	_ = impl.storage.Del(id)
+
+	return true
 }
 
 func (impl *taskTimerImpl) Start() {
+	impl.runLock.Lock()
+	defer impl.runLock.Unlock()
+
+	if impl.doneCh != nil {
+		return
+	}
+
+	impl.doneCh = make(chan struct{})
+
 	go func() {
+		defer close(impl.doneCh)
+
 		for {
-			impl.check()
+			nextAt, hasNext := impl.check()
+
+			var timerC <-chan time.Time
+
+			var timer ClockTimer
+
+			if hasNext {
+				timer = impl.clock.NewTimer(nextAt.Sub(impl.clock.Now()))
+				timerC = timer.C()
+			}
 
-			time.Sleep(time.Second * 10)
+			select {
+			case <-timerC:
+			case <-impl.wakeCh:
+			case <-impl.stopCh:
+				if timer != nil {
+					timer.Stop()
+				}
+
+				return
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
 		}
 	}()
 }
 
+// Stop 停止检查定时, 等待正在执行的回调和 Intent 完成
+func (impl *taskTimerImpl) Stop() {
+	impl.stopOnce.Do(func() {
+		close(impl.stopCh)
+	})
+
+	impl.runLock.Lock()
+	doneCh := impl.doneCh
+	impl.runLock.Unlock()
+
+	if doneCh != nil {
+		<-doneCh
+	}
+}
+
 func (impl *taskTimerImpl) AddTimer(at time.Time, data *ShowItem) error {
-	err := impl.storage.Set(data.ID, &D{
+	if data == nil || data.ID == "" {
+		return commerr.ErrInvalidArgument
+	}
+
+	d := &D{
 		Data: data,
 		At:   at,
-	})
+	}
+
+	impl.itemsLock.Lock()
+
+	err := impl.storage.Set(data.ID, d)
+	if err == nil {
+		impl.pushItemNoLock(d)
+	}
+
+	impl.itemsLock.Unlock()
 
 	if err != nil {
 		trace.Get().RecordMessage(data.ID, fmt.Sprintf("add timer %s  failed: %v", at.String(), err))
-	} else {
-		trace.Get().RecordTimeSchedule(data.ID, at)
+
+		return err
+	}
+
+	trace.Get().RecordTimeSchedule(data.ID, at)
+	impl.eventBus.Publish(EventTimerScheduled, data.ID, &EventTimer{At: at})
+
+	impl.wakeup()
+
+	return nil
+}
+
+func (impl *taskTimerImpl) RemoveTimer(id string) error {
+	impl.itemsLock.Lock()
+
+	removed := impl.removeItemNoLock(id)
+	err := impl.storage.Del(id)
+
+	impl.itemsLock.Unlock()
+
+	if removed {
+		trace.Get().RecordRemoveTimeSchedule(id)
+		impl.eventBus.Publish(EventTimerRemoved, id, nil)
+
+		impl.wakeup()
 	}
 
 	return err
@@ -172,22 +481,13 @@ func (impl *taskTimerImpl) SetCallback(cb Callback) {
 }
 
 func (impl *taskTimerImpl) List() (items []D, err error) {
-	ds, err := impl.storage.GetMap(func(_ string) interface{} {
-		return &D{}
-	})
-	if err != nil {
-		return
-	}
-
-	items = make([]D, 0, len(ds))
+	impl.itemsLock.Lock()
+	defer impl.itemsLock.Unlock()
 
-	for _, v := range ds {
-		d, ok := v.(*D)
-		if !ok {
-			continue
-		}
+	items = make([]D, 0, impl.items.Len())
 
-		items = append(items, *d)
+	for _, item := range impl.items {
+		items = append(items, *item.d)
 	}
 
 	return
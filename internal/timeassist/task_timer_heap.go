@@ -0,0 +1,40 @@
+package timeassist
+
+type timerItem struct {
+	d     *D
+	index int
+}
+
+// timerHeap 按 D.At 排序的小顶堆
+type timerHeap []*timerItem
+
+func (h timerHeap) Len() int {
+	return len(h)
+}
+
+func (h timerHeap) Less(i, j int) bool {
+	return h[i].d.At.Before(h[j].d.At)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	item, _ := x.(*timerItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*h = old[0 : n-1]
+
+	return item
+}
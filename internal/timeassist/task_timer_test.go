@@ -1,17 +1,30 @@
 package timeassist
 
 import (
-	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
 	utTestTimerFile = "recycle_task_timer.txt"
 )
 
+// utNewTaskTimer 每个测试使用独立的文件, 结束时停止 timer
+func utNewTaskTimer(t *testing.T) (timer TaskTimer, fileName string) {
+	fileName = filepath.Join(t.TempDir(), utTestTimerFile)
+
+	timer = NewTaskTimer(fileName, nil, nil, nil)
+	require.NotNil(t, timer)
+
+	t.Cleanup(timer.Stop)
+
+	return
+}
+
 func Test11(t *testing.T) {
 	b := []byte{0x01, 0x02, 0x00, 0x08}
 	t.Log(b)
@@ -27,11 +40,9 @@ func Test11(t *testing.T) {
 }
 
 func TestNewRecycleTaskTimer(t *testing.T) {
-	_ = os.Remove(utTestTimerFile)
-
-	timer := NewTaskTimer(utTestTimerFile)
+	timer, _ := utNewTaskTimer(t)
 
-	timer.SetCallback(func(dRemoved *ShowItem) (at time.Time, data *ShowItem, err error) {
+	timer.SetCallback(func(dRemoved *ShowItem, _ *Intent) (at time.Time, data *ShowItem, err error) {
 		t.Log("timeNow:", time.Now(), ", data", dRemoved)
 
 		return
@@ -55,3 +66,91 @@ func TestNewRecycleTaskTimer(t *testing.T) {
 
 	time.Sleep(time.Minute * 2)
 }
+
+func TestTaskTimerFireOnTime(t *testing.T) {
+	timer, _ := utNewTaskTimer(t)
+
+	firedCh := make(chan time.Time, 10)
+
+	timer.SetCallback(func(dRemoved *ShowItem, _ *Intent) (at time.Time, data *ShowItem, err error) {
+		firedCh <- time.Now()
+
+		return
+	})
+
+	timer.Start()
+
+	at := time.Now().Add(time.Millisecond * 300)
+
+	err := timer.AddTimer(at, &ShowItem{
+		ID: "1",
+	})
+	assert.Nil(t, err)
+
+	select {
+	case firedAt := <-firedCh:
+		assert.False(t, firedAt.Before(at))
+		assert.True(t, firedAt.Sub(at) < time.Second)
+	case <-time.After(time.Second * 3):
+		assert.Fail(t, "timer not fired")
+	}
+
+	items, err := timer.List()
+	assert.Nil(t, err)
+	assert.Empty(t, items)
+}
+
+func TestTaskTimerReschedule(t *testing.T) {
+	timer, fileName := utNewTaskTimer(t)
+
+	firedCh := make(chan string, 10)
+
+	timer.SetCallback(func(dRemoved *ShowItem, _ *Intent) (at time.Time, data *ShowItem, err error) {
+		firedCh <- dRemoved.ID
+
+		if dRemoved.ID == "2" && dRemoved.EndUTC == 0 {
+			at = time.Now().Add(time.Millisecond * 100)
+			data = &ShowItem{
+				ID:     dRemoved.ID,
+				EndUTC: 1,
+			}
+		}
+
+		return
+	})
+
+	timer.Start()
+
+	assert.Nil(t, timer.AddTimer(time.Now().Add(time.Hour), &ShowItem{ID: "1"}))
+	assert.Nil(t, timer.AddTimer(time.Now().Add(time.Millisecond*200), &ShowItem{ID: "2"}))
+	assert.Nil(t, timer.AddTimer(time.Now().Add(time.Millisecond*100), &ShowItem{ID: "3"}))
+	assert.Nil(t, timer.RemoveTimer("3"))
+
+	var fired []string
+
+	for len(fired) < 2 {
+		select {
+		case id := <-firedCh:
+			fired = append(fired, id)
+		case <-time.After(time.Second * 3):
+			assert.FailNow(t, "timer not fired")
+		}
+	}
+
+	assert.Equal(t, []string{"2", "2"}, fired)
+
+	// 回调返回后 Intent 可能还在执行, 停止后文件不再变化
+	timer.Stop()
+
+	items, err := timer.List()
+	assert.Nil(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "1", items[0].Data.ID)
+
+	reloaded := NewTaskTimer(fileName, nil, nil, nil)
+
+	items, err = reloaded.List()
+	assert.Nil(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "1", items[0].Data.ID)
+}
@@ -11,5 +11,7 @@ const (
 	RecycleTimeTypeDay
 	RecycleTimeTypeHour
 	RecycleTimeTypeMinute
+	RecycleTimeTypeCron  // 仅用于 Alarm, Value 为 cron 表达式
+	RecycleTimeTypeRRule // 仅用于 Alarm, Value 为 DTSTART/RRULE/EXDATE
 	TimeTypeEnd
 )
@@ -10,47 +10,49 @@ import (
 )
 
 func MinuteStart(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	return t.Add(-time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
 }
 
 func MinuteEnd(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 59, 0, t.Location())
+	return MinuteStart(t).Add(time.Second * 59)
 }
 
+// MinuteAdd 按实际经过的时间计算, 夏令时切换时不会重复或者跳过
 func MinuteAdd(t time.Time, minutes int) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+minutes, t.Second(), t.Nanosecond(), t.Location())
+	return t.Add(time.Duration(minutes) * time.Minute)
 }
 
 func HourStart(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	return MinuteStart(t).Add(-time.Duration(t.Minute()) * time.Minute)
 }
 
 func HourEnd(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 59, 59, 0, t.Location())
+	return HourStart(t).Add(time.Minute*59 + time.Second*59)
 }
 
+// HourAdd 按实际经过的时间计算, 夏令时切换时不会重复或者跳过
 func HourAdd(t time.Time, hours int) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+hours, t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return t.Add(time.Duration(hours) * time.Hour)
 }
 
 func DayStart(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return localDate(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 }
 
 func DayEnd(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
+	return localDate(t.Year(), t.Month(), t.Day(), 23, 59, 59, 0, t.Location())
 }
 
 func DayAdd(t time.Time, days int) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day()+days, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return localDate(t.Year(), t.Month(), t.Day()+days, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
 }
 
 func WeekStart(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	return localDate(t.Year(), t.Month(), t.Day()-int(t.Weekday()), 0, 0, 0, 0, t.Location())
 }
 
 func WeekEnd(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day()-int(t.Weekday())+6, 23, 59, 59, 0, t.Location())
+	return localDate(t.Year(), t.Month(), t.Day()-int(t.Weekday())+6, 23, 59, 59, 0, t.Location())
 }
 
 func WeekAdd(t time.Time, weeks int) time.Time {
@@ -58,19 +60,19 @@ func WeekAdd(t time.Time, weeks int) time.Time {
 
 	sw = sw.Next(weeks, false)
 
-	return time.Date(sw.GetYear(), time.Month(sw.GetMonth()), sw.GetDay(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return localDate(sw.GetYear(), time.Month(sw.GetMonth()), sw.GetDay(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
 }
 
 func MonthStart(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return localDate(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
 }
 
 func MonthEnd(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), SolarUtil.GetDaysOfMonth(t.Year(), int(t.Month())), 23, 59, 59, 0, t.Location())
+	return localDate(t.Year(), t.Month(), SolarUtil.GetDaysOfMonth(t.Year(), int(t.Month())), 23, 59, 59, 0, t.Location())
 }
 
 func MonthAdd(t time.Time, months int) time.Time {
-	return time.Date(t.Year(), t.Month()+time.Month(months), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return localDate(t.Year(), t.Month()+time.Month(months), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
 }
 
 func LunarMonthStart(t time.Time) time.Time {
@@ -145,15 +147,15 @@ func LunarMonthEndAdd(t time.Time, months int) time.Time {
 }
 
 func YearStart(t time.Time) time.Time {
-	return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+	return localDate(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
 }
 
 func YearEnd(t time.Time) time.Time {
-	return time.Date(t.Year(), 12, SolarUtil.GetDaysOfMonth(t.Year(), 12), 23, 59, 59, 0, t.Location())
+	return localDate(t.Year(), 12, SolarUtil.GetDaysOfMonth(t.Year(), 12), 23, 59, 59, 0, t.Location())
 }
 
 func YearAdd(t time.Time, years int) time.Time {
-	return time.Date(t.Year()+years, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return localDate(t.Year()+years, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
 }
 
 func LunarYearStart(t time.Time) time.Time {
@@ -226,13 +228,9 @@ func LunarYearEndAdd(t time.Time, years int) time.Time {
 	return time.Date(st.GetYear(), time.Month(st.GetMonth()), st.GetDay(), 23, 59, 59, 0, tz8).In(t.Location())
 }
 
+// IsWorkDay 考虑法定节假日和调休, 使用内置数据; 自定义数据使用 HolidayCalendar.IsWorkDay
 func IsWorkDay(t time.Time) bool {
-	switch t.Weekday() {
-	case time.Sunday, time.Saturday:
-		return false
-	}
-
-	return true
+	return builtinHolidayCalendar.IsWorkDay(t)
 }
 
 func WeekIndexInMonth(t time.Time) int {
@@ -240,7 +238,7 @@ func WeekIndexInMonth(t time.Time) int {
 }
 
 func ToDateTime(year, month, day, hour, minute, second int, location *time.Location) time.Time {
-	return time.Date(year, time.Month(month), day, hour, minute, second, 0, location)
+	return localDate(year, time.Month(month), day, hour, minute, second, 0, location)
 }
 
 func LunarToDateTime(year, month, day, hour, minute, second int) time.Time {
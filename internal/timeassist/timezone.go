@@ -0,0 +1,59 @@
+package timeassist
+
+import (
+	"time"
+)
+
+const (
+	defaultTimeZone = 8
+)
+
+// TimeLocation location 为 IANA 时区名, 如 Europe/Berlin; 为空时使用整数小时偏移 timeZone(兼容旧数据)
+func TimeLocation(location string, timeZone int) (*time.Location, error) {
+	if location != "" {
+		return time.LoadLocation(location)
+	}
+
+	if timeZone < -11 || timeZone > 12 {
+		timeZone = defaultTimeZone
+	}
+
+	return time.FixedZone("X", timeZone*3600), nil
+}
+
+// localDate 同 time.Date, 但对夏令时切换附近的本地时间有确定的处理:
+// 不存在的时间(拨快)顺延跳变的时长, 重复的时间(拨慢)取第一次出现
+func localDate(year int, month time.Month, day, hour, minute, second, nsec int, loc *time.Location) time.Time {
+	wall := time.Date(year, month, day, hour, minute, second, nsec, time.UTC)
+	wallUnix := wall.Unix()
+
+	_, offsetBefore := wall.Add(-time.Hour * 24).In(loc).Zone()
+	_, offsetAfter := wall.Add(time.Hour * 24).In(loc).Zone()
+
+	var found bool
+
+	var t time.Time
+
+	for _, offset := range []int{offsetBefore, offsetAfter} {
+		candidate := time.Unix(wallUnix-int64(offset), int64(wall.Nanosecond())).In(loc)
+		if !sameWallClock(candidate, wall) {
+			continue
+		}
+
+		if !found || candidate.Before(t) {
+			t = candidate
+			found = true
+		}
+	}
+
+	if !found {
+		t = time.Unix(wallUnix-int64(offsetBefore), int64(wall.Nanosecond())).In(loc)
+	}
+
+	return t
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	return t.Year() == wall.Year() && t.Month() == wall.Month() && t.Day() == wall.Day() &&
+		t.Hour() == wall.Hour() && t.Minute() == wall.Minute() && t.Second() == wall.Second()
+}
@@ -0,0 +1,106 @@
+package timeassist
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func utMustLoadLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	assert.Nil(t, err)
+
+	return loc
+}
+
+func TestLocalDateDST(t *testing.T) {
+	berlin := utMustLoadLocation(t, "Europe/Berlin")
+
+	// 不存在的时间顺延
+	tm := localDate(2026, 3, 29, 2, 30, 0, 0, berlin)
+	assert.True(t, tm.Equal(time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC)), tm.String())
+	assert.Equal(t, 3, tm.Hour())
+
+	// 重复的时间取第一次
+	tm = localDate(2026, 10, 25, 2, 30, 0, 0, berlin)
+	assert.True(t, tm.Equal(time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC)), tm.String())
+
+	// 天数溢出
+	tm = localDate(2026, 1, 32, 0, 0, 0, 0, berlin)
+	assert.Equal(t, time.February, tm.Month())
+	assert.Equal(t, 1, tm.Day())
+
+	newYork := utMustLoadLocation(t, "America/New_York")
+
+	dayStart := DayStart(time.Date(2026, 3, 8, 12, 0, 0, 0, newYork))
+	assert.Equal(t, time.Hour*23, DayAdd(dayStart, 1).Sub(dayStart))
+
+	hourStart := HourStart(time.Date(2026, 11, 1, 6, 40, 0, 0, time.UTC).In(newYork))
+	assert.True(t, hourStart.Equal(time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC)), hourStart.String())
+	assert.Equal(t, time.Hour, HourAdd(hourStart, 1).Sub(hourStart))
+}
+
+func TestAlarmLocation(t *testing.T) {
+	alarm := &Alarm{
+		ID:       "A1",
+		AType:    RecycleTimeTypeDay,
+		Text:     "t",
+		Value:    "023000",
+		Location: "Europe/Berlin",
+	}
+
+	timeNow := time.Date(2026, 3, 28, 12, 0, 0, 0, time.UTC)
+
+	_, timeAt, _, _, _, err := alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.True(t, timeAt.Equal(time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC)), timeAt.String())
+
+	timeNow = time.Date(2026, 3, 29, 4, 0, 0, 0, time.UTC)
+
+	_, timeAt, _, _, _, err = alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.True(t, timeAt.Equal(time.Date(2026, 3, 30, 0, 30, 0, 0, time.UTC)), timeAt.String())
+
+	// 夏令时结束时重复的小时
+	alarm.AType = RecycleTimeTypeHour
+	alarm.Value = "1500"
+	timeNow = time.Date(2026, 10, 25, 0, 20, 0, 0, time.UTC)
+
+	_, timeAt, _, _, _, err = alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.True(t, timeAt.Equal(time.Date(2026, 10, 25, 1, 15, 0, 0, time.UTC)), timeAt.String())
+
+	alarm.AType = RecycleTimeTypeDay
+	alarm.Value = "090000"
+	alarm.Location = "Asia/Kolkata"
+
+	_, timeAt, _, _, _, err = alarm.GenRecycleDataEx(timeNow, timeNow, nil)
+	assert.Nil(t, err)
+	assert.True(t, timeAt.Equal(time.Date(2026, 10, 25, 3, 30, 0, 0, time.UTC)), timeAt.String())
+
+	alarm.Location = "Mars/Olympus"
+
+	_, err = alarm.Validate()
+	assert.NotNil(t, err)
+}
+
+func TestTaskLocation(t *testing.T) {
+	task := &Task{
+		ID:       "T1",
+		TType:    RecycleTimeTypeDay,
+		Value:    1,
+		Text:     "t",
+		Location: "America/New_York",
+	}
+
+	assert.Nil(t, task.Valid())
+
+	rd, nowIsValid := task.GenRecycleDataEx(time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC), nil)
+	assert.True(t, nowIsValid)
+	assert.Equal(t, int64(23*3600), rd.EndUTC-rd.StartUTC)
+
+	task.Location = "Nowhere/City"
+	assert.NotNil(t, task.Valid())
+}
@@ -2,6 +2,15 @@ package timeassist
 
 import (
 	"time"
+
+	"github.com/sgostarter/i/commerr"
+)
+
+const (
+	// maxValidTimeRetry 按 ValidTime 重新计算下次时间的最大次数, 防止配置无有效时间时死循环
+	maxValidTimeRetry = 1000
+	// maxValidTimeDays FindAfterTime 最多向后查找的天数, 节假日数据用完后可能再也没有有效的日期
+	maxValidTimeDays = 366 * maxValidTimeRetry
 )
 
 type ValidRange struct {
@@ -33,6 +42,8 @@ type ValidTime struct {
 	ValidDaysInMonth  *ValidRanges `yaml:"ValidDaysInMonth,omitempty" json:"valid_days_in_month,omitempty"`
 	ValidDaysInWeek   *ValidRanges `yaml:"ValidDaysInWeek,omitempty" json:"valid_days_in_week,omitempty"`
 	ValidHoursInDay   *ValidRanges `yaml:"ValidHoursInDay,omitempty" json:"valid_hours_in_day,omitempty"`
+	OnlyWorkDays      bool         `yaml:"OnlyWorkDays,omitempty" json:"only_work_days,omitempty"` // 只在工作日有效, 含调休上班的周末
+	SkipHolidays      bool         `yaml:"SkipHolidays,omitempty" json:"skip_holidays,omitempty"`  // 法定节假日无效
 }
 
 func (vt *ValidTime) Reset() {
@@ -41,18 +52,55 @@ func (vt *ValidTime) Reset() {
 	vt.ValidDaysInMonth = nil
 	vt.ValidDaysInWeek = nil
 	vt.ValidHoursInDay = nil
+	vt.OnlyWorkDays = false
+	vt.SkipHolidays = false
+}
+
+func (vt *ValidTime) isValidDay(t time.Time, calendar HolidayCalendar) bool {
+	if vt.OnlyWorkDays && !calendar.IsWorkDay(t) {
+		return false
+	}
+
+	if vt.SkipHolidays && calendar.IsHoliday(t) {
+		return false
+	}
+
+	return true
+}
+
+// Valid 从 timeNow 开始找不到有效时间时返回 ErrInvalidArgument, calendar 为空时使用内置的节假日数据
+func (vt *ValidTime) Valid(timeNow time.Time, calendar HolidayCalendar) error {
+	if _, _, found := vt.findAfterTime(timeNow, calendar); !found {
+		return commerr.ErrInvalidArgument
+	}
+
+	return nil
+}
+
+// FindAfterTime 找不到有效时间时 tIsOk 为 false, nextT 为查找结束的时间; calendar 为空时使用内置的节假日数据
+func (vt *ValidTime) FindAfterTime(t time.Time, calendar HolidayCalendar) (tIsOk bool, nextT time.Time) {
+	tIsOk, nextT, _ = vt.findAfterTime(t, calendar)
+
+	return
 }
 
-func (vt *ValidTime) FindAfterTime(t time.Time) (tIsOk bool, nextT time.Time) {
+func (vt *ValidTime) findAfterTime(t time.Time, calendar HolidayCalendar) (tIsOk bool, nextT time.Time, found bool) {
 	tIsOk = true
 	nextT = t
 
+	calendar = fixHolidayCalendar(calendar)
+
+	limit := t.AddDate(0, 0, maxValidTimeDays)
+
 RETRY:
 	if vt.ValidMonthsInYear != nil {
 		for !vt.ValidMonthsInYear.IsValid(int(nextT.Month())) {
 			tIsOk = false
 
 			nextT = MonthStart(MonthAdd(nextT, 1))
+			if nextT.After(limit) {
+				return false, nextT, false
+			}
 		}
 	}
 
@@ -63,6 +111,9 @@ RETRY:
 			needRetry = true
 
 			nextT = WeekStart(WeekAdd(nextT, 1))
+			if nextT.After(limit) {
+				return false, nextT, false
+			}
 		}
 
 		if needRetry {
@@ -79,6 +130,9 @@ RETRY:
 			needRetry = true
 
 			nextT = DayStart(DayAdd(nextT, 1))
+			if nextT.After(limit) {
+				return false, nextT, false
+			}
 		}
 
 		if needRetry {
@@ -95,6 +149,28 @@ RETRY:
 			needRetry = true
 
 			nextT = DayStart(DayAdd(nextT, 1))
+			if nextT.After(limit) {
+				return false, nextT, false
+			}
+		}
+
+		if needRetry {
+			tIsOk = false
+
+			goto RETRY
+		}
+	}
+
+	if vt.OnlyWorkDays || vt.SkipHolidays {
+		var needRetry bool
+
+		for !vt.isValidDay(nextT, calendar) {
+			needRetry = true
+
+			nextT = DayStart(DayAdd(nextT, 1))
+			if nextT.After(limit) {
+				return false, nextT, false
+			}
 		}
 
 		if needRetry {
@@ -111,6 +187,9 @@ RETRY:
 			needRetry = true
 
 			nextT = HourStart(HourAdd(nextT, 1))
+			if nextT.After(limit) {
+				return false, nextT, false
+			}
 		}
 
 		if needRetry {
@@ -120,5 +199,7 @@ RETRY:
 		}
 	}
 
+	found = true
+
 	return
 }
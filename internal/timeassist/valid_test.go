@@ -25,7 +25,7 @@ func TestValid1(t *testing.T) {
 
 	nowT := time.Date(2023, 2, 11, 19, 13, 0, 0, time.Local)
 
-	tIsOk, newT := vt.FindAfterTime(nowT)
+	tIsOk, newT := vt.FindAfterTime(nowT, nil)
 	assert.True(t, tIsOk)
 	assert.EqualValues(t, nowT, newT)
 
@@ -38,7 +38,7 @@ func TestValid1(t *testing.T) {
 		},
 	}
 
-	tIsOk, newT = vt.FindAfterTime(nowT)
+	tIsOk, newT = vt.FindAfterTime(nowT, nil)
 	assert.False(t, tIsOk)
 	assert.EqualValues(t, 2023, newT.Year())
 	assert.EqualValues(t, 2, newT.Month())
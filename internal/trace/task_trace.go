@@ -28,6 +28,13 @@ func Get() TaskTrace {
 	return _trace
 }
 
+// SetRoot 在第一次 Get 之前调用时修改记录的目录, 默认为当前目录下的 trace
+func SetRoot(root string) {
+	_traceOnce.Do(func() {
+		_trace = newTaskTrace(root)
+	})
+}
+
 func newTaskTrace(root string) *taskTraceImpl {
 	return &taskTraceImpl{
 		root:  root,
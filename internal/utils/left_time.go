@@ -1,32 +1,40 @@
 package utils
 
 import (
-	"fmt"
 	"time"
+
+	"github.com/s-min-sys/timeassistbe/internal/locale"
 )
 
 func LeftTimeString(at time.Time) string {
-	timeNow := time.Now()
+	return LeftTimeStringEx(at, time.Now())
+}
+
+func LeftTimeStringEx(at, timeNow time.Time) string {
+	return LeftTimeStringLocale(at, timeNow, locale.Default)
+}
+
+func LeftTimeStringLocale(at, timeNow time.Time, lc locale.Locale) string {
 	if timeNow.After(at) {
-		return "已经过期"
+		return lc.T("left.expired")
 	}
 
 	d := at.Sub(timeNow)
 	if d > time.Hour*24*30 {
-		return fmt.Sprintf("约%d月%d天", d/(time.Hour*24*30), d%(time.Hour*24*30)/(time.Hour*24))
+		return lc.T("left.month_day", d/(time.Hour*24*30), d%(time.Hour*24*30)/(time.Hour*24))
 	}
 
 	if d > time.Hour*24 {
-		return fmt.Sprintf("%d天%d小时", d/(time.Hour*24), d%(time.Hour*24)/time.Hour)
+		return lc.T("left.day_hour", d/(time.Hour*24), d%(time.Hour*24)/time.Hour)
 	}
 
 	if d > time.Hour {
-		return fmt.Sprintf("%d小时%d分", d/time.Hour, (d%time.Hour)/time.Minute)
+		return lc.T("left.hour_minute", d/time.Hour, (d%time.Hour)/time.Minute)
 	}
 
 	if d > time.Minute {
-		return fmt.Sprintf("%d分%d秒", d/time.Minute, (d%time.Minute)/time.Second)
+		return lc.T("left.minute_second", d/time.Minute, (d%time.Minute)/time.Second)
 	}
 
-	return fmt.Sprintf("%d秒", d/time.Second)
+	return lc.T("left.second", d/time.Second)
 }
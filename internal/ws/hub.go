@@ -0,0 +1,197 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sgostarter/i/l"
+)
+
+const (
+	hubSendBufferSize = 64
+	hubWriteTimeout   = 10 * time.Second
+	hubPongTimeout    = 60 * time.Second
+	hubPingInterval   = hubPongTimeout / 2
+)
+
+// SnapshotFn 新连接建立时发送的第一条消息
+type SnapshotFn func() (interface{}, error)
+
+// Hub 把消息以 JSON 广播给所有连接, Serve 可以直接作为 Handler
+type Hub interface {
+	Serve(route string, conn *websocket.Conn)
+	Broadcast(v interface{})
+	Close()
+}
+
+func NewHub(snapshot SnapshotFn, logger l.Wrapper) Hub {
+	if logger == nil {
+		logger = l.NewNopLoggerWrapper()
+	}
+
+	return &hubImpl{
+		snapshot: snapshot,
+		logger:   logger,
+		clients:  make(map[*hubClient]struct{}),
+	}
+}
+
+type hubClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+type hubImpl struct {
+	snapshot SnapshotFn
+	logger   l.Wrapper
+
+	lock    sync.Mutex
+	closed  bool
+	clients map[*hubClient]struct{}
+}
+
+// Serve 持有锁取快照并注册, 快照之后的变化都会收到; 快照和紧接着的事件可能重复, 客户端按 ID 覆盖即可
+func (impl *hubImpl) Serve(route string, conn *websocket.Conn) {
+	logger := impl.logger.WithFields(l.StringField("route", route), l.StringField("remote", conn.RemoteAddr().String()))
+
+	client := &hubClient{
+		conn: conn,
+		send: make(chan []byte, hubSendBufferSize),
+	}
+
+	if !impl.register(client, logger) {
+		return
+	}
+
+	logger.Debug("ws client connected")
+
+	defer func() {
+		impl.unregister(client)
+
+		logger.Debug("ws client disconnected")
+	}()
+
+	go impl.writeLoop(client)
+
+	conn.SetReadLimit(1024)
+	_ = conn.SetReadDeadline(time.Now().Add(hubPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(hubPongTimeout))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
+
+func (impl *hubImpl) register(client *hubClient, logger l.Wrapper) bool {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	if impl.closed {
+		return false
+	}
+
+	if impl.snapshot != nil {
+		v, err := impl.snapshot()
+		if err != nil {
+			logger.WithFields(l.ErrorField(err)).Error("get snapshot failed")
+
+			return false
+		}
+
+		d, err := json.Marshal(v)
+		if err != nil {
+			logger.WithFields(l.ErrorField(err)).Error("marshal snapshot failed")
+
+			return false
+		}
+
+		client.send <- d
+	}
+
+	impl.clients[client] = struct{}{}
+
+	return true
+}
+
+func (impl *hubImpl) unregister(client *hubClient) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	if _, ok := impl.clients[client]; ok {
+		delete(impl.clients, client)
+		close(client.send)
+	}
+}
+
+func (impl *hubImpl) writeLoop(client *hubClient) {
+	ticker := time.NewTicker(hubPingInterval)
+
+	defer func() {
+		ticker.Stop()
+
+		_ = client.conn.Close()
+	}()
+
+	for {
+		select {
+		case d, ok := <-client.send:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(hubWriteTimeout))
+
+			if !ok {
+				_ = client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+
+				return
+			}
+
+			if err := client.conn.WriteMessage(websocket.TextMessage, d); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = client.conn.SetWriteDeadline(time.Now().Add(hubWriteTimeout))
+
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Broadcast 不等待慢的连接, 发送队列满了直接断开, 重连后会重新拿到快照
+func (impl *hubImpl) Broadcast(v interface{}) {
+	d, err := json.Marshal(v)
+	if err != nil {
+		impl.logger.WithFields(l.ErrorField(err)).Error("marshal broadcast message failed")
+
+		return
+	}
+
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	for client := range impl.clients {
+		select {
+		case client.send <- d:
+		default:
+			delete(impl.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+func (impl *hubImpl) Close() {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	impl.closed = true
+
+	for client := range impl.clients {
+		delete(impl.clients, client)
+		close(client.send)
+	}
+}
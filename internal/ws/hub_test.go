@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type utMessage struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+}
+
+func utDialHub(t *testing.T, hub Hub) (conn *websocket.Conn, closeFn func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		upgrader := websocket.Upgrader{}
+
+		c, err := upgrader.Upgrade(writer, request, nil)
+		if err != nil {
+			return
+		}
+
+		defer c.Close()
+
+		hub.Serve("/shows", c)
+	}))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.Nil(t, err)
+
+	closeFn = func() {
+		_ = conn.Close()
+
+		srv.Close()
+	}
+
+	return
+}
+
+func utReadMessage(t *testing.T, conn *websocket.Conn) (msg utMessage) {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	assert.Nil(t, conn.ReadJSON(&msg))
+
+	return
+}
+
+func TestHub(t *testing.T) {
+	hub := NewHub(func() (interface{}, error) {
+		return &utMessage{Type: "snapshot"}, nil
+	}, nil)
+
+	conn1, close1 := utDialHub(t, hub)
+	defer close1()
+
+	assert.Equal(t, "snapshot", utReadMessage(t, conn1).Type)
+
+	conn2, close2 := utDialHub(t, hub)
+	defer close2()
+
+	assert.Equal(t, "snapshot", utReadMessage(t, conn2).Type)
+
+	hub.Broadcast(&utMessage{Type: "add", ID: "A1"})
+
+	assert.Equal(t, utMessage{Type: "add", ID: "A1"}, utReadMessage(t, conn1))
+	assert.Equal(t, utMessage{Type: "add", ID: "A1"}, utReadMessage(t, conn2))
+
+	// 关闭后连接被断开
+	hub.Close()
+
+	_ = conn1.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	_, _, err := conn1.ReadMessage()
+	assert.NotNil(t, err)
+}
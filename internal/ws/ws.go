@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"errors"
 	"net/http"
 	"sync"
 	"time"
@@ -33,21 +34,21 @@ func (impl *Ws) init(addr string, handlers map[string]Handler) {
 	r := mux.NewRouter()
 
 	for s, handler := range handlers {
+		s, handler := s, handler
+
 		r.HandleFunc(s, func(writer http.ResponseWriter, request *http.Request) {
 			upgrader := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool {
 				return true
 			}}
 
+			// 失败时 Upgrade 已经回复了错误
 			conn, err := upgrader.Upgrade(writer, request, nil)
-
-			defer conn.Close() // nolint: staticcheck
-
 			if err != nil {
-				_, _ = writer.Write([]byte(err.Error()))
-
 				return
 			}
 
+			defer conn.Close()
+
 			handler(s, conn)
 		})
 	}
@@ -65,7 +66,7 @@ func (impl *Ws) init(addr string, handlers map[string]Handler) {
 	go func() {
 		defer impl.wg.Done()
 
-		if err := s.ListenAndServe(); err != nil {
+		if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			panic(err)
 		}
 	}()